@@ -1,80 +1,264 @@
 package announcers
 
 import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
 	"github.com/streampets/backend/services"
 	"github.com/streampets/backend/twitch"
 )
 
 type AnnouncerService struct {
-	announce      chan Announcement
-	newClients    chan Client
-	closedClients chan Client
-	totalClients  map[twitch.Id](map[chan Announcement]bool)
+	broker          Broker
+	announce        chan Announcement
+	newClients      chan Client
+	resumingClients chan resumeRequest
+	closedClients   chan Client
+	totalClients    map[twitch.Id](map[chan Announcement]Client)
+	histories       map[twitch.Id]*eventHistory
+	instance        string
+	bufferSize      int
+	historySize     int
+	overflow        OverflowPolicy
+	dropped         atomic.Uint64
+	stopping        chan struct{}
+	stopOnce        sync.Once
+	// Closed once listen has stopped, after which nothing is sent to it.
+	done chan struct{}
+}
+
+type resumeRequest struct {
+	client      Client
+	lastEventId string
+	resumed     chan bool
 }
 
-func NewAnnouncerService() *AnnouncerService {
+func NewAnnouncerService(
+	broker Broker,
+	bufferSize int,
+	historySize int,
+	overflow OverflowPolicy,
+) *AnnouncerService {
 	service := &AnnouncerService{
-		announce:      make(chan Announcement),
-		newClients:    make(chan Client),
-		closedClients: make(chan Client),
-		totalClients:  make(map[twitch.Id]map[chan Announcement]bool),
+		broker:          broker,
+		bufferSize:      bufferSize,
+		historySize:     historySize,
+		overflow:        overflow,
+		instance:        uuid.NewString()[:8],
+		announce:        make(chan Announcement),
+		newClients:      make(chan Client),
+		resumingClients: make(chan resumeRequest),
+		closedClients:   make(chan Client),
+		totalClients:    make(map[twitch.Id]map[chan Announcement]Client),
+		histories:       make(map[twitch.Id]*eventHistory),
+		stopping:        make(chan struct{}),
+		done:            make(chan struct{}),
 	}
 
 	go service.listen()
 
+	broker.Subscribe(func(a Announcement) {
+		select {
+		case service.announce <- a:
+		case <-service.done:
+		}
+	})
+
 	return service
 }
 
 func (s *AnnouncerService) AddClient(channelId twitch.Id) Client {
-	client := newClient(channelId)
-	s.newClients <- client
+	client := newClient(channelId, s.bufferSize)
+	select {
+	case s.newClients <- client:
+	case <-s.done:
+		client.close()
+	}
 	return client
 }
 
+// Adds a client that is first sent the announcements made after lastEventId.
+// Returns false if those announcements are no longer known, for example because
+// the event id was issued before a restart or by another instance. The client
+// is still added in that case but nothing is replayed.
+func (s *AnnouncerService) ResumeClient(channelId twitch.Id, lastEventId string) (Client, bool) {
+	// Leave room for the replayed announcements on top of the usual buffer.
+	client := newClient(channelId, s.bufferSize+s.historySize)
+
+	resumed := make(chan bool)
+	select {
+	case s.resumingClients <- resumeRequest{client: client, lastEventId: lastEventId, resumed: resumed}:
+		return client, <-resumed
+	case <-s.done:
+		client.close()
+		return client, false
+	}
+}
+
 func (s *AnnouncerService) RemoveClient(client Client) {
-	s.closedClients <- client
+	select {
+	case s.closedClients <- client:
+	case <-s.done:
+	}
+}
+
+// Sends every client a reconnect hint, disconnects them and stops handling
+// announcements. Clients added afterwards are closed straight away and
+// announcements are dropped, so nothing blocks on a stopped announcer.
+func (s *AnnouncerService) Close() {
+	s.stopOnce.Do(func() {
+		close(s.stopping)
+	})
+	<-s.done
 }
 
 func (s *AnnouncerService) AnnounceJoin(channelId twitch.Id, pet services.Pet) {
-	s.announce <- joinAnnouncement(channelId, pet)
+	s.publish(joinAnnouncement(channelId, pet))
 }
 
 func (s *AnnouncerService) AnnouncePart(channelId, userId twitch.Id) {
-	s.announce <- partAnnouncement(channelId, userId)
+	s.publish(partAnnouncement(channelId, userId))
 }
 
 func (s *AnnouncerService) AnnounceAction(channelId, userId twitch.Id, action string) {
-	s.announce <- actionAnnouncement(channelId, userId, action)
+	s.publish(actionAnnouncement(channelId, userId, action))
 }
 
 func (s *AnnouncerService) AnnounceUpdate(channelId, userId twitch.Id, image string) {
-	s.announce <- updateAnnouncement(channelId, userId, image)
+	s.publish(updateAnnouncement(channelId, userId, image))
+}
+
+// Returns the number of announcements that did not reach a client because its buffer was full.
+func (s *AnnouncerService) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Logs how many announcements were dropped every interval, for as long as
+// any were, until ctx is cancelled.
+func (s *AnnouncerService) ReportDropped(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var reported uint64
+	for {
+		select {
+		case <-ticker.C:
+			dropped := s.Dropped()
+			if dropped > reported {
+				slog.Warn("announcements dropped for slow clients", "dropped", dropped-reported, "total", dropped, "policy", s.overflow)
+				reported = dropped
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *AnnouncerService) publish(a Announcement) {
+	if err := s.broker.Publish(a); err != nil {
+		slog.Error("error when publishing announcement", "channel_id", a.channelId, "event", a.Event, "err", err.Error())
+	}
 }
 
 func (s *AnnouncerService) handleNewClient(c Client) {
 	_, ok := s.totalClients[c.channelId]
 	if !ok {
-		s.totalClients[c.channelId] = make(map[chan Announcement]bool)
+		s.totalClients[c.channelId] = make(map[chan Announcement]Client)
+	}
+	s.totalClients[c.channelId][c.Stream] = c
+}
+
+func (s *AnnouncerService) handleResumingClient(r resumeRequest) {
+	s.handleNewClient(r.client)
+
+	missed, ok := s.missedSince(r.client.channelId, r.lastEventId)
+	for _, a := range missed {
+		r.client.offer(a, s.overflow)
+	}
+	r.resumed <- ok
+}
+
+func (s *AnnouncerService) missedSince(channelId twitch.Id, lastEventId string) ([]Announcement, bool) {
+	instance, seq, ok := parseEventId(lastEventId)
+	if !ok || instance != s.instance {
+		return nil, false
+	}
+
+	history, ok := s.histories[channelId]
+	if !ok {
+		return nil, false
 	}
-	s.totalClients[c.channelId][c.Stream] = true
+
+	return history.since(seq)
 }
 
 func (s *AnnouncerService) handleClosedClient(c Client) {
-	delete(s.totalClients[c.channelId], c.Stream)
-	close(c.Stream)
+	// The client may already have been disconnected for falling behind.
+	if _, ok := s.totalClients[c.channelId][c.Stream]; ok {
+		s.disconnect(c)
+	}
 }
 
 func (s *AnnouncerService) handleAnnouncement(a Announcement) {
-	for eventStream := range s.totalClients[a.channelId] {
-		eventStream <- a
+	if a.Event == positionEvent || a.Event == OverlayRevokedEvent {
+		return
+	}
+
+	history, ok := s.histories[a.channelId]
+	if !ok {
+		history = newEventHistory(s.historySize)
+		s.histories[a.channelId] = history
+	}
+	a.Id = formatEventId(s.instance, history.lastSeq+1)
+	history.add(a)
+
+	for _, client := range s.totalClients[a.channelId] {
+		if client.offer(a, s.overflow) {
+			continue
+		}
+
+		s.dropped.Add(1)
+		slog.Debug("client buffer full", "channel_id", a.channelId, "policy", s.overflow)
+
+		if s.overflow == Disconnect {
+			s.disconnect(client)
+		}
+	}
+}
+
+func (s *AnnouncerService) disconnect(c Client) {
+	delete(s.totalClients[c.channelId], c.Stream)
+	if len(s.totalClients[c.channelId]) == 0 {
+		delete(s.totalClients, c.channelId)
+	}
+	c.close()
+}
+
+func (s *AnnouncerService) handleClose() {
+	for channelId, clients := range s.totalClients {
+		for _, client := range clients {
+			// Make room for the hint, the overlay is about to resync anyway.
+			client.offer(reconnectAnnouncement(channelId), DropOldest)
+			s.disconnect(client)
+		}
 	}
 }
 
 func (s *AnnouncerService) listen() {
 	for {
 		select {
+		case <-s.stopping:
+			s.handleClose()
+			close(s.done)
+			return
 		case client := <-s.newClients:
 			s.handleNewClient(client)
+		case request := <-s.resumingClients:
+			s.handleResumingClient(request)
 		case client := <-s.closedClients:
 			s.handleClosedClient(client)
 		case announcement := <-s.announce:
@@ -1,10 +1,15 @@
 package announcers
 
 import (
-	"fmt"
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
 	"sync"
 	"testing"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/ovechkin-dm/mockio/mock"
 	"github.com/streampets/backend/services"
 	"github.com/streampets/backend/twitch"
@@ -18,7 +23,7 @@ func TestAddClientWithAnnouncements(t *testing.T) {
 		channelId := twitch.Id("channel id")
 		pet := services.Pet{}
 
-		announcer := NewAnnouncerService()
+		announcer := NewAnnouncerService(NewMemoryBroker(), DefaultBufferSize, DefaultHistorySize, Disconnect)
 
 		client := announcer.AddClient(channelId)
 		assert.Equal(t, channelId, client.channelId)
@@ -38,9 +43,10 @@ func TestAddClientWithAnnouncements(t *testing.T) {
 		wg.Wait()
 
 		expected := Announcement{
+			Id:        formatEventId(announcer.instance, 1),
 			channelId: channelId,
-			Event:     "JOIN",
-			Message:   pet,
+			Event:     JoinEvent,
+			Message:   JoinPayload{Pet: pet},
 		}
 
 		assert.Equal(t, 1, len(events))
@@ -53,7 +59,7 @@ func TestAddClientWithAnnouncements(t *testing.T) {
 		channelId := twitch.Id("channel name")
 		userId := twitch.Id("user id")
 
-		announcer := NewAnnouncerService()
+		announcer := NewAnnouncerService(NewMemoryBroker(), DefaultBufferSize, DefaultHistorySize, Disconnect)
 
 		client := announcer.AddClient(channelId)
 		assert.Equal(t, channelId, client.channelId)
@@ -73,9 +79,10 @@ func TestAddClientWithAnnouncements(t *testing.T) {
 		wg.Wait()
 
 		expected := Announcement{
+			Id:        formatEventId(announcer.instance, 1),
 			channelId: channelId,
-			Event:     "PART",
-			Message:   userId,
+			Event:     PartEvent,
+			Message:   PartPayload{UserId: userId},
 		}
 
 		assert.Equal(t, 1, len(events))
@@ -89,7 +96,7 @@ func TestAddClientWithAnnouncements(t *testing.T) {
 		userId := twitch.Id("user id")
 		action := "action"
 
-		announcer := NewAnnouncerService()
+		announcer := NewAnnouncerService(NewMemoryBroker(), DefaultBufferSize, DefaultHistorySize, Disconnect)
 
 		client := announcer.AddClient(channelId)
 		assert.Equal(t, channelId, client.channelId)
@@ -109,9 +116,10 @@ func TestAddClientWithAnnouncements(t *testing.T) {
 		wg.Wait()
 
 		expected := Announcement{
+			Id:        formatEventId(announcer.instance, 1),
 			channelId: channelId,
-			Event:     fmt.Sprintf("%s-%s", action, userId),
-			Message:   userId,
+			Event:     ActionEvent,
+			Message:   ActionPayload{UserId: userId, Action: action},
 		}
 
 		assert.Equal(t, 1, len(events))
@@ -125,7 +133,7 @@ func TestAddClientWithAnnouncements(t *testing.T) {
 		userId := twitch.Id("user id")
 		image := "image"
 
-		announcer := NewAnnouncerService()
+		announcer := NewAnnouncerService(NewMemoryBroker(), DefaultBufferSize, DefaultHistorySize, Disconnect)
 
 		client := announcer.AddClient(channelId)
 		assert.Equal(t, channelId, client.channelId)
@@ -144,10 +152,15 @@ func TestAddClientWithAnnouncements(t *testing.T) {
 		announcer.AnnounceUpdate(channelId, userId, image)
 		wg.Wait()
 
-		expected := fmt.Sprintf("%s-%s", "COLOR", userId)
+		expected := Announcement{
+			Id:        formatEventId(announcer.instance, 1),
+			channelId: channelId,
+			Event:     UpdateEvent,
+			Message:   UpdatePayload{UserId: userId, Image: image},
+		}
 
 		assert.Equal(t, 1, len(events))
-		assert.Equal(t, expected, events[0].Event)
+		assert.Equal(t, expected, events[0])
 	})
 }
 
@@ -157,7 +170,7 @@ func TestRemoveClientWithAnnouncements(t *testing.T) {
 	channelId := twitch.Id("channel id")
 	pet := services.Pet{}
 
-	announcer := NewAnnouncerService()
+	announcer := NewAnnouncerService(NewMemoryBroker(), DefaultBufferSize, DefaultHistorySize, Disconnect)
 
 	client := announcer.AddClient(channelId)
 	assert.Equal(t, channelId, client.channelId)
@@ -190,7 +203,7 @@ func TestAnnouncerOnMultipleChannels(t *testing.T) {
 	channelTwoId := twitch.Id("channel two id")
 	pet := services.Pet{}
 
-	announcer := NewAnnouncerService()
+	announcer := NewAnnouncerService(NewMemoryBroker(), DefaultBufferSize, DefaultHistorySize, Disconnect)
 
 	clientOne := announcer.AddClient(channelOneId)
 	assert.Equal(t, channelOneId, clientOne.channelId)
@@ -221,12 +234,226 @@ func TestAnnouncerOnMultipleChannels(t *testing.T) {
 	wg.Wait()
 
 	expected := Announcement{
+		Id:        formatEventId(announcer.instance, 1),
 		channelId: channelOneId,
-		Event:     "JOIN",
-		Message:   pet,
+		Event:     JoinEvent,
+		Message:   JoinPayload{Pet: pet},
 	}
 
 	assert.Equal(t, 1, len(eventsOne))
 	assert.Equal(t, expected, eventsOne[0])
 	assert.Equal(t, 0, len(eventsTwo))
 }
+
+func TestSlowClientOverflow(t *testing.T) {
+	channelId := twitch.Id("channel id")
+	first := partAnnouncement(channelId, twitch.Id("first"))
+	second := partAnnouncement(channelId, twitch.Id("second"))
+
+	setUp := func(overflow OverflowPolicy) (*AnnouncerService, Client) {
+		announcer := NewAnnouncerService(NewMemoryBroker(), 1, DefaultHistorySize, overflow)
+		client := announcer.AddClient(channelId)
+
+		announcer.AnnouncePart(channelId, twitch.Id("first"))
+		announcer.AnnouncePart(channelId, twitch.Id("second"))
+
+		assert.Eventually(t, func() bool {
+			return announcer.Dropped() == 1
+		}, time.Second, time.Millisecond)
+
+		return announcer, client
+	}
+
+	t.Run("drop oldest keeps the newest announcement", func(t *testing.T) {
+		announcer, client := setUp(DropOldest)
+
+		second.Id = formatEventId(announcer.instance, 2)
+		assert.Equal(t, second, <-client.Stream)
+	})
+
+	t.Run("drop newest keeps the oldest announcement", func(t *testing.T) {
+		announcer, client := setUp(DropNewest)
+
+		first.Id = formatEventId(announcer.instance, 1)
+		assert.Equal(t, first, <-client.Stream)
+	})
+
+	t.Run("disconnect closes the stream", func(t *testing.T) {
+		announcer, client := setUp(Disconnect)
+
+		first.Id = formatEventId(announcer.instance, 1)
+		assert.Equal(t, first, <-client.Stream)
+		_, ok := <-client.Stream
+		assert.False(t, ok)
+
+		// Removing an already disconnected client must not close the stream twice.
+		announcer.RemoveClient(client)
+	})
+
+	t.Run("dropped announcements reported", func(t *testing.T) {
+		var logs bytes.Buffer
+		defer slog.SetDefault(slog.Default())
+		slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+
+		announcer, _ := setUp(DropNewest)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		stopped := make(chan struct{})
+		go func() {
+			announcer.ReportDropped(ctx, 10*time.Millisecond)
+			close(stopped)
+		}()
+
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+		<-stopped
+
+		// Only announcements dropped since the last report are counted.
+		assert.Equal(t, 1, strings.Count(logs.String(), "announcements dropped"))
+		assert.Contains(t, logs.String(), "dropped=1 total=1")
+	})
+
+	t.Run("slow client does not block other clients", func(t *testing.T) {
+		announcer := NewAnnouncerService(NewMemoryBroker(), 1, DefaultHistorySize, DropNewest)
+		announcer.AddClient(channelId)
+
+		done := make(chan bool)
+		go func() {
+			for i := 0; i < 10; i++ {
+				announcer.AnnouncePart(channelId, twitch.Id("user id"))
+			}
+			done <- true
+		}()
+
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("announcements blocked on a slow client")
+		}
+	})
+}
+
+func TestResumeClient(t *testing.T) {
+	channelId := twitch.Id("channel id")
+
+	t.Run("missed announcements are replayed after the last event id", func(t *testing.T) {
+		announcer := NewAnnouncerService(NewMemoryBroker(), DefaultBufferSize, DefaultHistorySize, Disconnect)
+
+		client := announcer.AddClient(channelId)
+		announcer.AnnouncePart(channelId, twitch.Id("seen"))
+		seen := <-client.Stream
+		announcer.RemoveClient(client)
+
+		announcer.AnnouncePart(channelId, twitch.Id("missed one"))
+		announcer.AnnouncePart(channelId, twitch.Id("missed two"))
+
+		client, resumed := announcer.ResumeClient(channelId, seen.Id)
+		assert.True(t, resumed)
+
+		missedOne := partAnnouncement(channelId, twitch.Id("missed one"))
+		missedOne.Id = formatEventId(announcer.instance, 2)
+		missedTwo := partAnnouncement(channelId, twitch.Id("missed two"))
+		missedTwo.Id = formatEventId(announcer.instance, 3)
+
+		assert.Equal(t, missedOne, <-client.Stream)
+		assert.Equal(t, missedTwo, <-client.Stream)
+
+		announcer.AnnouncePart(channelId, twitch.Id("live"))
+		assert.Equal(t, formatEventId(announcer.instance, 4), (<-client.Stream).Id)
+	})
+
+	t.Run("event ids are numbered per channel", func(t *testing.T) {
+		announcer := NewAnnouncerService(NewMemoryBroker(), DefaultBufferSize, DefaultHistorySize, Disconnect)
+
+		otherClient := announcer.AddClient(twitch.Id("other channel id"))
+		client := announcer.AddClient(channelId)
+
+		announcer.AnnouncePart(twitch.Id("other channel id"), twitch.Id("user id"))
+		announcer.AnnouncePart(channelId, twitch.Id("user id"))
+
+		assert.Equal(t, formatEventId(announcer.instance, 1), (<-otherClient.Stream).Id)
+		assert.Equal(t, formatEventId(announcer.instance, 1), (<-client.Stream).Id)
+	})
+
+	t.Run("event ids from another instance are not resumed", func(t *testing.T) {
+		announcer := NewAnnouncerService(NewMemoryBroker(), DefaultBufferSize, DefaultHistorySize, Disconnect)
+		announcer.AnnouncePart(channelId, twitch.Id("user id"))
+
+		client, resumed := announcer.ResumeClient(channelId, formatEventId("other", 1))
+		assert.False(t, resumed)
+
+		select {
+		case a := <-client.Stream:
+			t.Errorf("did not expect a msg but received %v", a)
+		default:
+		}
+	})
+
+	t.Run("event ids older than the history are not resumed", func(t *testing.T) {
+		announcer := NewAnnouncerService(NewMemoryBroker(), DefaultBufferSize, 1, Disconnect)
+		announcer.AnnouncePart(channelId, twitch.Id("one"))
+		announcer.AnnouncePart(channelId, twitch.Id("two"))
+		announcer.AnnouncePart(channelId, twitch.Id("three"))
+
+		_, resumed := announcer.ResumeClient(channelId, formatEventId(announcer.instance, 1))
+		assert.False(t, resumed)
+	})
+}
+
+func TestCloseAnnouncer(t *testing.T) {
+	t.Run("clients are sent a reconnect hint and closed", func(t *testing.T) {
+		channelId := twitch.Id("channel id")
+		announcer := NewAnnouncerService(NewMemoryBroker(), 1, DefaultHistorySize, Disconnect)
+
+		client := announcer.AddClient(channelId)
+		announcer.AnnounceJoin(channelId, services.Pet{UserId: "user id"})
+
+		announcer.Close()
+
+		events := []Announcement{}
+		for event := range client.Stream {
+			events = append(events, event)
+		}
+
+		expected := []Announcement{reconnectAnnouncement(channelId)}
+		assert.Equal(t, expected, events)
+	})
+
+	t.Run("nothing blocks once closed", func(t *testing.T) {
+		channelId := twitch.Id("channel id")
+		announcer := NewAnnouncerService(NewMemoryBroker(), DefaultBufferSize, DefaultHistorySize, Disconnect)
+		connected := announcer.AddClient(channelId)
+
+		announcer.Close()
+		announcer.Close()
+
+		announcer.AnnounceJoin(channelId, services.Pet{UserId: "user id"})
+		announcer.RemoveClient(connected)
+
+		client := announcer.AddClient(channelId)
+		_, open := <-client.Stream
+		assert.False(t, open)
+
+		resumed, ok := announcer.ResumeClient(channelId, formatEventId(announcer.instance, 1))
+		_, open = <-resumed.Stream
+		assert.False(t, ok)
+		assert.False(t, open)
+	})
+}
+
+func TestInternalEventsNotSentToClients(t *testing.T) {
+	channelId := twitch.Id("channel id")
+	userId := twitch.Id("user id")
+
+	broker := NewMemoryBroker()
+	announcer := NewAnnouncerService(broker, DefaultBufferSize, DefaultHistorySize, Disconnect)
+	client := announcer.AddClient(channelId)
+
+	assert.NoError(t, broker.Publish(positionAnnouncement(channelId, userId, services.Position{X: 1, Y: 2})))
+	assert.NoError(t, broker.Publish(OverlayRevokedAnnouncement(channelId, uuid.New())))
+	announcer.AnnouncePart(channelId, userId)
+
+	expected := partAnnouncement(channelId, userId)
+	expected.Id = formatEventId(announcer.instance, 1)
+	assert.Equal(t, expected, <-client.Stream)
+}
@@ -0,0 +1,77 @@
+package announcers
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/streampets/backend/twitch"
+)
+
+// A Broker fans announcements out to every backend instance.
+// Each AnnouncerService subscribes once and forwards whatever it receives to
+// the clients connected to that instance. Lock lets a single instance take on
+// work that every instance would otherwise repeat; it reports whether the
+// named lock was taken, which it is for ttl unless another instance holds it.
+type Broker interface {
+	Publish(announcement Announcement) error
+	Subscribe(handler func(Announcement))
+	Lock(name string, ttl time.Duration) (bool, error)
+	Close() error
+}
+
+type wireAnnouncement struct {
+	ChannelId twitch.Id       `json:"channel_id"`
+	Event     string          `json:"event"`
+	Message   json.RawMessage `json:"message"`
+}
+
+func encodeAnnouncement(a Announcement) ([]byte, error) {
+	message, err := json.Marshal(a.Message)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(wireAnnouncement{
+		ChannelId: a.channelId,
+		Event:     a.Event,
+		Message:   message,
+	})
+}
+
+func decodeAnnouncement(data []byte) (Announcement, error) {
+	var wire wireAnnouncement
+	if err := json.Unmarshal(data, &wire); err != nil {
+		return Announcement{}, err
+	}
+
+	var message interface{}
+	var err error
+	switch wire.Event {
+	case JoinEvent:
+		message, err = decodePayload[JoinPayload](wire.Message)
+	case PartEvent:
+		message, err = decodePayload[PartPayload](wire.Message)
+	case ActionEvent:
+		message, err = decodePayload[ActionPayload](wire.Message)
+	case UpdateEvent:
+		message, err = decodePayload[UpdatePayload](wire.Message)
+	case positionEvent:
+		message, err = decodePayload[positionPayload](wire.Message)
+	case OverlayRevokedEvent:
+		message, err = decodePayload[OverlayRevokedPayload](wire.Message)
+	default:
+		err = fmt.Errorf("unknown event %q", wire.Event)
+	}
+	if err != nil {
+		return Announcement{}, err
+	}
+
+	return newAnnouncement(wire.ChannelId, wire.Event, message), nil
+}
+
+func decodePayload[T any](data json.RawMessage) (T, error) {
+	var payload T
+	err := json.Unmarshal(data, &payload)
+	return payload, err
+}
@@ -0,0 +1,45 @@
+package announcers
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/streampets/backend/services"
+	"github.com/streampets/backend/twitch"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncodeDecodeAnnouncement(t *testing.T) {
+	channelId := twitch.Id("channel id")
+	userId := twitch.Id("user id")
+
+	tests := map[string]Announcement{
+		"join":     joinAnnouncement(channelId, services.Pet{UserId: userId, Username: "username", Image: "image"}),
+		"part":     partAnnouncement(channelId, userId),
+		"action":   actionAnnouncement(channelId, userId, "action"),
+		"update":   updateAnnouncement(channelId, userId, "image"),
+		"position": positionAnnouncement(channelId, userId, services.Position{X: 1, Y: 2}),
+		"revoked":  OverlayRevokedAnnouncement(channelId, uuid.New()),
+	}
+
+	for name, expected := range tests {
+		t.Run(name, func(t *testing.T) {
+			data, err := encodeAnnouncement(expected)
+			assert.NoError(t, err)
+
+			actual, err := decodeAnnouncement(data)
+			assert.NoError(t, err)
+			assert.Equal(t, expected, actual)
+		})
+	}
+}
+
+func TestDecodeInvalidAnnouncement(t *testing.T) {
+	_, err := decodeAnnouncement([]byte("not json"))
+	assert.Error(t, err)
+}
+
+func TestDecodeUnknownEvent(t *testing.T) {
+	_, err := decodeAnnouncement([]byte(`{"channel_id":"channel id","event":"unknown","message":"message"}`))
+	assert.Error(t, err)
+}
@@ -1,90 +1,128 @@
 package announcers
 
 import (
+	"log/slog"
+	"sort"
+
 	"github.com/streampets/backend/services"
 	"github.com/streampets/backend/twitch"
 )
 
 type announcer interface {
 	AddClient(channelId twitch.Id) Client
+	ResumeClient(channelId twitch.Id, lastEventId string) (Client, bool)
 	RemoveClient(client Client)
-	AnnounceJoin(channelId twitch.Id, pet services.Pet)
-	AnnouncePart(channelId, userId twitch.Id)
-	AnnounceAction(channelId, userId twitch.Id, action string)
-	AnnounceUpdate(channelId, userId twitch.Id, image string)
 }
 
+// Keeps the pets on every channel's overlay so overlays that connect are sent
+// them. Announcements are published to the broker and the cache is only
+// changed when they come back from it, so every instance caches the same pets
+// whichever instance the announcement was made on.
 type CachedAnnouncerService struct {
 	announcer announcer
-	cache     cacheMap
+	broker    Broker
+	cache     *petCache
 }
 
 func NewCachedAnnouncerService(
 	announcer announcer,
+	broker Broker,
 ) *CachedAnnouncerService {
-	return &CachedAnnouncerService{
-		cache:     make(cacheMap),
+	service := &CachedAnnouncerService{
+		cache:     newPetCache(),
 		announcer: announcer,
+		broker:    broker,
 	}
+
+	broker.Subscribe(service.apply)
+
+	return service
 }
 
 func (s *CachedAnnouncerService) AddClient(channelId twitch.Id) Client {
 	client := s.announcer.AddClient(channelId)
+	go s.replayCache(client)
+	return client
+}
 
-	go func() {
-		pets, ok := s.cache[channelId]
-		if ok {
-			for _, pet := range pets {
-				client.Stream <- joinAnnouncement(channelId, pet)
-			}
-		}
-	}()
-
+// Adds a client that is sent the announcements it missed since lastEventId.
+// Falls back to the cached pets when the missed announcements are not known.
+func (s *CachedAnnouncerService) ResumeClient(channelId twitch.Id, lastEventId string) Client {
+	client, resumed := s.announcer.ResumeClient(channelId, lastEventId)
+	if !resumed {
+		go s.replayCache(client)
+	}
 	return client
 }
 
+func (s *CachedAnnouncerService) replayCache(client Client) {
+	for _, pet := range s.cache.pets(client.channelId) {
+		if !client.send(joinAnnouncement(client.channelId, pet)) {
+			return
+		}
+	}
+}
+
 func (s *CachedAnnouncerService) RemoveClient(client Client) {
 	s.announcer.RemoveClient(client)
 }
 
 func (s *CachedAnnouncerService) AnnounceJoin(channelId twitch.Id, pet services.Pet) {
-	pets, ok := s.cache[channelId]
-	if !ok {
-		pets = make(petMap)
-		s.cache[channelId] = pets
-	}
-	pets[pet.UserId] = pet
-
-	s.announcer.AnnounceJoin(channelId, pet)
+	s.publish(joinAnnouncement(channelId, pet))
 }
 
+// Parts are announced even when this instance has not cached the pet, as the
+// overlays showing it may have been sent it by another instance.
 func (s *CachedAnnouncerService) AnnouncePart(channelId, userId twitch.Id) {
-	pets, ok := s.cache[channelId]
-	if !ok {
-		return
-	}
-	delete(pets, userId)
-
-	s.announcer.AnnouncePart(channelId, userId)
+	s.publish(partAnnouncement(channelId, userId))
 }
 
 func (s *CachedAnnouncerService) AnnounceAction(channelId, userId twitch.Id, action string) {
-	s.announcer.AnnounceAction(channelId, userId, action)
+	s.publish(actionAnnouncement(channelId, userId, action))
 }
 
 func (s *CachedAnnouncerService) AnnounceUpdate(channelId, userId twitch.Id, image string) {
-	pets, ok := s.cache[channelId]
-	if !ok {
-		return
+	s.publish(updateAnnouncement(channelId, userId, image))
+}
+
+// Records where an overlay placed a pet. Overlays that connect later, to any
+// instance, are sent the pet at that position.
+func (s *CachedAnnouncerService) ReportPosition(channelId, userId twitch.Id, position services.Position) {
+	s.publish(positionAnnouncement(channelId, userId, position))
+}
+
+func (s *CachedAnnouncerService) publish(a Announcement) {
+	if err := s.broker.Publish(a); err != nil {
+		slog.Error("error when publishing announcement", "channel_id", a.channelId, "event", a.Event, "err", err.Error())
 	}
+}
 
-	pet, ok := pets[userId]
-	if !ok {
-		return
+// Applies an announcement received from the broker to the cache.
+func (s *CachedAnnouncerService) apply(a Announcement) {
+	switch payload := a.Message.(type) {
+	case JoinPayload:
+		s.cache.add(a.channelId, payload.Pet)
+	case PartPayload:
+		s.cache.remove(a.channelId, payload.UserId)
+	case ActionPayload:
+		s.cache.touch(a.channelId, payload.UserId)
+	case UpdatePayload:
+		s.cache.update(a.channelId, payload.UserId, payload.Image)
+	case positionPayload:
+		s.cache.move(a.channelId, payload.UserId, payload.Position)
 	}
+}
 
-	pet.Image = image
-	s.cache[channelId][userId] = pet
+// Reports whether the user's pet is currently on the channel's overlay.
+func (s *CachedAnnouncerService) HasPet(channelId, userId twitch.Id) bool {
+	return s.cache.has(channelId, userId)
+}
 
-	s.announcer.AnnounceUpdate(channelId, userId, image)
+// Returns the pets currently on the channel's overlay, in the order they joined.
+func (s *CachedAnnouncerService) GetPresence(channelId twitch.Id) []Presence {
+	presence := s.cache.presence(channelId)
+	sort.Slice(presence, func(i, j int) bool {
+		return presence[i].JoinedAt.Before(presence[j].JoinedAt)
+	})
+	return presence
 }
@@ -1,6 +1,7 @@
 package announcers
 
 import (
+	"fmt"
 	"sync"
 	"testing"
 	"time"
@@ -11,16 +12,25 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
+// Returns a cached announcer using a memory broker, and the announcements published to it.
+func newTestCachedAnnouncer(announcer announcer) (*CachedAnnouncerService, *[]Announcement) {
+	broker := NewMemoryBroker()
+	published := &[]Announcement{}
+	broker.Subscribe(func(a Announcement) { *published = append(*published, a) })
+
+	return NewCachedAnnouncerService(announcer, broker), published
+}
+
 func TestAddClient(t *testing.T) {
 	mock.SetUp(t)
 
 	channelId := twitch.Id("channel id")
-	expected := newClient(channelId)
+	expected := newClient(channelId, DefaultBufferSize)
 
 	announcerMock := mock.Mock[announcer]()
 	mock.When(announcerMock.AddClient(channelId)).ThenReturn(expected)
 
-	cachedAnnouncer := NewCachedAnnouncerService(announcerMock)
+	cachedAnnouncer, _ := newTestCachedAnnouncer(announcerMock)
 	actual := cachedAnnouncer.AddClient(channelId)
 
 	assert.Equal(t, expected, actual)
@@ -30,11 +40,11 @@ func TestRemoveClient(t *testing.T) {
 	mock.SetUp(t)
 
 	channelId := twitch.Id("channel id")
-	client := newClient(channelId)
+	client := newClient(channelId, DefaultBufferSize)
 
 	announcerMock := mock.Mock[announcer]()
 
-	cachedAnnouncer := NewCachedAnnouncerService(announcerMock)
+	cachedAnnouncer, _ := newTestCachedAnnouncer(announcerMock)
 	cachedAnnouncer.RemoveClient(client)
 
 	mock.Verify(announcerMock, mock.Once()).RemoveClient(client)
@@ -46,12 +56,12 @@ func TestAnnounceJoin(t *testing.T) {
 	channelId := twitch.Id("channel id")
 
 	pet := services.Pet{}
-	client := newClient(channelId)
+	client := newClient(channelId, DefaultBufferSize)
 
 	announcerMock := mock.Mock[announcer]()
 	mock.When(announcerMock.AddClient(channelId)).ThenReturn(client)
 
-	cachedAnnouncer := NewCachedAnnouncerService(announcerMock)
+	cachedAnnouncer, published := newTestCachedAnnouncer(announcerMock)
 	cachedAnnouncer.AnnounceJoin(channelId, pet)
 	cachedAnnouncer.AddClient(channelId)
 
@@ -73,7 +83,7 @@ func TestAnnounceJoin(t *testing.T) {
 	assert.Equal(t, 1, len(announcements))
 	assert.Equal(t, expected, announcements[0])
 
-	mock.Verify(announcerMock, mock.Once()).AnnounceJoin(channelId, pet)
+	assert.Equal(t, []Announcement{expected}, *published)
 }
 
 func TestAnnouncePart(t *testing.T) {
@@ -83,12 +93,12 @@ func TestAnnouncePart(t *testing.T) {
 	userId := twitch.Id("user id")
 
 	pet := services.Pet{UserId: userId}
-	client := newClient(channelId)
+	client := newClient(channelId, DefaultBufferSize)
 
 	announcerMock := mock.Mock[announcer]()
 	mock.When(announcerMock.AddClient(channelId)).ThenReturn(client)
 
-	cachedAnnouncer := NewCachedAnnouncerService(announcerMock)
+	cachedAnnouncer, published := newTestCachedAnnouncer(announcerMock)
 	cachedAnnouncer.AnnounceJoin(channelId, pet)
 	cachedAnnouncer.AnnouncePart(channelId, userId)
 	cachedAnnouncer.AddClient(channelId)
@@ -99,7 +109,24 @@ func TestAnnouncePart(t *testing.T) {
 	case <-time.After(1 * time.Second):
 	}
 
-	mock.Verify(announcerMock, mock.Once()).AnnouncePart(channelId, userId)
+	assert.Equal(t, partAnnouncement(channelId, userId), (*published)[1])
+}
+
+func TestAnnouncePartOfUncachedPet(t *testing.T) {
+	mock.SetUp(t)
+
+	channelId := twitch.Id("channel id")
+	userId := twitch.Id("user id")
+
+	cachedAnnouncer, published := newTestCachedAnnouncer(mock.Mock[announcer]())
+	cachedAnnouncer.AnnouncePart(channelId, userId)
+	cachedAnnouncer.AnnounceUpdate(channelId, userId, "image")
+
+	assert.Equal(t, []Announcement{
+		partAnnouncement(channelId, userId),
+		updateAnnouncement(channelId, userId, "image"),
+	}, *published)
+	assert.False(t, cachedAnnouncer.HasPet(channelId, userId))
 }
 
 func TestAnnounceAction(t *testing.T) {
@@ -111,10 +138,10 @@ func TestAnnounceAction(t *testing.T) {
 
 	announcerMock := mock.Mock[announcer]()
 
-	cachedAnnouncer := NewCachedAnnouncerService(announcerMock)
+	cachedAnnouncer, published := newTestCachedAnnouncer(announcerMock)
 	cachedAnnouncer.AnnounceAction(channelId, userId, action)
 
-	mock.Verify(announcerMock, mock.Once()).AnnounceAction(channelId, userId, action)
+	assert.Equal(t, []Announcement{actionAnnouncement(channelId, userId, action)}, *published)
 }
 
 func TestAnnounceUpdate(t *testing.T) {
@@ -126,12 +153,12 @@ func TestAnnounceUpdate(t *testing.T) {
 	newImage := "new image"
 
 	pet := services.Pet{UserId: userId, Image: image}
-	client := newClient(channelId)
+	client := newClient(channelId, DefaultBufferSize)
 
 	announcerMock := mock.Mock[announcer]()
 	mock.When(announcerMock.AddClient(channelId)).ThenReturn(client)
 
-	cachedAnnouncer := NewCachedAnnouncerService(announcerMock)
+	cachedAnnouncer, published := newTestCachedAnnouncer(announcerMock)
 	cachedAnnouncer.AnnounceJoin(channelId, pet)
 	cachedAnnouncer.AnnounceUpdate(channelId, userId, newImage)
 	cachedAnnouncer.AddClient(channelId)
@@ -151,10 +178,172 @@ func TestAnnounceUpdate(t *testing.T) {
 
 	assert.Equal(t, 1, len(announcements))
 
-	actual := announcements[0].Message.(services.Pet)
+	actual := announcements[0].Message.(JoinPayload).Pet
 	expected := services.Pet{UserId: userId, Image: newImage}
 
 	assert.Equal(t, expected, actual)
 
-	mock.Verify(announcerMock, mock.Once()).AnnounceUpdate(channelId, userId, newImage)
+	assert.Equal(t, updateAnnouncement(channelId, userId, newImage), (*published)[1])
+}
+
+func TestCachedAnnouncerConcurrency(t *testing.T) {
+	channelIds := []twitch.Id{"channel one", "channel two", "channel three"}
+
+	for _, overflow := range []OverflowPolicy{DropOldest, DropNewest, Disconnect} {
+		t.Run(string(overflow), func(t *testing.T) {
+			broker := NewMemoryBroker()
+			announcer := NewAnnouncerService(broker, 4, DefaultHistorySize, overflow)
+			cachedAnnouncer := NewCachedAnnouncerService(announcer, broker)
+
+			var wg sync.WaitGroup
+
+			for worker := 0; worker < 8; worker++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for i := 0; i < 200; i++ {
+						channelId := channelIds[i%len(channelIds)]
+						userId := twitch.Id(fmt.Sprintf("user %d", (i+worker)%10))
+
+						switch i % 4 {
+						case 0:
+							cachedAnnouncer.AnnounceJoin(channelId, services.Pet{UserId: userId})
+						case 1:
+							cachedAnnouncer.AnnounceUpdate(channelId, userId, "image")
+						case 2:
+							cachedAnnouncer.AnnounceAction(channelId, userId, "jump")
+						case 3:
+							cachedAnnouncer.AnnouncePart(channelId, userId)
+						}
+					}
+				}()
+			}
+
+			for viewer := 0; viewer < 8; viewer++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for i := 0; i < 50; i++ {
+						client := cachedAnnouncer.AddClient(channelIds[(i+viewer)%len(channelIds)])
+						for read := 0; read < i%3; read++ {
+							select {
+							case <-client.Stream:
+							case <-time.After(time.Millisecond):
+							}
+						}
+						cachedAnnouncer.RemoveClient(client)
+					}
+				}()
+			}
+
+			wg.Wait()
+		})
+	}
+}
+
+func TestResumeCachedClient(t *testing.T) {
+	t.Run("cached pets are not replayed when resumed", func(t *testing.T) {
+		mock.SetUp(t)
+
+		channelId := twitch.Id("channel id")
+		lastEventId := "last event id"
+		client := newClient(channelId, DefaultBufferSize)
+
+		announcerMock := mock.Mock[announcer]()
+		mock.When(announcerMock.ResumeClient(channelId, lastEventId)).ThenReturn(client, true)
+
+		cachedAnnouncer, _ := newTestCachedAnnouncer(announcerMock)
+		cachedAnnouncer.AnnounceJoin(channelId, services.Pet{})
+		actual := cachedAnnouncer.ResumeClient(channelId, lastEventId)
+
+		assert.Equal(t, client, actual)
+
+		select {
+		case msg := <-client.Stream:
+			t.Errorf("did not expect a msg but received %v", msg)
+		case <-time.After(100 * time.Millisecond):
+		}
+	})
+
+	t.Run("cached pets are replayed when not resumed", func(t *testing.T) {
+		mock.SetUp(t)
+
+		channelId := twitch.Id("channel id")
+		lastEventId := "last event id"
+		pet := services.Pet{}
+		client := newClient(channelId, DefaultBufferSize)
+
+		announcerMock := mock.Mock[announcer]()
+		mock.When(announcerMock.ResumeClient(channelId, lastEventId)).ThenReturn(client, false)
+
+		cachedAnnouncer, _ := newTestCachedAnnouncer(announcerMock)
+		cachedAnnouncer.AnnounceJoin(channelId, pet)
+		cachedAnnouncer.ResumeClient(channelId, lastEventId)
+
+		assert.Equal(t, joinAnnouncement(channelId, pet), <-client.Stream)
+	})
+}
+
+func TestReportPosition(t *testing.T) {
+	mock.SetUp(t)
+
+	channelId := twitch.Id("channel id")
+	userId := twitch.Id("user id")
+	position := services.Position{X: 1, Y: 2}
+
+	pet := services.Pet{UserId: userId}
+	client := newClient(channelId, DefaultBufferSize)
+
+	announcerMock := mock.Mock[announcer]()
+	mock.When(announcerMock.AddClient(channelId)).ThenReturn(client)
+
+	cachedAnnouncer, _ := newTestCachedAnnouncer(announcerMock)
+	cachedAnnouncer.AnnounceJoin(channelId, pet)
+	cachedAnnouncer.ReportPosition(channelId, userId, position)
+	cachedAnnouncer.AddClient(channelId)
+
+	actual := (<-client.Stream).Message.(JoinPayload).Pet
+	expected := services.Pet{UserId: userId, Position: &position}
+
+	assert.Equal(t, expected, actual)
+}
+
+func TestGetPresence(t *testing.T) {
+	mock.SetUp(t)
+
+	now := time.Now()
+	channelId := twitch.Id("channel id")
+	first := services.Pet{UserId: "first", Username: "first", Image: "image"}
+	second := services.Pet{UserId: "second", Username: "second", Image: "image"}
+
+	cachedAnnouncer, _ := newTestCachedAnnouncer(mock.Mock[announcer]())
+
+	cachedAnnouncer.cache.now = func() time.Time { return now }
+	cachedAnnouncer.AnnounceJoin(channelId, first)
+	cachedAnnouncer.cache.now = func() time.Time { return now.Add(time.Minute) }
+	cachedAnnouncer.AnnounceJoin(channelId, second)
+	cachedAnnouncer.cache.now = func() time.Time { return now.Add(2 * time.Minute) }
+	cachedAnnouncer.AnnounceAction(channelId, first.UserId, "action")
+
+	expected := []Presence{
+		{Pet: first, JoinedAt: now, LastSeen: now.Add(2 * time.Minute)},
+		{Pet: second, JoinedAt: now.Add(time.Minute), LastSeen: now.Add(time.Minute)},
+	}
+
+	assert.Equal(t, expected, cachedAnnouncer.GetPresence(channelId))
+	assert.Empty(t, cachedAnnouncer.GetPresence("other channel"))
+}
+
+func TestHasPet(t *testing.T) {
+	mock.SetUp(t)
+
+	channelId := twitch.Id("channel id")
+	pet := services.Pet{UserId: "user id"}
+
+	cachedAnnouncer, _ := newTestCachedAnnouncer(mock.Mock[announcer]())
+	assert.False(t, cachedAnnouncer.HasPet(channelId, pet.UserId))
+
+	cachedAnnouncer.AnnounceJoin(channelId, pet)
+	assert.True(t, cachedAnnouncer.HasPet(channelId, pet.UserId))
+	assert.False(t, cachedAnnouncer.HasPet("other channel", pet.UserId))
 }
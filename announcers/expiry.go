@@ -0,0 +1,72 @@
+package announcers
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/streampets/backend/twitch"
+)
+
+// Looks up how long a channel's pets may stay idle.
+// A zero duration means the channel uses the default timeout.
+type IdleTimeoutGetter interface {
+	GetIdleTimeout(channelId twitch.Id) (time.Duration, error)
+}
+
+// Removes pets that have not joined, acted or been updated within their
+// channel's idle timeout and announces that they left.
+func (s *CachedAnnouncerService) ExpireIdlePets(timeouts IdleTimeoutGetter, defaultTimeout time.Duration) {
+	now := s.cache.now()
+
+	for _, channelId := range s.cache.channelIds() {
+		timeout, err := timeouts.GetIdleTimeout(channelId)
+		if err != nil {
+			slog.Error("error when getting idle timeout", "channel_id", channelId, "err", err.Error())
+			timeout = 0
+		}
+		if timeout <= 0 {
+			timeout = defaultTimeout
+		}
+
+		for _, userId := range s.cache.expire(channelId, now.Add(-timeout)) {
+			s.AnnouncePart(channelId, userId)
+		}
+	}
+}
+
+const sweepLock = "sweep-idle-pets"
+
+// Expires idle pets every interval until ctx is done. Every instance caches
+// the same pets, so only the instance that takes the sweep lock expires them
+// and the others drop them when the part reaches them.
+func (s *CachedAnnouncerService) SweepIdlePets(
+	ctx context.Context,
+	timeouts IdleTimeoutGetter,
+	defaultTimeout time.Duration,
+	interval time.Duration,
+) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			// The lock expires before the next tick so any instance can take it then.
+			s.sweepIdlePets(timeouts, defaultTimeout, interval/2)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *CachedAnnouncerService) sweepIdlePets(timeouts IdleTimeoutGetter, defaultTimeout time.Duration, lockTtl time.Duration) {
+	locked, err := s.broker.Lock(sweepLock, lockTtl)
+	if err != nil {
+		slog.Error("error when taking the sweep lock", "err", err.Error())
+		return
+	}
+	if locked {
+		s.ExpireIdlePets(timeouts, defaultTimeout)
+	}
+}
@@ -0,0 +1,76 @@
+package announcers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ovechkin-dm/mockio/mock"
+	"github.com/streampets/backend/services"
+	"github.com/streampets/backend/twitch"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpireIdlePets(t *testing.T) {
+	channelId := twitch.Id("channel id")
+	pet := services.Pet{UserId: "user id", Username: "username", Image: "image"}
+
+	t.Run("idle pets are removed and announced as parting", func(t *testing.T) {
+		mock.SetUp(t)
+
+		now := time.Now()
+		timeoutsMock := mock.Mock[IdleTimeoutGetter]()
+		announcerMock := mock.Mock[announcer]()
+		mock.When(timeoutsMock.GetIdleTimeout(channelId)).ThenReturn(time.Duration(0), nil)
+
+		cachedAnnouncer, published := newTestCachedAnnouncer(announcerMock)
+		cachedAnnouncer.cache.now = func() time.Time { return now }
+		cachedAnnouncer.AnnounceJoin(channelId, pet)
+
+		cachedAnnouncer.cache.now = func() time.Time { return now.Add(2 * time.Minute) }
+		cachedAnnouncer.ExpireIdlePets(timeoutsMock, time.Minute)
+
+		assert.Equal(t, partAnnouncement(channelId, pet.UserId), (*published)[len(*published)-1])
+		assert.Empty(t, cachedAnnouncer.cache.pets(channelId))
+	})
+
+	t.Run("actions keep pets from expiring", func(t *testing.T) {
+		mock.SetUp(t)
+
+		now := time.Now()
+		timeoutsMock := mock.Mock[IdleTimeoutGetter]()
+		announcerMock := mock.Mock[announcer]()
+		mock.When(timeoutsMock.GetIdleTimeout(channelId)).ThenReturn(time.Duration(0), nil)
+
+		cachedAnnouncer, published := newTestCachedAnnouncer(announcerMock)
+		cachedAnnouncer.cache.now = func() time.Time { return now }
+		cachedAnnouncer.AnnounceJoin(channelId, pet)
+
+		cachedAnnouncer.cache.now = func() time.Time { return now.Add(50 * time.Second) }
+		cachedAnnouncer.AnnounceAction(channelId, pet.UserId, "action")
+
+		cachedAnnouncer.cache.now = func() time.Time { return now.Add(100 * time.Second) }
+		cachedAnnouncer.ExpireIdlePets(timeoutsMock, time.Minute)
+
+		assert.NotContains(t, *published, partAnnouncement(channelId, pet.UserId))
+		assert.Equal(t, []services.Pet{pet}, cachedAnnouncer.cache.pets(channelId))
+	})
+
+	t.Run("channel timeout overrides the default", func(t *testing.T) {
+		mock.SetUp(t)
+
+		now := time.Now()
+		timeoutsMock := mock.Mock[IdleTimeoutGetter]()
+		announcerMock := mock.Mock[announcer]()
+		mock.When(timeoutsMock.GetIdleTimeout(channelId)).ThenReturn(time.Hour, nil)
+
+		cachedAnnouncer, published := newTestCachedAnnouncer(announcerMock)
+		cachedAnnouncer.cache.now = func() time.Time { return now }
+		cachedAnnouncer.AnnounceJoin(channelId, pet)
+
+		cachedAnnouncer.cache.now = func() time.Time { return now.Add(2 * time.Minute) }
+		cachedAnnouncer.ExpireIdlePets(timeoutsMock, time.Minute)
+
+		assert.NotContains(t, *published, partAnnouncement(channelId, pet.UserId))
+		assert.Equal(t, []services.Pet{pet}, cachedAnnouncer.cache.pets(channelId))
+	})
+}
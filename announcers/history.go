@@ -0,0 +1,37 @@
+package announcers
+
+// The most recent announcements of a channel, kept so that a reconnecting
+// overlay can be sent exactly the events it missed.
+// Announcements are numbered consecutively from 1, so the announcement with
+// sequence number n lives at index (n-1) % capacity.
+type eventHistory struct {
+	events  []Announcement
+	lastSeq uint64
+}
+
+func newEventHistory(capacity int) *eventHistory {
+	return &eventHistory{events: make([]Announcement, capacity)}
+}
+
+// Records the announcement and returns its sequence number.
+func (h *eventHistory) add(a Announcement) uint64 {
+	h.lastSeq++
+	if len(h.events) > 0 {
+		h.events[(h.lastSeq-1)%uint64(len(h.events))] = a
+	}
+	return h.lastSeq
+}
+
+// Returns the announcements recorded after seq.
+// Returns false if seq is unknown or some of the announcements after it were already overwritten.
+func (h *eventHistory) since(seq uint64) ([]Announcement, bool) {
+	if seq > h.lastSeq || h.lastSeq-seq > uint64(len(h.events)) {
+		return nil, false
+	}
+
+	missed := make([]Announcement, 0, h.lastSeq-seq)
+	for n := seq + 1; n <= h.lastSeq; n++ {
+		missed = append(missed, h.events[(n-1)%uint64(len(h.events))])
+	}
+	return missed, true
+}
@@ -0,0 +1,68 @@
+package announcers
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/streampets/backend/twitch"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventHistory(t *testing.T) {
+	channelId := twitch.Id("channel id")
+	announcements := []Announcement{}
+	for i := 0; i < 5; i++ {
+		announcements = append(announcements, partAnnouncement(channelId, twitch.Id(fmt.Sprintf("user %d", i))))
+	}
+
+	t.Run("announcements are numbered from one", func(t *testing.T) {
+		history := newEventHistory(3)
+
+		assert.Equal(t, uint64(1), history.add(announcements[0]))
+		assert.Equal(t, uint64(2), history.add(announcements[1]))
+	})
+
+	t.Run("announcements after sequence number are returned in order", func(t *testing.T) {
+		history := newEventHistory(3)
+		for _, a := range announcements[:3] {
+			history.add(a)
+		}
+
+		missed, ok := history.since(1)
+
+		assert.True(t, ok)
+		assert.Equal(t, announcements[1:3], missed)
+	})
+
+	t.Run("nothing is missed when up to date", func(t *testing.T) {
+		history := newEventHistory(3)
+		history.add(announcements[0])
+
+		missed, ok := history.since(1)
+
+		assert.True(t, ok)
+		assert.Empty(t, missed)
+	})
+
+	t.Run("overwritten announcements cannot be replayed", func(t *testing.T) {
+		history := newEventHistory(3)
+		for _, a := range announcements {
+			history.add(a)
+		}
+
+		_, ok := history.since(1)
+		assert.False(t, ok)
+
+		missed, ok := history.since(2)
+		assert.True(t, ok)
+		assert.Equal(t, announcements[2:], missed)
+	})
+
+	t.Run("future sequence numbers are unknown", func(t *testing.T) {
+		history := newEventHistory(3)
+		history.add(announcements[0])
+
+		_, ok := history.since(2)
+		assert.False(t, ok)
+	})
+}
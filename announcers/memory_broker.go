@@ -0,0 +1,43 @@
+package announcers
+
+import (
+	"sync"
+	"time"
+)
+
+// A Broker that only delivers announcements within the current process.
+// This is the default when the backend runs as a single instance.
+type MemoryBroker struct {
+	mu       sync.RWMutex
+	handlers []func(Announcement)
+}
+
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{}
+}
+
+func (b *MemoryBroker) Publish(announcement Announcement) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for _, handler := range b.handlers {
+		handler(announcement)
+	}
+	return nil
+}
+
+func (b *MemoryBroker) Subscribe(handler func(Announcement)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.handlers = append(b.handlers, handler)
+}
+
+// Always takes the lock, as there are no other instances to share it with.
+func (b *MemoryBroker) Lock(name string, ttl time.Duration) (bool, error) {
+	return true, nil
+}
+
+func (b *MemoryBroker) Close() error {
+	return nil
+}
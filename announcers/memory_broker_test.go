@@ -0,0 +1,27 @@
+package announcers
+
+import (
+	"testing"
+
+	"github.com/streampets/backend/twitch"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryBroker(t *testing.T) {
+	announcement := partAnnouncement(twitch.Id("channel id"), twitch.Id("user id"))
+
+	broker := NewMemoryBroker()
+
+	received := []Announcement{}
+	broker.Subscribe(func(a Announcement) {
+		received = append(received, a)
+	})
+	broker.Subscribe(func(a Announcement) {
+		received = append(received, a)
+	})
+
+	err := broker.Publish(announcement)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []Announcement{announcement, announcement}, received)
+}
@@ -0,0 +1,240 @@
+package announcers
+
+import (
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"github.com/streampets/backend/services"
+	"github.com/streampets/backend/twitch"
+)
+
+const cacheShards = 32
+
+// A pet present on a channel's overlay.
+type Presence struct {
+	services.Pet
+	JoinedAt time.Time `json:"joinedAt"`
+	LastSeen time.Time `json:"lastSeen"`
+}
+
+// The pets currently present on each channel.
+// Channels are spread over shards so that busy channels do not contend for a single lock.
+type petCache struct {
+	shards [cacheShards]*cacheShard
+	now    func() time.Time
+}
+
+type cacheShard struct {
+	mu       sync.RWMutex
+	channels cacheMap
+}
+
+func newPetCache() *petCache {
+	cache := &petCache{now: time.Now}
+	for i := range cache.shards {
+		cache.shards[i] = &cacheShard{channels: make(cacheMap)}
+	}
+	return cache
+}
+
+func (c *petCache) shard(channelId twitch.Id) *cacheShard {
+	hash := fnv.New32a()
+	hash.Write([]byte(channelId))
+	return c.shards[hash.Sum32()%cacheShards]
+}
+
+func (c *petCache) add(channelId twitch.Id, pet services.Pet) {
+	now := c.now()
+
+	shard := c.shard(channelId)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	pets, ok := shard.channels[channelId]
+	if !ok {
+		pets = make(petMap)
+		shard.channels[channelId] = pets
+	}
+
+	presence, ok := pets[pet.UserId]
+	if !ok {
+		presence.JoinedAt = now
+	}
+	presence.Pet = pet
+	presence.LastSeen = now
+	pets[pet.UserId] = presence
+}
+
+// Returns false if nothing is cached for the channel.
+func (c *petCache) remove(channelId, userId twitch.Id) bool {
+	shard := c.shard(channelId)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	pets, ok := shard.channels[channelId]
+	if !ok {
+		return false
+	}
+
+	delete(pets, userId)
+	if len(pets) == 0 {
+		delete(shard.channels, channelId)
+	}
+	return true
+}
+
+// Returns false if the pet is not cached.
+func (c *petCache) update(channelId, userId twitch.Id, image string) bool {
+	now := c.now()
+
+	shard := c.shard(channelId)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	presence, ok := shard.channels[channelId][userId]
+	if !ok {
+		return false
+	}
+
+	presence.Image = image
+	presence.LastSeen = now
+	shard.channels[channelId][userId] = presence
+	return true
+}
+
+// Returns false if the pet is not cached.
+func (c *petCache) move(channelId, userId twitch.Id, position services.Position) bool {
+	shard := c.shard(channelId)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	presence, ok := shard.channels[channelId][userId]
+	if !ok {
+		return false
+	}
+
+	presence.Position = &position
+	shard.channels[channelId][userId] = presence
+	return true
+}
+
+// Marks the pet as active now. Returns false if the pet is not cached.
+func (c *petCache) touch(channelId, userId twitch.Id) bool {
+	now := c.now()
+
+	shard := c.shard(channelId)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	presence, ok := shard.channels[channelId][userId]
+	if !ok {
+		return false
+	}
+
+	presence.LastSeen = now
+	shard.channels[channelId][userId] = presence
+	return true
+}
+
+// Removes the channel's pets that have not been seen since the cutoff and returns their user ids.
+func (c *petCache) expire(channelId twitch.Id, cutoff time.Time) []twitch.Id {
+	shard := c.shard(channelId)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	expired := []twitch.Id{}
+	for userId, presence := range shard.channels[channelId] {
+		if presence.LastSeen.Before(cutoff) {
+			delete(shard.channels[channelId], userId)
+			expired = append(expired, userId)
+		}
+	}
+
+	if len(shard.channels[channelId]) == 0 {
+		delete(shard.channels, channelId)
+	}
+	return expired
+}
+
+// Returns the ids of the channels with cached pets.
+func (c *petCache) channelIds() []twitch.Id {
+	channelIds := []twitch.Id{}
+	for _, shard := range c.shards {
+		shard.mu.RLock()
+		for channelId := range shard.channels {
+			channelIds = append(channelIds, channelId)
+		}
+		shard.mu.RUnlock()
+	}
+	return channelIds
+}
+
+// Puts a previously cached pet back, keeping its join and last seen times.
+func (c *petCache) restore(channelId twitch.Id, presence Presence) {
+	shard := c.shard(channelId)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	pets, ok := shard.channels[channelId]
+	if !ok {
+		pets = make(petMap)
+		shard.channels[channelId] = pets
+	}
+	pets[presence.UserId] = presence
+}
+
+// Returns a copy of the pets cached for the channel.
+func (c *petCache) pets(channelId twitch.Id) []services.Pet {
+	shard := c.shard(channelId)
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
+
+	pets := make([]services.Pet, 0, len(shard.channels[channelId]))
+	for _, presence := range shard.channels[channelId] {
+		pets = append(pets, presence.Pet)
+	}
+	return pets
+}
+
+func (c *petCache) has(channelId, userId twitch.Id) bool {
+	shard := c.shard(channelId)
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
+
+	_, ok := shard.channels[channelId][userId]
+	return ok
+}
+
+// Returns a copy of the channel's pets along with when they joined and were last seen.
+func (c *petCache) presence(channelId twitch.Id) []Presence {
+	shard := c.shard(channelId)
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
+
+	presence := make([]Presence, 0, len(shard.channels[channelId]))
+	for _, p := range shard.channels[channelId] {
+		presence = append(presence, p)
+	}
+	return presence
+}
+
+// Calls fn with a copy of every cached pet, one shard at a time.
+func (c *petCache) each(fn func(channelId twitch.Id, presence Presence)) {
+	for _, shard := range c.shards {
+		shard.mu.RLock()
+		presences := []Presence{}
+		channelIds := []twitch.Id{}
+		for channelId, pets := range shard.channels {
+			for _, presence := range pets {
+				presences = append(presences, presence)
+				channelIds = append(channelIds, channelId)
+			}
+		}
+		shard.mu.RUnlock()
+
+		for i, presence := range presences {
+			fn(channelIds[i], presence)
+		}
+	}
+}
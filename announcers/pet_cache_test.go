@@ -0,0 +1,116 @@
+package announcers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/streampets/backend/services"
+	"github.com/streampets/backend/twitch"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPetCache(t *testing.T) {
+	channelId := twitch.Id("channel id")
+	userId := twitch.Id("user id")
+
+	t.Run("added pet is returned for its channel only", func(t *testing.T) {
+		pet := services.Pet{UserId: userId}
+
+		cache := newPetCache()
+		cache.add(channelId, pet)
+
+		assert.Equal(t, []services.Pet{pet}, cache.pets(channelId))
+		assert.Empty(t, cache.pets(twitch.Id("other channel id")))
+	})
+
+	t.Run("removed pet is no longer returned", func(t *testing.T) {
+		cache := newPetCache()
+		cache.add(channelId, services.Pet{UserId: userId})
+
+		assert.True(t, cache.remove(channelId, userId))
+		assert.Empty(t, cache.pets(channelId))
+		assert.False(t, cache.remove(channelId, userId))
+	})
+
+	t.Run("update changes the image of a cached pet", func(t *testing.T) {
+		cache := newPetCache()
+		cache.add(channelId, services.Pet{UserId: userId, Image: "image"})
+
+		assert.True(t, cache.update(channelId, userId, "new image"))
+		assert.Equal(t, []services.Pet{{UserId: userId, Image: "new image"}}, cache.pets(channelId))
+	})
+
+	t.Run("update ignores pets that are not cached", func(t *testing.T) {
+		cache := newPetCache()
+
+		assert.False(t, cache.update(channelId, userId, "image"))
+		assert.Empty(t, cache.pets(channelId))
+	})
+}
+
+func TestPetCacheMove(t *testing.T) {
+	channelId := twitch.Id("channel id")
+	userId := twitch.Id("user id")
+	position := services.Position{X: 1, Y: 2}
+
+	t.Run("move sets the position of a cached pet", func(t *testing.T) {
+		cache := newPetCache()
+		cache.add(channelId, services.Pet{UserId: userId})
+
+		assert.True(t, cache.move(channelId, userId, position))
+		assert.Equal(t, []services.Pet{{UserId: userId, Position: &position}}, cache.pets(channelId))
+	})
+
+	t.Run("move ignores pets that are not cached", func(t *testing.T) {
+		cache := newPetCache()
+
+		assert.False(t, cache.move(channelId, userId, position))
+		assert.Empty(t, cache.pets(channelId))
+	})
+}
+
+func TestPetCacheTimes(t *testing.T) {
+	channelId := twitch.Id("channel id")
+	userId := twitch.Id("user id")
+
+	joined := time.Now()
+	later := joined.Add(time.Minute)
+
+	cache := newPetCache()
+	cache.now = func() time.Time { return joined }
+	cache.add(channelId, services.Pet{UserId: userId})
+
+	cache.now = func() time.Time { return later }
+	cache.update(channelId, userId, "image")
+
+	presences := []Presence{}
+	cache.each(func(_ twitch.Id, presence Presence) {
+		presences = append(presences, presence)
+	})
+
+	assert.Len(t, presences, 1)
+	assert.Equal(t, joined, presences[0].JoinedAt)
+	assert.Equal(t, later, presences[0].LastSeen)
+}
+
+func TestPetCacheExpire(t *testing.T) {
+	now := time.Now()
+	channelId := twitch.Id("channel id")
+	stale := services.Pet{UserId: "stale", Username: "stale", Image: "image"}
+	fresh := services.Pet{UserId: "fresh", Username: "fresh", Image: "image"}
+
+	cache := newPetCache()
+	cache.now = func() time.Time { return now.Add(-time.Hour) }
+	cache.add(channelId, stale)
+	cache.now = func() time.Time { return now }
+	cache.add(channelId, fresh)
+
+	expired := cache.expire(channelId, now.Add(-time.Minute))
+
+	assert.Equal(t, []twitch.Id{stale.UserId}, expired)
+	assert.Equal(t, []services.Pet{fresh}, cache.pets(channelId))
+	assert.Equal(t, []twitch.Id{channelId}, cache.channelIds())
+
+	assert.Equal(t, []twitch.Id{fresh.UserId}, cache.expire(channelId, now.Add(time.Minute)))
+	assert.Empty(t, cache.channelIds())
+}
@@ -0,0 +1,86 @@
+package announcers
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/streampets/backend/models"
+	"github.com/streampets/backend/services"
+	"github.com/streampets/backend/twitch"
+)
+
+// Somewhere to keep the cached pets while the backend restarts.
+type PresenceStore interface {
+	SavePresence(pets []models.PetPresence) error
+	LoadPresence(seenAfter time.Time) ([]models.PetPresence, error)
+}
+
+// Writes every cached pet to the store, replacing the stored pets of their channels.
+func (s *CachedAnnouncerService) SavePresence(store PresenceStore) error {
+	pets := []models.PetPresence{}
+	s.cache.each(func(channelId twitch.Id, presence Presence) {
+		pet := models.PetPresence{
+			ChannelId: channelId,
+			UserId:    presence.UserId,
+			Username:  presence.Username,
+			Image:     presence.Image,
+			JoinedAt:  presence.JoinedAt,
+			LastSeen:  presence.LastSeen,
+		}
+		if presence.Position != nil {
+			pet.PositionX = &presence.Position.X
+			pet.PositionY = &presence.Position.Y
+		}
+		pets = append(pets, pet)
+	})
+
+	return store.SavePresence(pets)
+}
+
+// Fills the cache from the store. Pets not seen within maxAge are left out so
+// that viewers who left while the backend was down are not brought back.
+func (s *CachedAnnouncerService) RestorePresence(store PresenceStore, maxAge time.Duration) error {
+	pets, err := store.LoadPresence(s.cache.now().Add(-maxAge))
+	if err != nil {
+		return err
+	}
+
+	for _, pet := range pets {
+		presence := Presence{
+			Pet: services.Pet{
+				UserId:   pet.UserId,
+				Username: pet.Username,
+				Image:    pet.Image,
+			},
+			JoinedAt: pet.JoinedAt,
+			LastSeen: pet.LastSeen,
+		}
+		if pet.PositionX != nil && pet.PositionY != nil {
+			presence.Position = &services.Position{X: *pet.PositionX, Y: *pet.PositionY}
+		}
+		s.cache.restore(pet.ChannelId, presence)
+	}
+
+	return nil
+}
+
+// Saves the cache every interval until ctx is done, then saves it one last time.
+func (s *CachedAnnouncerService) SnapshotPresence(ctx context.Context, store PresenceStore, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.SavePresence(store); err != nil {
+				slog.Error("error when saving pet presence", "err", err.Error())
+			}
+		case <-ctx.Done():
+			if err := s.SavePresence(store); err != nil {
+				slog.Error("error when saving pet presence", "err", err.Error())
+			}
+			return
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package announcers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ovechkin-dm/mockio/mock"
+	"github.com/streampets/backend/models"
+	"github.com/streampets/backend/services"
+	"github.com/streampets/backend/twitch"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSavePresence(t *testing.T) {
+	mock.SetUp(t)
+
+	now := time.Now()
+	channelId := twitch.Id("channel id")
+	pet := services.Pet{UserId: "user id", Username: "username", Image: "image"}
+
+	storeMock := mock.Mock[PresenceStore]()
+	announcerMock := mock.Mock[announcer]()
+
+	cachedAnnouncer, _ := newTestCachedAnnouncer(announcerMock)
+	cachedAnnouncer.cache.now = func() time.Time { return now }
+	cachedAnnouncer.AnnounceJoin(channelId, pet)
+
+	err := cachedAnnouncer.SavePresence(storeMock)
+
+	expected := []models.PetPresence{{
+		ChannelId: channelId,
+		UserId:    pet.UserId,
+		Username:  pet.Username,
+		Image:     pet.Image,
+		JoinedAt:  now,
+		LastSeen:  now,
+	}}
+
+	assert.NoError(t, err)
+	mock.Verify(storeMock, mock.Once()).SavePresence(expected)
+}
+
+func TestSavePresenceWithPosition(t *testing.T) {
+	mock.SetUp(t)
+
+	now := time.Now()
+	channelId := twitch.Id("channel id")
+	pet := services.Pet{UserId: "user id", Username: "username", Image: "image"}
+	x, y := 12.5, 40.0
+
+	storeMock := mock.Mock[PresenceStore]()
+	announcerMock := mock.Mock[announcer]()
+
+	cachedAnnouncer, _ := newTestCachedAnnouncer(announcerMock)
+	cachedAnnouncer.cache.now = func() time.Time { return now }
+	cachedAnnouncer.AnnounceJoin(channelId, pet)
+	cachedAnnouncer.ReportPosition(channelId, pet.UserId, services.Position{X: x, Y: y})
+
+	err := cachedAnnouncer.SavePresence(storeMock)
+
+	expected := []models.PetPresence{{
+		ChannelId: channelId,
+		UserId:    pet.UserId,
+		Username:  pet.Username,
+		Image:     pet.Image,
+		JoinedAt:  now,
+		LastSeen:  now,
+		PositionX: &x,
+		PositionY: &y,
+	}}
+
+	assert.NoError(t, err)
+	mock.Verify(storeMock, mock.Once()).SavePresence(expected)
+}
+
+func TestRestorePresence(t *testing.T) {
+	t.Run("restored pets are sent to new clients", func(t *testing.T) {
+		mock.SetUp(t)
+
+		now := time.Now()
+		maxAge := time.Minute
+		channelId := twitch.Id("channel id")
+		client := newClient(channelId, DefaultBufferSize)
+
+		stored := models.PetPresence{
+			ChannelId: channelId,
+			UserId:    "user id",
+			Username:  "username",
+			Image:     "image",
+			JoinedAt:  now.Add(-time.Hour),
+			LastSeen:  now.Add(-time.Second),
+		}
+
+		storeMock := mock.Mock[PresenceStore]()
+		announcerMock := mock.Mock[announcer]()
+
+		mock.When(storeMock.LoadPresence(now.Add(-maxAge))).ThenReturn([]models.PetPresence{stored}, nil)
+		mock.When(announcerMock.AddClient(channelId)).ThenReturn(client)
+
+		cachedAnnouncer, _ := newTestCachedAnnouncer(announcerMock)
+		cachedAnnouncer.cache.now = func() time.Time { return now }
+
+		err := cachedAnnouncer.RestorePresence(storeMock, maxAge)
+		assert.NoError(t, err)
+
+		cachedAnnouncer.AddClient(channelId)
+
+		expected := services.Pet{UserId: stored.UserId, Username: stored.Username, Image: stored.Image}
+		assert.Equal(t, joinAnnouncement(channelId, expected), <-client.Stream)
+	})
+
+	t.Run("restored pets keep their position", func(t *testing.T) {
+		mock.SetUp(t)
+
+		now := time.Now()
+		channelId := twitch.Id("channel id")
+		client := newClient(channelId, DefaultBufferSize)
+		x, y := 12.5, 40.0
+
+		stored := models.PetPresence{
+			ChannelId: channelId,
+			UserId:    "user id",
+			Username:  "username",
+			Image:     "image",
+			JoinedAt:  now.Add(-time.Hour),
+			LastSeen:  now.Add(-time.Second),
+			PositionX: &x,
+			PositionY: &y,
+		}
+
+		storeMock := mock.Mock[PresenceStore]()
+		announcerMock := mock.Mock[announcer]()
+
+		mock.When(storeMock.LoadPresence(mock.Any[time.Time]())).ThenReturn([]models.PetPresence{stored}, nil)
+		mock.When(announcerMock.AddClient(channelId)).ThenReturn(client)
+
+		cachedAnnouncer, _ := newTestCachedAnnouncer(announcerMock)
+		cachedAnnouncer.cache.now = func() time.Time { return now }
+
+		err := cachedAnnouncer.RestorePresence(storeMock, time.Minute)
+		assert.NoError(t, err)
+
+		cachedAnnouncer.AddClient(channelId)
+
+		expected := services.Pet{
+			UserId:   stored.UserId,
+			Username: stored.Username,
+			Image:    stored.Image,
+			Position: &services.Position{X: x, Y: y},
+		}
+		assert.Equal(t, joinAnnouncement(channelId, expected), <-client.Stream)
+	})
+
+	t.Run("error returned when store fails", func(t *testing.T) {
+		mock.SetUp(t)
+
+		storeMock := mock.Mock[PresenceStore]()
+		mock.When(storeMock.LoadPresence(mock.Any[time.Time]())).ThenReturn(nil, assert.AnError)
+
+		cachedAnnouncer, _ := newTestCachedAnnouncer(mock.Mock[announcer]())
+
+		err := cachedAnnouncer.RestorePresence(storeMock, time.Minute)
+		assert.Equal(t, assert.AnError, err)
+	})
+}
+
+func TestSnapshotPresence(t *testing.T) {
+	mock.SetUp(t)
+
+	storeMock := mock.Mock[PresenceStore]()
+	cachedAnnouncer, _ := newTestCachedAnnouncer(mock.Mock[announcer]())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// Returns straight away but still saves once on the way out.
+	cachedAnnouncer.SnapshotPresence(ctx, storeMock, time.Hour)
+
+	mock.Verify(storeMock, mock.Once()).SavePresence(mock.Any[[]models.PetPresence]())
+}
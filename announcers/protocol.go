@@ -0,0 +1,157 @@
+package announcers
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/streampets/backend/apperrors"
+	"github.com/streampets/backend/services"
+	"github.com/streampets/backend/twitch"
+)
+
+// The version of the event protocol an overlay speaks.
+//
+// Version 1 is the original protocol. Event names carry the user they are
+// about and payloads are bare values:
+//
+//	JOIN            v1Pet
+//	PART            user id
+//	<action>-<id>   user id
+//	COLOR-<id>      new image
+//	heartbeat       "ping"
+//	reconnect       ReconnectPayload
+//
+// Version 2 uses the fixed event names below, and each payload is a JSON
+// object described by the matching Payload struct:
+//
+//	join       JoinPayload
+//	part       PartPayload
+//	action     ActionPayload
+//	update     UpdatePayload
+//	heartbeat  HeartbeatPayload
+//	reconnect  ReconnectPayload
+//
+// A reconnect event is the last event on a stream that the backend is about to
+// close, in both versions. Overlays should reconnect, passing the id of the last
+// event they handled.
+type ProtocolVersion int
+
+const (
+	ProtocolV1 ProtocolVersion = 1
+	ProtocolV2 ProtocolVersion = 2
+
+	// Overlays that do not ask for a version are assumed to be on the original protocol.
+	DefaultProtocol = ProtocolV1
+	LatestProtocol  = ProtocolV2
+)
+
+var ErrUnknownProtocolVersion = apperrors.New(apperrors.Invalid, "unknown_protocol_version", "unknown protocol version")
+
+// Parses the version an overlay asked for. Versions newer than the latest
+// are answered with the latest, so overlays can be released before the backend.
+func ParseProtocolVersion(value string) (ProtocolVersion, error) {
+	if value == "" {
+		return DefaultProtocol, nil
+	}
+
+	version, err := strconv.Atoi(value)
+	if err != nil || version < int(ProtocolV1) {
+		return 0, fmt.Errorf("%w: %q", ErrUnknownProtocolVersion, value)
+	}
+
+	return min(ProtocolVersion(version), LatestProtocol), nil
+}
+
+const (
+	JoinEvent      string = "join"
+	PartEvent      string = "part"
+	ActionEvent    string = "action"
+	UpdateEvent    string = "update"
+	HeartbeatEvent string = "heartbeat"
+	ReconnectEvent string = "reconnect"
+)
+
+type JoinPayload struct {
+	Pet services.Pet `json:"pet"`
+}
+
+type PartPayload struct {
+	UserId twitch.Id `json:"userId"`
+}
+
+type ActionPayload struct {
+	UserId twitch.Id `json:"userId"`
+	Action string    `json:"action"`
+}
+
+// Sent when a pet's appearance changes.
+type UpdatePayload struct {
+	UserId twitch.Id `json:"userId"`
+	Image  string    `json:"color"`
+}
+
+type HeartbeatPayload struct {
+	Time time.Time `json:"time"`
+}
+
+type ReconnectPayload struct {
+	Reason string `json:"reason"`
+}
+
+// Shared between backend instances so they all know where overlays placed
+// pets. Never sent to overlays.
+const positionEvent string = "position"
+
+type positionPayload struct {
+	UserId   twitch.Id         `json:"userId"`
+	Position services.Position `json:"position"`
+}
+
+// Shared between backend instances so each disconnects the overlays connected
+// to it with an overlay id that was revoked. Never sent to overlays.
+const OverlayRevokedEvent string = "overlay_revoked"
+
+type OverlayRevokedPayload struct {
+	ChannelId twitch.Id `json:"channelId"`
+	OverlayId uuid.UUID `json:"overlayId"`
+}
+
+// The pet as version 1 overlays know it, from before pets had positions.
+type v1Pet struct {
+	UserId   twitch.Id `json:"userId"`
+	Username string    `json:"username"`
+	Image    string    `json:"color"`
+}
+
+// Sent when the backend instance is shutting down.
+const ShutdownReason string = "shutdown"
+
+// Returns the event name and data to send to an overlay speaking the given version.
+func (a Announcement) Encode(version ProtocolVersion) (string, interface{}) {
+	if version >= ProtocolV2 {
+		return a.Event, a.Message
+	}
+
+	switch payload := a.Message.(type) {
+	case JoinPayload:
+		return "JOIN", v1Pet{UserId: payload.Pet.UserId, Username: payload.Pet.Username, Image: payload.Pet.Image}
+	case PartPayload:
+		return "PART", payload.UserId
+	case ActionPayload:
+		return fmt.Sprintf("%s-%s", payload.Action, payload.UserId), payload.UserId
+	case UpdatePayload:
+		return fmt.Sprintf("COLOR-%s", payload.UserId), payload.Image
+	default:
+		return a.Event, a.Message
+	}
+}
+
+// Returns the event name and data of a heartbeat sent at the given time.
+func EncodeHeartbeat(version ProtocolVersion, now time.Time) (string, interface{}) {
+	if version >= ProtocolV2 {
+		return HeartbeatEvent, HeartbeatPayload{Time: now}
+	}
+	return HeartbeatEvent, "ping"
+}
@@ -0,0 +1,100 @@
+package announcers
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/streampets/backend/services"
+	"github.com/streampets/backend/twitch"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseProtocolVersion(t *testing.T) {
+	tests := map[string]ProtocolVersion{
+		"":  DefaultProtocol,
+		"1": ProtocolV1,
+		"2": ProtocolV2,
+		"9": LatestProtocol,
+	}
+
+	for value, expected := range tests {
+		actual, err := ParseProtocolVersion(value)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, actual)
+	}
+
+	for _, value := range []string{"0", "-1", "two"} {
+		_, err := ParseProtocolVersion(value)
+		assert.ErrorIs(t, err, ErrUnknownProtocolVersion)
+	}
+}
+
+func TestEncodeAnnouncement(t *testing.T) {
+	channelId := twitch.Id("channel id")
+	userId := twitch.Id("user id")
+	pet := services.Pet{UserId: userId, Username: "username", Image: "image"}
+
+	tests := map[string]struct {
+		announcement Announcement
+		v1Event      string
+		v1Data       interface{}
+		v2Event      string
+		v2Data       interface{}
+	}{
+		"join": {
+			joinAnnouncement(channelId, pet),
+			"JOIN", v1Pet{UserId: userId, Username: "username", Image: "image"},
+			JoinEvent, JoinPayload{Pet: pet},
+		},
+		"part": {
+			partAnnouncement(channelId, userId),
+			"PART", userId,
+			PartEvent, PartPayload{UserId: userId},
+		},
+		"action": {
+			actionAnnouncement(channelId, userId, "wave"),
+			"wave-user id", userId,
+			ActionEvent, ActionPayload{UserId: userId, Action: "wave"},
+		},
+		"update": {
+			updateAnnouncement(channelId, userId, "image"),
+			"COLOR-user id", "image",
+			UpdateEvent, UpdatePayload{UserId: userId, Image: "image"},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			event, data := test.announcement.Encode(ProtocolV1)
+			assert.Equal(t, test.v1Event, event)
+			assert.Equal(t, test.v1Data, data)
+
+			event, data = test.announcement.Encode(ProtocolV2)
+			assert.Equal(t, test.v2Event, event)
+			assert.Equal(t, test.v2Data, data)
+		})
+	}
+}
+
+func TestEncodeV1JoinWithoutPosition(t *testing.T) {
+	pet := services.Pet{UserId: "user id", Username: "username", Image: "image", Position: &services.Position{X: 1, Y: 2}}
+
+	_, data := joinAnnouncement(twitch.Id("channel id"), pet).Encode(ProtocolV1)
+	encoded, err := json.Marshal(data)
+
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"userId":"user id","username":"username","color":"image"}`, string(encoded))
+}
+
+func TestEncodeHeartbeat(t *testing.T) {
+	now := time.Now()
+
+	event, data := EncodeHeartbeat(ProtocolV1, now)
+	assert.Equal(t, HeartbeatEvent, event)
+	assert.Equal(t, "ping", data)
+
+	event, data = EncodeHeartbeat(ProtocolV2, now)
+	assert.Equal(t, HeartbeatEvent, event)
+	assert.Equal(t, HeartbeatPayload{Time: now}, data)
+}
@@ -0,0 +1,83 @@
+package announcers
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const redisChannel = "streampets:announcements"
+const redisLockPrefix = "streampets:lock:"
+
+// A Broker backed by Redis pub/sub, so announcements posted to one backend
+// instance reach overlays connected to any other instance.
+type RedisBroker struct {
+	client *redis.Client
+
+	mu   sync.Mutex
+	subs []*redis.PubSub
+}
+
+func NewRedisBroker(client *redis.Client) *RedisBroker {
+	return &RedisBroker{client: client}
+}
+
+func (b *RedisBroker) Publish(announcement Announcement) error {
+	payload, err := encodeAnnouncement(announcement)
+	if err != nil {
+		return err
+	}
+
+	return b.client.Publish(context.Background(), redisChannel, payload).Err()
+}
+
+func (b *RedisBroker) Subscribe(handler func(Announcement)) {
+	ctx := context.Background()
+	pubsub := b.client.Subscribe(ctx, redisChannel)
+
+	// Wait for the subscription to be confirmed so nothing published after
+	// Subscribe returns is missed. If Redis is unreachable the client keeps
+	// retrying in the background.
+	if _, err := pubsub.Receive(ctx); err != nil {
+		slog.Error("could not confirm redis subscription", "err", err.Error())
+	}
+
+	b.mu.Lock()
+	b.subs = append(b.subs, pubsub)
+	b.mu.Unlock()
+
+	go func() {
+		for msg := range pubsub.Channel() {
+			announcement, err := decodeAnnouncement([]byte(msg.Payload))
+			if err != nil {
+				slog.Error("could not decode announcement", "err", err.Error())
+				continue
+			}
+			handler(announcement)
+		}
+	}()
+}
+
+// Takes the lock with SET NX, so only one instance holds it until it expires.
+// Locks are not released early, which keeps instances whose timers fire a
+// little later from repeating the work.
+func (b *RedisBroker) Lock(name string, ttl time.Duration) (bool, error) {
+	return b.client.SetNX(context.Background(), redisLockPrefix+name, 1, ttl).Result()
+}
+
+func (b *RedisBroker) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, pubsub := range b.subs {
+		if err := pubsub.Close(); err != nil {
+			return err
+		}
+	}
+	b.subs = nil
+
+	return b.client.Close()
+}
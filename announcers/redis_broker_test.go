@@ -0,0 +1,208 @@
+package announcers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/ovechkin-dm/mockio/mock"
+	"github.com/redis/go-redis/v9"
+	"github.com/streampets/backend/services"
+	"github.com/streampets/backend/twitch"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestRedisBroker(t *testing.T, server *miniredis.Miniredis) *RedisBroker {
+	broker := NewRedisBroker(redis.NewClient(&redis.Options{Addr: server.Addr()}))
+	t.Cleanup(func() { broker.Close() })
+	return broker
+}
+
+func TestRedisBroker(t *testing.T) {
+	t.Run("announcement is delivered to every subscriber", func(t *testing.T) {
+		server := miniredis.RunT(t)
+
+		publisher := newTestRedisBroker(t, server)
+		subscriberOne := newTestRedisBroker(t, server)
+		subscriberTwo := newTestRedisBroker(t, server)
+
+		receivedOne := make(chan Announcement, 1)
+		subscriberOne.Subscribe(func(a Announcement) { receivedOne <- a })
+
+		receivedTwo := make(chan Announcement, 1)
+		subscriberTwo.Subscribe(func(a Announcement) { receivedTwo <- a })
+
+		expected := joinAnnouncement(twitch.Id("channel id"), services.Pet{UserId: "user id"})
+		assert.NoError(t, publisher.Publish(expected))
+
+		for _, received := range []chan Announcement{receivedOne, receivedTwo} {
+			select {
+			case actual := <-received:
+				assert.Equal(t, expected, actual)
+			case <-time.After(time.Second):
+				t.Fatal("announcement was not delivered")
+			}
+		}
+	})
+
+	t.Run("lock is held by one broker until it expires", func(t *testing.T) {
+		server := miniredis.RunT(t)
+		brokerOne := newTestRedisBroker(t, server)
+		brokerTwo := newTestRedisBroker(t, server)
+
+		locked, err := brokerOne.Lock("lock", time.Minute)
+		assert.NoError(t, err)
+		assert.True(t, locked)
+
+		locked, err = brokerTwo.Lock("lock", time.Minute)
+		assert.NoError(t, err)
+		assert.False(t, locked)
+
+		server.FastForward(time.Minute)
+
+		locked, err = brokerTwo.Lock("lock", time.Minute)
+		assert.NoError(t, err)
+		assert.True(t, locked)
+	})
+
+	t.Run("publish fails when redis is unreachable", func(t *testing.T) {
+		server := miniredis.RunT(t)
+		broker := newTestRedisBroker(t, server)
+		server.Close()
+
+		err := broker.Publish(partAnnouncement(twitch.Id("channel id"), twitch.Id("user id")))
+
+		assert.Error(t, err)
+	})
+}
+
+func TestAnnouncerAcrossReplicas(t *testing.T) {
+	server := miniredis.RunT(t)
+
+	channelId := twitch.Id("channel id")
+	pet := services.Pet{UserId: "user id", Username: "username", Image: "image"}
+
+	replicaOne := NewAnnouncerService(newTestRedisBroker(t, server), DefaultBufferSize, DefaultHistorySize, Disconnect)
+	replicaTwo := NewAnnouncerService(newTestRedisBroker(t, server), DefaultBufferSize, DefaultHistorySize, Disconnect)
+
+	client := replicaTwo.AddClient(channelId)
+	replicaOne.AnnounceJoin(channelId, pet)
+
+	select {
+	case actual := <-client.Stream:
+		expected := joinAnnouncement(channelId, pet)
+		expected.Id = formatEventId(replicaTwo.instance, 1)
+		assert.Equal(t, expected, actual)
+	case <-time.After(time.Second):
+		t.Fatal("announcement did not reach the other replica")
+	}
+}
+
+func TestCachedAnnouncerAcrossReplicas(t *testing.T) {
+	server := miniredis.RunT(t)
+
+	channelId := twitch.Id("channel id")
+	pet := services.Pet{UserId: "user id", Username: "username", Image: "image"}
+
+	newReplica := func() (*AnnouncerService, *CachedAnnouncerService) {
+		broker := newTestRedisBroker(t, server)
+		announcer := NewAnnouncerService(broker, DefaultBufferSize, DefaultHistorySize, Disconnect)
+		return announcer, NewCachedAnnouncerService(announcer, broker)
+	}
+	announcerA, replicaA := newReplica()
+	_, replicaB := newReplica()
+
+	receive := func(client Client) Announcement {
+		select {
+		case a := <-client.Stream:
+			return a
+		case <-time.After(time.Second):
+			t.Fatal("announcement did not reach the other replica")
+			return Announcement{}
+		}
+	}
+
+	client := replicaA.AddClient(channelId)
+	replicaA.AnnounceJoin(channelId, pet)
+	assert.Equal(t, JoinEvent, receive(client).Event)
+
+	assert.Eventually(t, func() bool { return replicaB.HasPet(channelId, pet.UserId) }, time.Second, 10*time.Millisecond)
+
+	replicaB.ReportPosition(channelId, pet.UserId, services.Position{X: 1, Y: 2})
+	replicaB.AnnounceUpdate(channelId, pet.UserId, "new image")
+
+	expected := updateAnnouncement(channelId, pet.UserId, "new image")
+	expected.Id = formatEventId(announcerA.instance, 2)
+	assert.Equal(t, expected, receive(client))
+
+	assert.Eventually(t, func() bool {
+		presence := replicaA.GetPresence(channelId)
+		return len(presence) == 1 && presence[0].Image == "new image" && presence[0].Position != nil
+	}, time.Second, 10*time.Millisecond)
+
+	replicaB.AnnouncePart(channelId, pet.UserId)
+
+	expected = partAnnouncement(channelId, pet.UserId)
+	expected.Id = formatEventId(announcerA.instance, 3)
+	assert.Equal(t, expected, receive(client))
+
+	assert.Eventually(t, func() bool { return !replicaA.HasPet(channelId, pet.UserId) }, time.Second, 10*time.Millisecond)
+}
+
+func TestExpireIdlePetsAcrossReplicas(t *testing.T) {
+	server := miniredis.RunT(t)
+
+	channelId := twitch.Id("channel id")
+	pet := services.Pet{UserId: "user id", Username: "username", Image: "image"}
+
+	newReplica := func() *CachedAnnouncerService {
+		broker := newTestRedisBroker(t, server)
+		announcer := NewAnnouncerService(broker, DefaultBufferSize, DefaultHistorySize, Disconnect)
+		return NewCachedAnnouncerService(announcer, broker)
+	}
+	replicaA := newReplica()
+	replicaB := newReplica()
+
+	mock.SetUp(t)
+	timeoutsMock := mock.Mock[IdleTimeoutGetter]()
+	mock.When(timeoutsMock.GetIdleTimeout(channelId)).ThenReturn(time.Duration(0), nil)
+
+	parts := make(chan Announcement, 2)
+	observer := newTestRedisBroker(t, server)
+	observer.Subscribe(func(a Announcement) {
+		if a.Event == PartEvent {
+			parts <- a
+		}
+	})
+
+	replicaA.AnnounceJoin(channelId, pet)
+	assert.Eventually(t, func() bool {
+		return replicaA.HasPet(channelId, pet.UserId) && replicaB.HasPet(channelId, pet.UserId)
+	}, time.Second, 10*time.Millisecond)
+
+	later := time.Now().Add(time.Hour)
+	replicaA.cache.now = func() time.Time { return later }
+	replicaB.cache.now = func() time.Time { return later }
+
+	replicaA.sweepIdlePets(timeoutsMock, time.Minute, time.Minute)
+
+	select {
+	case part := <-parts:
+		assert.Equal(t, partAnnouncement(channelId, pet.UserId), part)
+	case <-time.After(time.Second):
+		t.Fatal("part was not announced")
+	}
+	assert.Eventually(t, func() bool { return !replicaB.HasPet(channelId, pet.UserId) }, time.Second, 10*time.Millisecond)
+
+	// Replica B sweeps as if the part had not reached it yet.
+	replicaB.cache.now = time.Now
+	replicaB.cache.add(channelId, pet)
+	replicaB.cache.now = func() time.Time { return later }
+	replicaB.sweepIdlePets(timeoutsMock, time.Minute, time.Minute)
+
+	select {
+	case <-parts:
+		t.Fatal("part was announced by both replicas")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -1,13 +1,22 @@
 package announcers
 
 import (
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
+	"sync"
 
+	"github.com/google/uuid"
 	"github.com/streampets/backend/services"
 	"github.com/streampets/backend/twitch"
 )
 
 type Announcement struct {
+	// Identifies the announcement within its channel, empty for announcements replayed from the cache.
+	Id string
+	// One of the version 2 event names, with Message holding the matching payload.
+	// Use Encode to get what an overlay on an older version expects.
 	Event     string
 	Message   interface{}
 	channelId twitch.Id
@@ -16,13 +25,139 @@ type Announcement struct {
 type Client struct {
 	Stream    chan Announcement
 	channelId twitch.Id
+	state     *clientState
 }
 
-func newClient(channelId twitch.Id) Client {
-	return Client{channelId: channelId, Stream: make(chan Announcement)}
+// Guards Stream so it is never sent on after being closed.
+// Senders hold a read lock, closing takes the write lock once done has
+// released any sender blocked on a full buffer.
+type clientState struct {
+	mu     sync.RWMutex
+	once   sync.Once
+	done   chan struct{}
+	closed bool
 }
 
-type petMap = map[twitch.Id]services.Pet
+func newClient(channelId twitch.Id, bufferSize int) Client {
+	return Client{
+		channelId: channelId,
+		Stream:    make(chan Announcement, bufferSize),
+		state:     &clientState{done: make(chan struct{})},
+	}
+}
+
+// Blocks until the announcement is queued or the client is closed.
+// Returns false if the client was closed.
+func (c Client) send(a Announcement) bool {
+	c.state.mu.RLock()
+	defer c.state.mu.RUnlock()
+
+	if c.state.closed {
+		return false
+	}
+
+	select {
+	case c.Stream <- a:
+		return true
+	case <-c.state.done:
+		return false
+	}
+}
+
+// Queues the announcement without blocking, applying the overflow policy when the buffer is full.
+// Returns false when the announcement, or an older one, had to be dropped.
+func (c Client) offer(a Announcement, overflow OverflowPolicy) bool {
+	c.state.mu.RLock()
+	defer c.state.mu.RUnlock()
+
+	if c.state.closed {
+		return true
+	}
+
+	select {
+	case c.Stream <- a:
+		return true
+	default:
+	}
+
+	if overflow == DropOldest {
+		select {
+		case <-c.Stream:
+		default:
+		}
+
+		select {
+		case c.Stream <- a:
+		default:
+		}
+	}
+
+	return false
+}
+
+func (c Client) close() {
+	c.state.once.Do(func() {
+		close(c.state.done)
+
+		c.state.mu.Lock()
+		defer c.state.mu.Unlock()
+
+		c.state.closed = true
+		close(c.Stream)
+	})
+}
+
+// Event ids combine an id for the running instance with a per-channel sequence
+// number, so ids issued before a restart or by another instance are never
+// mistaken for ones this instance knows about.
+func formatEventId(instance string, seq uint64) string {
+	return fmt.Sprintf("%s-%d", instance, seq)
+}
+
+func parseEventId(eventId string) (instance string, seq uint64, ok bool) {
+	instance, seqString, found := strings.Cut(eventId, "-")
+	if !found {
+		return "", 0, false
+	}
+
+	seq, err := strconv.ParseUint(seqString, 10, 64)
+	if err != nil {
+		return "", 0, false
+	}
+
+	return instance, seq, true
+}
+
+// The number of announcements queued for a client before its OverflowPolicy applies.
+const DefaultBufferSize = 64
+
+// The number of announcements kept per channel for overlays that reconnect.
+const DefaultHistorySize = 64
+
+// Decides what happens to a client whose buffer is full.
+type OverflowPolicy string
+
+const (
+	// Discard the oldest queued announcement to make room for the new one.
+	DropOldest OverflowPolicy = "drop-oldest"
+	// Discard the new announcement.
+	DropNewest OverflowPolicy = "drop-newest"
+	// Close the client's stream so the overlay reconnects and is sent the cached pets.
+	Disconnect OverflowPolicy = "disconnect"
+)
+
+var ErrUnknownOverflowPolicy = errors.New("unknown overflow policy")
+
+func ParseOverflowPolicy(value string) (OverflowPolicy, error) {
+	switch policy := OverflowPolicy(value); policy {
+	case DropOldest, DropNewest, Disconnect:
+		return policy, nil
+	default:
+		return "", ErrUnknownOverflowPolicy
+	}
+}
+
+type petMap = map[twitch.Id]Presence
 type cacheMap = map[twitch.Id]petMap
 
 func newAnnouncement(
@@ -38,19 +173,29 @@ func newAnnouncement(
 }
 
 func joinAnnouncement(channelId twitch.Id, pet services.Pet) Announcement {
-	return newAnnouncement(channelId, "JOIN", pet)
+	return newAnnouncement(channelId, JoinEvent, JoinPayload{Pet: pet})
 }
 
 func partAnnouncement(channelId, userId twitch.Id) Announcement {
-	return newAnnouncement(channelId, "PART", userId)
+	return newAnnouncement(channelId, PartEvent, PartPayload{UserId: userId})
 }
 
 func actionAnnouncement(channelId, userId twitch.Id, action string) Announcement {
-	event := fmt.Sprintf("%s-%s", action, userId)
-	return newAnnouncement(channelId, event, userId)
+	return newAnnouncement(channelId, ActionEvent, ActionPayload{UserId: userId, Action: action})
+}
+
+func reconnectAnnouncement(channelId twitch.Id) Announcement {
+	return newAnnouncement(channelId, ReconnectEvent, ReconnectPayload{Reason: ShutdownReason})
 }
 
 func updateAnnouncement(channelId, userId twitch.Id, image string) Announcement {
-	event := fmt.Sprintf("COLOR-%s", userId)
-	return newAnnouncement(channelId, event, image)
+	return newAnnouncement(channelId, UpdateEvent, UpdatePayload{UserId: userId, Image: image})
+}
+
+func positionAnnouncement(channelId, userId twitch.Id, position services.Position) Announcement {
+	return newAnnouncement(channelId, positionEvent, positionPayload{UserId: userId, Position: position})
+}
+
+func OverlayRevokedAnnouncement(channelId twitch.Id, overlayId uuid.UUID) Announcement {
+	return newAnnouncement(channelId, OverlayRevokedEvent, OverlayRevokedPayload{ChannelId: channelId, OverlayId: overlayId})
 }
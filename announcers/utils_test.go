@@ -2,6 +2,7 @@ package announcers
 
 import (
 	"testing"
+	"time"
 
 	"github.com/streampets/backend/services"
 	"github.com/streampets/backend/twitch"
@@ -15,8 +16,8 @@ func TestJoinAnnouncement(t *testing.T) {
 	actual := joinAnnouncement(channelId, pet)
 	expected := Announcement{
 		channelId: channelId,
-		Event:     "JOIN",
-		Message:   pet,
+		Event:     JoinEvent,
+		Message:   JoinPayload{Pet: pet},
 	}
 
 	assert.Equal(t, expected, actual)
@@ -29,8 +30,8 @@ func TestPartAnnouncement(t *testing.T) {
 	actual := partAnnouncement(channelId, userId)
 	expected := Announcement{
 		channelId: channelId,
-		Event:     "PART",
-		Message:   userId,
+		Event:     PartEvent,
+		Message:   PartPayload{UserId: userId},
 	}
 
 	assert.Equal(t, expected, actual)
@@ -44,8 +45,8 @@ func TestActionAnnouncement(t *testing.T) {
 	actual := actionAnnouncement(channelId, userId, action)
 	expected := Announcement{
 		channelId: channelId,
-		Event:     "action-user id",
-		Message:   userId,
+		Event:     ActionEvent,
+		Message:   ActionPayload{UserId: userId, Action: action},
 	}
 
 	assert.Equal(t, expected, actual)
@@ -59,9 +60,62 @@ func TestUpdateAnnouncement(t *testing.T) {
 	actual := updateAnnouncement(channelId, userId, image)
 	expected := Announcement{
 		channelId: channelId,
-		Event:     "COLOR-user id",
-		Message:   image,
+		Event:     UpdateEvent,
+		Message:   UpdatePayload{UserId: userId, Image: image},
 	}
 
 	assert.Equal(t, expected, actual)
 }
+
+func TestParseOverflowPolicy(t *testing.T) {
+	for _, expected := range []OverflowPolicy{DropOldest, DropNewest, Disconnect} {
+		actual, err := ParseOverflowPolicy(string(expected))
+		assert.NoError(t, err)
+		assert.Equal(t, expected, actual)
+	}
+
+	_, err := ParseOverflowPolicy("unknown")
+	assert.Equal(t, ErrUnknownOverflowPolicy, err)
+}
+
+func TestClientClose(t *testing.T) {
+	t.Run("send fails once the client is closed", func(t *testing.T) {
+		client := newClient(twitch.Id("channel id"), 1)
+		client.close()
+
+		assert.False(t, client.send(partAnnouncement("channel id", "user id")))
+		assert.True(t, client.offer(partAnnouncement("channel id", "user id"), DropNewest))
+	})
+
+	t.Run("close releases a send blocked on a full buffer", func(t *testing.T) {
+		client := newClient(twitch.Id("channel id"), 0)
+
+		sent := make(chan bool)
+		go func() {
+			sent <- client.send(partAnnouncement("channel id", "user id"))
+		}()
+
+		client.close()
+		client.close()
+
+		select {
+		case ok := <-sent:
+			assert.False(t, ok)
+		case <-time.After(time.Second):
+			t.Fatal("send was not released")
+		}
+	})
+}
+
+func TestParseEventId(t *testing.T) {
+	instance, seq, ok := parseEventId(formatEventId("instance", 42))
+
+	assert.True(t, ok)
+	assert.Equal(t, "instance", instance)
+	assert.Equal(t, uint64(42), seq)
+
+	for _, invalid := range []string{"", "instance", "instance-", "instance-abc"} {
+		_, _, ok := parseEventId(invalid)
+		assert.False(t, ok, invalid)
+	}
+}
@@ -0,0 +1,93 @@
+// Package apperrors describes failures in terms that clients can act on.
+// Services and repositories return these errors and controllers map their
+// kind to an HTTP status.
+package apperrors
+
+import (
+	"errors"
+)
+
+// The kind of failure, which decides the HTTP status.
+type Kind string
+
+const (
+	// The request is malformed. Retrying it will not help.
+	Invalid Kind = "invalid"
+	// The caller's credentials are missing or not valid.
+	Unauthorized Kind = "unauthorized"
+	// The caller is known but may not do this.
+	Forbidden Kind = "forbidden"
+	NotFound  Kind = "not_found"
+	// The request conflicts with the current state, for example a duplicate.
+	Conflict Kind = "conflict"
+	// A dependency such as the database could not be reached. Retrying may help.
+	Unavailable Kind = "unavailable"
+	// Anything else, which is a bug.
+	Internal Kind = "internal"
+)
+
+// Implemented by errors which know how they should be reported to clients.
+type Coded interface {
+	error
+	Kind() Kind
+	// A stable, machine readable code such as "select_unowned_item".
+	Code() string
+	// The part of the error that is safe to show to clients.
+	Message() string
+}
+
+// An error with a kind and code, optionally wrapping the error that caused it.
+type Error struct {
+	kind    Kind
+	code    string
+	message string
+	cause   error
+}
+
+func New(kind Kind, code string, message string) *Error {
+	return &Error{kind: kind, code: code, message: message}
+}
+
+// Wraps err so that errors.Is and errors.As still find it.
+func Wrap(err error, kind Kind, code string, message string) *Error {
+	return &Error{kind: kind, code: code, message: message, cause: err}
+}
+
+func (e *Error) Error() string {
+	if e.cause == nil {
+		return e.message
+	}
+	return e.message + ": " + e.cause.Error()
+}
+
+func (e *Error) Kind() Kind {
+	return e.kind
+}
+
+func (e *Error) Code() string {
+	return e.code
+}
+
+// The message without the cause, which is safe to show to clients.
+func (e *Error) Message() string {
+	return e.message
+}
+
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+// Returns the first Coded error in err's chain.
+func As(err error) (Coded, bool) {
+	var coded Coded
+	ok := errors.As(err, &coded)
+	return coded, ok
+}
+
+// Returns the kind of the first Coded error in err's chain, or Internal if there is none.
+func KindOf(err error) Kind {
+	if coded, ok := As(err); ok {
+		return coded.Kind()
+	}
+	return Internal
+}
@@ -0,0 +1,22 @@
+package apperrors
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKindOf(t *testing.T) {
+	cause := errors.New("cause")
+	notFound := Wrap(cause, NotFound, "thing_not_found", "thing not found")
+
+	assert.Equal(t, NotFound, KindOf(notFound))
+	assert.Equal(t, NotFound, KindOf(fmt.Errorf("getting thing: %w", notFound)))
+	assert.Equal(t, Internal, KindOf(cause))
+
+	assert.ErrorIs(t, notFound, cause)
+	assert.Equal(t, "thing not found: cause", notFound.Error())
+	assert.Equal(t, "thing not found", notFound.Message())
+}
@@ -0,0 +1,70 @@
+package config
+
+import (
+	"os"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/streampets/backend/announcers"
+)
+
+// Returns a Redis backed broker when REDIS_URL is set so that several
+// instances can run behind a load balancer. Otherwise announcements stay
+// within this process.
+func CreateBroker() announcers.Broker {
+	redisUrl := os.Getenv("REDIS_URL")
+	if redisUrl == "" {
+		return announcers.NewMemoryBroker()
+	}
+
+	opts, err := redis.ParseURL(redisUrl)
+	if err != nil {
+		panic(err)
+	}
+
+	return announcers.NewRedisBroker(redis.NewClient(opts))
+}
+
+// Reads ANNOUNCER_BUFFER_SIZE, ANNOUNCER_HISTORY_SIZE and ANNOUNCER_OVERFLOW_POLICY,
+// falling back to the announcers defaults and disconnecting clients which fall behind.
+func CreateAnnouncerService(broker announcers.Broker) *announcers.AnnouncerService {
+	bufferSize := getPositiveIntEnv("ANNOUNCER_BUFFER_SIZE", announcers.DefaultBufferSize)
+	historySize := getPositiveIntEnv("ANNOUNCER_HISTORY_SIZE", announcers.DefaultHistorySize)
+
+	overflow := announcers.Disconnect
+	if value := os.Getenv("ANNOUNCER_OVERFLOW_POLICY"); value != "" {
+		policy, err := announcers.ParseOverflowPolicy(value)
+		if err != nil {
+			panic(err)
+		}
+		overflow = policy
+	}
+
+	return announcers.NewAnnouncerService(broker, bufferSize, historySize, overflow)
+}
+
+// Reads ANNOUNCER_DROPPED_REPORT_INTERVAL, how often dropped announcements
+// are logged, which defaults to 1m.
+func GetDroppedReportInterval() time.Duration {
+	return getDurationEnv("ANNOUNCER_DROPPED_REPORT_INTERVAL", time.Minute)
+}
+
+// How long a snapshotted pet stays eligible to be restored, how often snapshots
+// are taken, and when idle pets are removed.
+type PresenceConfig struct {
+	MaxAge           time.Duration
+	SnapshotInterval time.Duration
+	IdleTimeout      time.Duration
+	SweepInterval    time.Duration
+}
+
+// Reads PRESENCE_MAX_AGE, PRESENCE_SNAPSHOT_INTERVAL, PET_IDLE_TIMEOUT and
+// PET_SWEEP_INTERVAL, which default to 10m, 30s, 30m and 1m.
+func GetPresenceConfig() PresenceConfig {
+	return PresenceConfig{
+		MaxAge:           getDurationEnv("PRESENCE_MAX_AGE", 10*time.Minute),
+		SnapshotInterval: getDurationEnv("PRESENCE_SNAPSHOT_INTERVAL", 30*time.Second),
+		IdleTimeout:      getDurationEnv("PET_IDLE_TIMEOUT", 30*time.Minute),
+		SweepInterval:    getDurationEnv("PET_SWEEP_INTERVAL", time.Minute),
+	}
+}
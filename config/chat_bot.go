@@ -0,0 +1,29 @@
+package config
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/streampets/backend/twitch"
+)
+
+// Returns nil unless CHAT_BOT_LOGIN, CHAT_BOT_TOKEN and CHAT_BOT_CHANNELS are
+// all set. CHAT_BOT_CHANNELS is a comma separated list of channel names.
+func CreateChatClient(handler twitch.ChatHandler) *twitch.ChatClient {
+	login := os.Getenv("CHAT_BOT_LOGIN")
+	token := os.Getenv("CHAT_BOT_TOKEN")
+	channels := os.Getenv("CHAT_BOT_CHANNELS")
+	if login == "" || token == "" || channels == "" {
+		return nil
+	}
+
+	dial := func(ctx context.Context) (net.Conn, error) {
+		dialer := tls.Dialer{}
+		return dialer.DialContext(ctx, "tcp", twitch.ChatAddress)
+	}
+
+	return twitch.NewChatClient(dial, login, token, strings.Split(channels, ","), handler)
+}
@@ -4,11 +4,11 @@ import (
 	"fmt"
 
 	_ "github.com/lib/pq"
-	"github.com/streampets/backend/models"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
+// Connects to the database without migrating it, see the migrations package.
 func ConnectDB() *gorm.DB {
 	host := mustGetEnv("DB_HOST")
 	port := mustGetEnv("DB_PORT")
@@ -19,22 +19,10 @@ func ConnectDB() *gorm.DB {
 
 	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=%s", host, user, password, dbName, port, sslMode)
 
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{TranslateError: true})
 	if err != nil {
 		panic(err)
 	}
 
-	if err := db.AutoMigrate(
-		&models.ChannelItem{},
-		&models.Channel{},
-		&models.DefaultChannelItem{},
-		&models.Item{},
-		&models.OwnedItem{},
-		&models.SelectedItem{},
-		&models.User{},
-	); err != nil {
-		panic(err)
-	}
-
 	return db
 }
@@ -0,0 +1,20 @@
+package config
+
+import (
+	"os"
+
+	"github.com/streampets/backend/controllers"
+)
+
+// Returns nil when EVENTSUB_SECRET is not set, in which case EventSub webhooks are not accepted.
+func CreateEventSubController(
+	announcer controllers.EventAnnouncer,
+	pets controllers.PetGetter,
+) *controllers.EventSubController {
+	secret := os.Getenv("EVENTSUB_SECRET")
+	if secret == "" {
+		return nil
+	}
+
+	return controllers.NewEventSubController(announcer, pets, secret)
+}
@@ -0,0 +1,23 @@
+package config
+
+import (
+	"time"
+
+	"github.com/streampets/backend/services"
+)
+
+func CreateLoginService(oauth services.OAuthClient, tokens services.TwitchTokenStore, versions services.SessionVersionStore) *services.LoginService {
+	return services.NewLoginService(
+		oauth,
+		tokens,
+		versions,
+		mustGetEnv("OAUTH_REDIRECT_URL"),
+		[]byte(mustGetEnv("SESSION_SECRET")),
+		getDurationEnv("SESSION_TTL", 7*24*time.Hour),
+	)
+}
+
+// Where streamers are sent once they have logged in.
+func GetDashboardUrl() string {
+	return mustGetEnv("DASHBOARD_URL")
+}
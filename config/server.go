@@ -0,0 +1,27 @@
+package config
+
+import (
+	"net/http"
+	"os"
+	"time"
+)
+
+// Creates the server on PORT, or 8080 like gin does when PORT is not set.
+func CreateServer(handler http.Handler) *http.Server {
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8080"
+	}
+
+	return &http.Server{
+		Addr:              ":" + port,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+}
+
+// Reads SHUTDOWN_TIMEOUT, how long in-flight requests are given to finish
+// when the server is stopped, which defaults to 30s.
+func GetShutdownTimeout() time.Duration {
+	return getDurationEnv("SHUTDOWN_TIMEOUT", 30*time.Second)
+}
@@ -0,0 +1,34 @@
+package config
+
+import (
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/streampets/backend/storage"
+)
+
+// Stores assets in the S3 compatible bucket S3_BUCKET when it is set, and in
+// the ASSET_DIR directory otherwise.
+func CreateAssetStore() storage.Store {
+	bucket := os.Getenv("S3_BUCKET")
+	if bucket == "" {
+		return storage.NewLocalStore(getEnv("ASSET_DIR", "assets"))
+	}
+
+	client := &http.Client{Timeout: getDurationEnv("S3_TIMEOUT", 30*time.Second)}
+
+	return storage.NewS3Store(
+		client,
+		mustGetEnv("S3_ENDPOINT"),
+		bucket,
+		getEnv("S3_REGION", "us-east-1"),
+		mustGetEnv("S3_ACCESS_KEY_ID"),
+		mustGetEnv("S3_SECRET_ACCESS_KEY"),
+	)
+}
+
+// The url the '/assets' route is reached at by overlays and the extension.
+func GetAssetUrl() string {
+	return getEnv("ASSET_URL", "/assets")
+}
@@ -0,0 +1,28 @@
+package config
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/streampets/backend/twitch"
+)
+
+func CreateTwitchApi() *twitch.TwitchApi {
+	client := &http.Client{Timeout: getDurationEnv("TWITCH_TIMEOUT", 10*time.Second)}
+
+	return twitch.New(
+		client,
+		"https://id.twitch.tv",
+		"https://api.twitch.tv/helix",
+		mustGetEnv("CLIENT_ID"),
+		mustGetEnv("CLIENT_SECRET"),
+	)
+}
+
+func CreateTokenValidator(api *twitch.TwitchApi) *twitch.CachedTokenValidator {
+	return twitch.NewCachedTokenValidator(
+		api,
+		getDurationEnv("TOKEN_CACHE_TTL", 10*time.Minute),
+		getDurationEnv("TOKEN_CACHE_NEGATIVE_TTL", 30*time.Second),
+	)
+}
@@ -3,6 +3,8 @@ package config
 import (
 	"fmt"
 	"os"
+	"strconv"
+	"time"
 )
 
 func mustGetEnv(name string) string {
@@ -12,3 +14,45 @@ func mustGetEnv(name string) string {
 	}
 	return value
 }
+
+func getEnv(name string, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
+
+func getIntEnv(name string, fallback int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+
+	number, err := strconv.Atoi(value)
+	if err != nil {
+		panic(fmt.Errorf("%s is not a number: %w", name, err))
+	}
+	return number
+}
+
+// Reads a size or count, which has to be at least 1.
+func getPositiveIntEnv(name string, fallback int) int {
+	number := getIntEnv(name, fallback)
+	if number < 1 {
+		panic(fmt.Errorf("%s must be at least 1, got %d", name, number))
+	}
+	return number
+}
+
+func getDurationEnv(name string, fallback time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		panic(fmt.Errorf("%s is not a duration: %w", name, err))
+	}
+	return duration
+}
@@ -0,0 +1,52 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/streampets/backend/storage"
+)
+
+// Assets are named after their content and never change, so they can be cached for good.
+const assetCacheControl string = "public, max-age=31536000, immutable"
+
+type AssetGetter interface {
+	Get(ctx context.Context, key string) (storage.Object, error)
+}
+
+type AssetController struct {
+	Assets AssetGetter
+}
+
+func NewAssetController(assets AssetGetter) *AssetController {
+	return &AssetController{
+		Assets: assets,
+	}
+}
+
+// Serves an uploaded image or preview.
+func (c *AssetController) GetAsset(ctx *gin.Context) {
+	key := ctx.Param(AssetKey)
+	etag := `"` + key + `"`
+
+	if ctx.GetHeader("If-None-Match") == etag {
+		ctx.Header("Cache-Control", assetCacheControl)
+		ctx.Header("ETag", etag)
+		ctx.Status(http.StatusNotModified)
+		return
+	}
+
+	object, err := c.Assets.Get(ctx, key)
+	if err != nil {
+		addErrorToCtx(err, ctx)
+		return
+	}
+	defer object.Body.Close()
+
+	ctx.DataFromReader(http.StatusOK, object.Size, object.ContentType, object.Body, map[string]string{
+		"Cache-Control":          assetCacheControl,
+		"ETag":                   etag,
+		"X-Content-Type-Options": "nosniff",
+	})
+}
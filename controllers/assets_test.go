@@ -0,0 +1,79 @@
+package controllers
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ovechkin-dm/mockio/mock"
+	"github.com/streampets/backend/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetAsset(t *testing.T) {
+	setUpContext := func(key, etag string) (*gin.Context, *httptest.ResponseRecorder) {
+		gin.SetMode(gin.TestMode)
+
+		recorder := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(recorder)
+		req, _ := http.NewRequest("GET", "/assets/"+key, nil)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+
+		ctx.Request = req
+		ctx.Params = gin.Params{{Key: AssetKey, Value: key}}
+		return ctx, recorder
+	}
+
+	t.Run("asset served with cache headers", func(t *testing.T) {
+		mock.SetUp(t)
+
+		assets := mock.Mock[AssetGetter]()
+		mock.When(assets.Get(mock.Any[context.Context](), mock.Equal("abc.png"))).ThenReturn(storage.Object{
+			Body:        io.NopCloser(strings.NewReader("image")),
+			ContentType: "image/png",
+			Size:        5,
+		}, nil)
+
+		ctx, recorder := setUpContext("abc.png", "")
+		NewAssetController(assets).GetAsset(ctx)
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, "image", recorder.Body.String())
+		assert.Equal(t, "image/png", recorder.Header().Get("Content-Type"))
+		assert.Equal(t, "5", recorder.Header().Get("Content-Length"))
+		assert.Equal(t, assetCacheControl, recorder.Header().Get("Cache-Control"))
+		assert.Equal(t, `"abc.png"`, recorder.Header().Get("ETag"))
+	})
+
+	t.Run("not modified status when etag matches", func(t *testing.T) {
+		mock.SetUp(t)
+
+		assets := mock.Mock[AssetGetter]()
+
+		ctx, recorder := setUpContext("abc.png", `"abc.png"`)
+		NewAssetController(assets).GetAsset(ctx)
+		ctx.Writer.WriteHeaderNow()
+
+		assert.Equal(t, http.StatusNotModified, recorder.Code)
+		mock.Verify(assets, mock.Never()).Get(mock.Any[context.Context](), mock.AnyString())
+	})
+
+	t.Run("not found status when asset missing", func(t *testing.T) {
+		mock.SetUp(t)
+
+		assets := mock.Mock[AssetGetter]()
+		mock.When(assets.Get(mock.Any[context.Context](), mock.AnyString())).ThenReturn(storage.Object{}, storage.ErrAssetNotFound)
+
+		ctx, recorder := setUpContext("abc.png", "")
+		NewAssetController(assets).GetAsset(ctx)
+
+		assert.Equal(t, http.StatusNotFound, recorder.Code)
+		assert.Empty(t, recorder.Header().Get("Cache-Control"))
+	})
+}
@@ -0,0 +1,35 @@
+package controllers
+
+import (
+	"log/slog"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/streampets/backend/models"
+	"github.com/streampets/backend/services"
+	"github.com/streampets/backend/twitch"
+)
+
+type BotAuthenticator interface {
+	AuthenticateBot(key string, channelId twitch.Id) (models.Bot, error)
+}
+
+// Rejects requests that do not carry the API key of a bot allowed to act for
+// the channel in the path. Keys are sent as 'Authorization: Bearer <key>'.
+func BotAuth(bots BotAuthenticator) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		key, ok := strings.CutPrefix(ctx.GetHeader(Authorization), "Bearer ")
+		if !ok || key == "" {
+			slog.Debug("no bot api key present")
+			addErrorToCtx(services.ErrInvalidBotKey, ctx)
+			return
+		}
+
+		if _, err := bots.AuthenticateBot(key, twitch.Id(ctx.Param(ChannelId))); err != nil {
+			addErrorToCtx(err, ctx)
+			return
+		}
+
+		ctx.Next()
+	}
+}
@@ -0,0 +1,82 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ovechkin-dm/mockio/mock"
+	"github.com/streampets/backend/models"
+	"github.com/streampets/backend/services"
+	"github.com/streampets/backend/twitch"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBotAuth(t *testing.T) {
+	channelId := twitch.Id("channel id")
+
+	setUpContext := func(authorization string) (*gin.Context, *httptest.ResponseRecorder) {
+		gin.SetMode(gin.TestMode)
+
+		recorder := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(recorder)
+		req, _ := http.NewRequest("GET", "/channels/channel id/users", nil)
+		if authorization != "" {
+			req.Header.Set(Authorization, authorization)
+		}
+
+		ctx.Request = req
+		ctx.Params = gin.Params{{Key: ChannelId, Value: string(channelId)}}
+		return ctx, recorder
+	}
+
+	t.Run("unauthorized status when no key present", func(t *testing.T) {
+		mock.SetUp(t)
+
+		bots := mock.Mock[BotAuthenticator]()
+		ctx, recorder := setUpContext("")
+
+		BotAuth(bots)(ctx)
+
+		assert.True(t, ctx.IsAborted())
+		assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+		mock.Verify(bots, mock.Never()).AuthenticateBot(mock.AnyString(), mock.Any[twitch.Id]())
+	})
+
+	tests := map[string]struct {
+		err    error
+		status int
+	}{
+		"unauthorized status when key invalid":      {services.ErrInvalidBotKey, http.StatusUnauthorized},
+		"forbidden status when channel not allowed": {services.ErrBotNotAllowed, http.StatusForbidden},
+		"internal server error when lookup fails":   {assert.AnError, http.StatusInternalServerError},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			mock.SetUp(t)
+
+			bots := mock.Mock[BotAuthenticator]()
+			mock.When(bots.AuthenticateBot("key", channelId)).ThenReturn(models.Bot{}, test.err)
+			ctx, recorder := setUpContext("Bearer key")
+
+			BotAuth(bots)(ctx)
+
+			assert.True(t, ctx.IsAborted())
+			assert.Equal(t, test.status, recorder.Code)
+		})
+	}
+
+	t.Run("request continues when bot allowed", func(t *testing.T) {
+		mock.SetUp(t)
+
+		bots := mock.Mock[BotAuthenticator]()
+		mock.When(bots.AuthenticateBot("key", channelId)).ThenReturn(models.Bot{Name: "bot"}, nil)
+		ctx, _ := setUpContext("Bearer key")
+
+		BotAuth(bots)(ctx)
+
+		assert.False(t, ctx.IsAborted())
+	})
+}
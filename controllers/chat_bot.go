@@ -0,0 +1,109 @@
+package controllers
+
+import (
+	"log/slog"
+	"strings"
+
+	"github.com/streampets/backend/twitch"
+)
+
+// The chat command viewers use to select one of their items, as in "!color red".
+const ColorCommand string = "color"
+
+type ChatAnnouncer interface {
+	Announcer
+	HasPet(channelId, userId twitch.Id) bool
+}
+
+// Drives pets from chat read by a twitch.ChatClient, doing what an external
+// bot would otherwise do through the TwitchBotController routes.
+type ChatBot struct {
+	Announcer ChatAnnouncer
+	Items     ItemGetSetter
+	Pets      PetGetter
+}
+
+func NewChatBot(
+	announcer ChatAnnouncer,
+	items ItemGetSetter,
+	pets PetGetter,
+) *ChatBot {
+	return &ChatBot{
+		Announcer: announcer,
+		Items:     items,
+		Pets:      pets,
+	}
+}
+
+// Users are only known by name when they join, so their pet appears once
+// they have chatted and the client has learned their id.
+func (b *ChatBot) HandleChatJoin(channel twitch.ChatChannel, user twitch.ChatUser) {
+	if channel.Id == "" || user.Id == "" {
+		return
+	}
+	b.join(channel.Id, user)
+}
+
+func (b *ChatBot) HandleChatPart(channel twitch.ChatChannel, user twitch.ChatUser) {
+	if channel.Id == "" || user.Id == "" {
+		return
+	}
+	b.Announcer.AnnouncePart(channel.Id, user.Id)
+}
+
+func (b *ChatBot) HandleChatMessage(channel twitch.ChatChannel, user twitch.ChatUser, text string) {
+	if channel.Id == "" || user.Id == "" {
+		return
+	}
+
+	if !b.join(channel.Id, user) {
+		return
+	}
+
+	command, ok := strings.CutPrefix(strings.TrimSpace(text), "!")
+	if !ok {
+		return
+	}
+
+	name, args, _ := strings.Cut(command, " ")
+	switch name = strings.ToLower(name); name {
+	case "":
+		return
+	case ColorCommand:
+		b.selectItem(channel.Id, user.Id, strings.TrimSpace(args))
+	default:
+		b.Announcer.AnnounceAction(channel.Id, user.Id, name)
+	}
+}
+
+// Announces the user's pet unless it is already on the overlay.
+// Returns false if the user has no pet.
+func (b *ChatBot) join(channelId twitch.Id, user twitch.ChatUser) bool {
+	if b.Announcer.HasPet(channelId, user.Id) {
+		return true
+	}
+
+	pet, err := b.Pets.GetPet(user.Id, channelId, user.DisplayName)
+	if err != nil {
+		slog.Debug("could not get pet for chatter", "channel_id", channelId, "user_id", user.Id, "err", err.Error())
+		return false
+	}
+
+	b.Announcer.AnnounceJoin(channelId, pet)
+	return true
+}
+
+func (b *ChatBot) selectItem(channelId, userId twitch.Id, itemName string) {
+	item, err := b.Items.GetItemByName(channelId, itemName)
+	if err != nil {
+		slog.Debug("chatter selected unknown item", "channel_id", channelId, "user_id", userId, "item", itemName, "err", err.Error())
+		return
+	}
+
+	if err := b.Items.SetSelectedItem(userId, channelId, item.ItemId); err != nil {
+		slog.Debug("chatter could not select item", "channel_id", channelId, "user_id", userId, "item", itemName, "err", err.Error())
+		return
+	}
+
+	b.Announcer.AnnounceUpdate(channelId, userId, item.Image)
+}
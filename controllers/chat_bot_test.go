@@ -0,0 +1,113 @@
+package controllers
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/ovechkin-dm/mockio/mock"
+	"github.com/streampets/backend/apperrors"
+	"github.com/streampets/backend/models"
+	"github.com/streampets/backend/services"
+	"github.com/streampets/backend/twitch"
+)
+
+func TestChatBot(t *testing.T) {
+	channel := twitch.ChatChannel{Login: "channel", Id: "channel id"}
+	user := twitch.ChatUser{Login: "user", Id: "user id", DisplayName: "User"}
+	pet := services.Pet{UserId: user.Id, Username: user.DisplayName, Image: "image"}
+
+	setUpBot := func() (*ChatBot, ChatAnnouncer, ItemGetSetter, PetGetter) {
+		announcer := mock.Mock[ChatAnnouncer]()
+		items := mock.Mock[ItemGetSetter]()
+		pets := mock.Mock[PetGetter]()
+		return NewChatBot(announcer, items, pets), announcer, items, pets
+	}
+
+	t.Run("chatter without a pet on the overlay joins", func(t *testing.T) {
+		mock.SetUp(t)
+
+		bot, announcer, _, pets := setUpBot()
+		mock.When(pets.GetPet(user.Id, channel.Id, user.DisplayName)).ThenReturn(pet, nil)
+
+		bot.HandleChatMessage(channel, user, "hello")
+
+		mock.Verify(announcer, mock.Once()).AnnounceJoin(channel.Id, pet)
+		mock.Verify(announcer, mock.Never()).AnnounceAction(mock.Any[twitch.Id](), mock.Any[twitch.Id](), mock.Any[string]())
+	})
+
+	t.Run("chatter with a pet on the overlay does not join again", func(t *testing.T) {
+		mock.SetUp(t)
+
+		bot, announcer, _, pets := setUpBot()
+		mock.When(announcer.HasPet(channel.Id, user.Id)).ThenReturn(true)
+
+		bot.HandleChatMessage(channel, user, "hello")
+
+		mock.Verify(pets, mock.Never()).GetPet(mock.Any[twitch.Id](), mock.Any[twitch.Id](), mock.Any[string]())
+		mock.Verify(announcer, mock.Never()).AnnounceJoin(mock.Any[twitch.Id](), mock.Any[services.Pet]())
+	})
+
+	t.Run("command announced as action", func(t *testing.T) {
+		mock.SetUp(t)
+
+		bot, announcer, _, _ := setUpBot()
+		mock.When(announcer.HasPet(channel.Id, user.Id)).ThenReturn(true)
+
+		bot.HandleChatMessage(channel, user, "!Jump now")
+
+		mock.Verify(announcer, mock.Once()).AnnounceAction(channel.Id, user.Id, "jump")
+	})
+
+	t.Run("color command selects item", func(t *testing.T) {
+		mock.SetUp(t)
+
+		item := models.Item{ItemId: uuid.New(), Name: "red", Image: "red image"}
+
+		bot, announcer, items, _ := setUpBot()
+		mock.When(announcer.HasPet(channel.Id, user.Id)).ThenReturn(true)
+		mock.When(items.GetItemByName(channel.Id, "red")).ThenReturn(item, nil)
+
+		bot.HandleChatMessage(channel, user, "!color red")
+
+		mock.Verify(items, mock.Once()).SetSelectedItem(user.Id, channel.Id, item.ItemId)
+		mock.Verify(announcer, mock.Once()).AnnounceUpdate(channel.Id, user.Id, item.Image)
+		mock.Verify(announcer, mock.Never()).AnnounceAction(mock.Any[twitch.Id](), mock.Any[twitch.Id](), mock.Any[string]())
+	})
+
+	t.Run("color command with unknown item ignored", func(t *testing.T) {
+		mock.SetUp(t)
+
+		bot, announcer, items, _ := setUpBot()
+		mock.When(announcer.HasPet(channel.Id, user.Id)).ThenReturn(true)
+		mock.When(items.GetItemByName(channel.Id, "blue")).ThenReturn(models.Item{}, apperrors.New(apperrors.NotFound, "item_not_found", "item not found"))
+
+		bot.HandleChatMessage(channel, user, "!color blue")
+
+		mock.Verify(items, mock.Never()).SetSelectedItem(mock.Any[twitch.Id](), mock.Any[twitch.Id](), mock.Any[uuid.UUID]())
+		mock.Verify(announcer, mock.Never()).AnnounceUpdate(mock.Any[twitch.Id](), mock.Any[twitch.Id](), mock.Any[string]())
+	})
+
+	t.Run("part announced", func(t *testing.T) {
+		mock.SetUp(t)
+
+		bot, announcer, _, _ := setUpBot()
+
+		bot.HandleChatPart(channel, user)
+
+		mock.Verify(announcer, mock.Once()).AnnouncePart(channel.Id, user.Id)
+	})
+
+	t.Run("events ignored until ids are known", func(t *testing.T) {
+		mock.SetUp(t)
+
+		bot, announcer, _, pets := setUpBot()
+
+		bot.HandleChatJoin(channel, twitch.ChatUser{Login: "user"})
+		bot.HandleChatPart(twitch.ChatChannel{Login: "channel"}, user)
+		bot.HandleChatMessage(twitch.ChatChannel{Login: "channel"}, user, "!jump")
+
+		mock.Verify(pets, mock.Never()).GetPet(mock.Any[twitch.Id](), mock.Any[twitch.Id](), mock.Any[string]())
+		mock.Verify(announcer, mock.Never()).AnnouncePart(mock.Any[twitch.Id](), mock.Any[twitch.Id]())
+		mock.Verify(announcer, mock.Never()).AnnounceAction(mock.Any[twitch.Id](), mock.Any[twitch.Id](), mock.Any[string]())
+	})
+}
@@ -2,16 +2,35 @@ package controllers
 
 import (
 	"context"
-	"errors"
+	"crypto/subtle"
 	"log/slog"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
-	"github.com/streampets/backend/repositories"
+	"github.com/streampets/backend/announcers"
+	"github.com/streampets/backend/apperrors"
+	"github.com/streampets/backend/models"
+	"github.com/streampets/backend/services"
 	"github.com/streampets/backend/twitch"
 )
 
+const SessionCookie string = "session"
+const OAuthStateCookie string = "oauth_state"
+const OAuthVerifierCookie string = "oauth_verifier"
+
+// How long a streamer has to log in on Twitch before the login has to be started again.
+const loginTimeout int = 10 * 60
+
+// The longest a rotated overlay id may keep working.
+const maxOverlayGracePeriod time.Duration = 24 * time.Hour
+
+var ErrNoAccessToken = apperrors.New(apperrors.Unauthorized, "no_access_token", "no access token present")
+var ErrInvalidOAuthState = apperrors.New(apperrors.Forbidden, "invalid_oauth_state", "login state does not match")
+var ErrInvalidGracePeriod = apperrors.New(apperrors.Invalid, "invalid_grace_period", "grace period must be between 0 and 24 hours")
+var ErrLoginDenied = apperrors.New(apperrors.Unauthorized, "login_denied", "login was not authorized on twitch")
+
 type userData struct {
 	OverlayId uuid.UUID `json:"overlay_id"`
 	ChannelId twitch.Id `json:"channel_id"`
@@ -25,55 +44,134 @@ type TokenValidator interface {
 	ValidateToken(ctx context.Context, accessToken string) (response twitch.Id, err error)
 }
 
+type PresenceGetter interface {
+	GetPresence(channelId twitch.Id) []announcers.Presence
+}
+
+type LoginFlow interface {
+	StartLogin() (services.LoginAttempt, error)
+	FinishLogin(ctx context.Context, code, verifier string) (session string, err error)
+	VerifySession(session string) (twitch.Id, error)
+	Logout(session string) error
+}
+
+type ChannelManager interface {
+	OnboardChannel(ctx context.Context, channelId twitch.Id) (channel models.Channel, created bool, err error)
+	RotateOverlayId(channelId twitch.Id, gracePeriod time.Duration) (overlayId uuid.UUID, dropped []uuid.UUID, err error)
+}
+
+type OverlayDisconnector interface {
+	DisconnectOverlay(channelId twitch.Id, overlayId uuid.UUID)
+}
+
 type DashboardController struct {
 	OverlayIdGetter
 	TokenValidator
+	Presence     PresenceGetter
+	Logins       LoginFlow
+	Channels     ChannelManager
+	Overlays     OverlayDisconnector
+	Catalog      CatalogManager
+	Images       ImageUploader
+	dashboardUrl string
 }
 
 func NewDashboardController(
 	overlayIdGetter OverlayIdGetter,
 	tokenValidator TokenValidator,
+	presence PresenceGetter,
+	logins LoginFlow,
+	channels ChannelManager,
+	overlays OverlayDisconnector,
+	catalog CatalogManager,
+	images ImageUploader,
+	dashboardUrl string,
 ) *DashboardController {
 	return &DashboardController{
 		OverlayIdGetter: overlayIdGetter,
 		TokenValidator:  tokenValidator,
+		Presence:        presence,
+		Logins:          logins,
+		Channels:        channels,
+		Overlays:        overlays,
+		Catalog:         catalog,
+		Images:          images,
+		dashboardUrl:    dashboardUrl,
 	}
 }
 
-func (c *DashboardController) HandleLogin(ctx *gin.Context) {
-	token, err := ctx.Cookie("Authorization")
-	if err == http.ErrNoCookie {
-		slog.Debug("no 'Authorization' cookie present")
-		ctx.JSON(http.StatusUnauthorized, nil)
+// Sends the streamer to Twitch to log in. The state and PKCE verifier are
+// kept in cookies until Twitch sends them back to FinishLogin.
+func (c *DashboardController) StartLogin(ctx *gin.Context) {
+	attempt, err := c.Logins.StartLogin()
+	if err != nil {
+		addErrorToCtx(err, ctx)
 		return
-	} else if err != nil {
-		// This should never happen since ctx.Cookie() only returns nil or http.ErrNoCookie.
-		// If this does occur, it might indicate a bug.
-		slog.Error("error when retrieving 'Authorization' cookie", "err", err.Error())
-		ctx.JSON(http.StatusInternalServerError, nil)
+	}
+
+	ctx.SetSameSite(http.SameSiteLaxMode)
+	ctx.SetCookie(OAuthStateCookie, attempt.State, loginTimeout, "/", "", true, true)
+	ctx.SetCookie(OAuthVerifierCookie, attempt.Verifier, loginTimeout, "/", "", true, true)
+	ctx.Redirect(http.StatusFound, attempt.Url)
+}
+
+// Handles Twitch sending the streamer back after logging in. Gives them a
+// session cookie and sends them on to the dashboard.
+func (c *DashboardController) FinishLogin(ctx *gin.Context) {
+	if ctx.Query("error") != "" {
+		slog.Debug("login denied on twitch", "error", ctx.Query("error"), "description", ctx.Query("error_description"))
+		addErrorToCtx(ErrLoginDenied, ctx)
 		return
 	}
 
-	userId, err := c.ValidateToken(ctx, token)
-	if err == twitch.ErrInvalidUserToken {
-		slog.Debug("invalid access token in header")
-		ctx.JSON(http.StatusUnauthorized, nil)
+	state, stateErr := ctx.Cookie(OAuthStateCookie)
+	verifier, verifierErr := ctx.Cookie(OAuthVerifierCookie)
+
+	ctx.SetSameSite(http.SameSiteLaxMode)
+	ctx.SetCookie(OAuthStateCookie, "", -1, "/", "", true, true)
+	ctx.SetCookie(OAuthVerifierCookie, "", -1, "/", "", true, true)
+
+	if stateErr != nil || verifierErr != nil || subtle.ConstantTimeCompare([]byte(state), []byte(ctx.Query("state"))) != 1 {
+		addErrorToCtx(ErrInvalidOAuthState, ctx)
 		return
-	} else if err != nil {
-		slog.Error("error when validating access token", "err", err.Error())
-		ctx.JSON(http.StatusInternalServerError, nil)
+	}
+
+	session, err := c.Logins.FinishLogin(ctx, ctx.Query("code"), verifier)
+	if err != nil {
+		addErrorToCtx(err, ctx)
 		return
 	}
 
-	overlayId, err := c.GetOverlayId(userId)
-	var e *repositories.ErrNoOverlayId
-	if errors.As(err, &e) {
-		slog.Error("no overlay id associated with channel id", "channel_id", e.ChannelId)
-		ctx.JSON(http.StatusBadRequest, nil)
+	// The dashboard is on another site, so the cookie has to be sent cross-site.
+	ctx.SetSameSite(http.SameSiteNoneMode)
+	ctx.SetCookie(SessionCookie, session, 0, "/", "", true, true)
+	ctx.Redirect(http.StatusFound, c.dashboardUrl)
+}
+
+// Ends the streamer's sessions, not just the one in this browser, and clears
+// the session cookie.
+func (c *DashboardController) Logout(ctx *gin.Context) {
+	if session, err := ctx.Cookie(SessionCookie); err == nil {
+		if err := c.Logins.Logout(session); err != nil {
+			addErrorToCtx(err, ctx)
+			return
+		}
+	}
+
+	ctx.SetSameSite(http.SameSiteNoneMode)
+	ctx.SetCookie(SessionCookie, "", -1, "/", "", true, true)
+	ctx.JSON(http.StatusNoContent, nil)
+}
+
+func (c *DashboardController) HandleLogin(ctx *gin.Context) {
+	userId, ok := c.authenticate(ctx)
+	if !ok {
 		return
-	} else if err != nil {
-		slog.Error("error when getting overlay url", "err", err.Error())
-		ctx.JSON(http.StatusInternalServerError, nil)
+	}
+
+	overlayId, err := c.GetOverlayId(userId)
+	if err != nil {
+		addErrorToCtx(err, ctx)
 		return
 	}
 
@@ -82,3 +180,119 @@ func (c *DashboardController) HandleLogin(ctx *gin.Context) {
 		ChannelId: userId,
 	})
 }
+
+// Sets up the logged in streamer's channel so they get an overlay. Streamers
+// who were already set up get their existing channel back. The body is an
+// empty JSON object.
+func (c *DashboardController) OnboardChannel(ctx *gin.Context) {
+	userId, ok := c.authenticate(ctx)
+	if !ok {
+		return
+	}
+
+	var params struct{}
+	if err := bindJSONBody(ctx, &params); err != nil {
+		addErrorToCtx(err, ctx)
+		return
+	}
+
+	channel, created, err := c.Channels.OnboardChannel(ctx, userId)
+	if err != nil {
+		addErrorToCtx(err, ctx)
+		return
+	}
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+
+	ctx.JSON(status, userData{
+		OverlayId: channel.OverlayId,
+		ChannelId: channel.ChannelId,
+	})
+}
+
+// Gives the logged in streamer's channel a new overlay id. Overlays using the
+// old one are disconnected, unless a grace period is asked for, in which case
+// they are disconnected once it is over. Overlays still using the id before
+// that are disconnected straight away.
+func (c *DashboardController) RotateOverlayId(ctx *gin.Context) {
+	type Params struct {
+		// Required, so that overlays are only cut off straight away on purpose.
+		GracePeriodSeconds *int `json:"grace_period_seconds" binding:"required"`
+	}
+
+	channelId, ok := c.authenticate(ctx)
+	if !ok {
+		return
+	}
+
+	var params Params
+	if err := bindJSONBody(ctx, &params); err != nil {
+		addErrorToCtx(err, ctx)
+		return
+	}
+
+	gracePeriod := time.Duration(*params.GracePeriodSeconds) * time.Second
+	if gracePeriod < 0 || gracePeriod > maxOverlayGracePeriod {
+		addErrorToCtx(ErrInvalidGracePeriod, ctx)
+		return
+	}
+
+	overlayId, dropped, err := c.Channels.RotateOverlayId(channelId, gracePeriod)
+	if err != nil {
+		addErrorToCtx(err, ctx)
+		return
+	}
+
+	for _, overlayId := range dropped {
+		c.Overlays.DisconnectOverlay(channelId, overlayId)
+	}
+
+	ctx.JSON(http.StatusOK, userData{
+		OverlayId: overlayId,
+		ChannelId: channelId,
+	})
+}
+
+// Lists the pets currently on the logged in streamer's overlay.
+func (c *DashboardController) GetPresence(ctx *gin.Context) {
+	channelId, ok := c.authenticate(ctx)
+	if !ok {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, c.Presence.GetPresence(channelId))
+}
+
+// Returns the id of the streamer whose session is in the 'session' cookie.
+// Dashboards that still hold a Twitch access token in the 'Authorization'
+// cookie are let in by validating it with Twitch.
+// Responds to the request and returns false if there is neither.
+func (c *DashboardController) authenticate(ctx *gin.Context) (twitch.Id, bool) {
+	if session, err := ctx.Cookie(SessionCookie); err == nil {
+		userId, err := c.Logins.VerifySession(session)
+		if err != nil {
+			addErrorToCtx(err, ctx)
+			return "", false
+		}
+		return userId, true
+	}
+
+	token, err := ctx.Cookie(Authorization)
+	if err != nil {
+		// ctx.Cookie() only returns http.ErrNoCookie.
+		slog.Debug("no 'Authorization' cookie present")
+		addErrorToCtx(ErrNoAccessToken, ctx)
+		return "", false
+	}
+
+	userId, err := c.ValidateToken(ctx, token)
+	if err != nil {
+		addErrorToCtx(err, ctx)
+		return "", false
+	}
+
+	return userId, true
+}
@@ -0,0 +1,253 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/streampets/backend/models"
+	"github.com/streampets/backend/services"
+	"github.com/streampets/backend/twitch"
+)
+
+type CatalogManager interface {
+	GetCatalog(channelId twitch.Id) ([]models.CatalogItem, error)
+	CreateItem(channelId twitch.Id, item models.Item) (models.CatalogItem, error)
+	UpdateItem(channelId twitch.Id, itemId uuid.UUID, update services.ItemUpdate) (models.CatalogItem, error)
+	RemoveItem(channelId twitch.Id, itemId uuid.UUID) (retired bool, err error)
+	ReorderItems(channelId twitch.Id, itemIds []uuid.UUID) error
+	SetDefaultItem(channelId twitch.Id, itemId uuid.UUID) error
+}
+
+type ImageUploader interface {
+	UploadImage(ctx context.Context, data []byte) (services.UploadedImage, error)
+}
+
+// Room left in upload requests for the multipart encoding around the image.
+const maxUploadOverhead int64 = 64 << 10
+
+// Lists the logged in streamer's items, retired ones included, in store order.
+func (c *DashboardController) GetItems(ctx *gin.Context) {
+	channelId, ok := c.authenticate(ctx)
+	if !ok {
+		return
+	}
+
+	items, err := c.Catalog.GetCatalog(channelId)
+	if err != nil {
+		addErrorToCtx(err, ctx)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, items)
+}
+
+// Adds a new item to the end of the logged in streamer's store.
+func (c *DashboardController) CreateItem(ctx *gin.Context) {
+	type Params struct {
+		Name    string        `json:"name"`
+		Rarity  models.Rarity `json:"rarity"`
+		Image   string        `json:"img"`
+		PrevImg string        `json:"prev"`
+	}
+
+	channelId, ok := c.authenticate(ctx)
+	if !ok {
+		return
+	}
+
+	var params Params
+	if err := ctx.ShouldBindJSON(&params); err != nil {
+		addErrorToCtx(invalidRequest(err), ctx)
+		return
+	}
+
+	item, err := c.Catalog.CreateItem(channelId, models.Item{
+		Name:    params.Name,
+		Rarity:  params.Rarity,
+		Image:   params.Image,
+		PrevImg: params.PrevImg,
+	})
+	if err != nil {
+		addErrorToCtx(err, ctx)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, item)
+}
+
+// Renames, re-images, retires or brings back one of the logged in streamer's
+// items. Only the fields in the body are changed.
+func (c *DashboardController) UpdateItem(ctx *gin.Context) {
+	type Params struct {
+		Name    *string        `json:"name"`
+		Rarity  *models.Rarity `json:"rarity"`
+		Image   *string        `json:"img"`
+		PrevImg *string        `json:"prev"`
+		Retired *bool          `json:"retired"`
+	}
+
+	channelId, ok := c.authenticate(ctx)
+	if !ok {
+		return
+	}
+
+	itemId, err := uuid.Parse(ctx.Param(ItemId))
+	if err != nil {
+		addErrorToCtx(invalidRequest(err), ctx)
+		return
+	}
+
+	var params Params
+	if err := ctx.ShouldBindJSON(&params); err != nil {
+		addErrorToCtx(invalidRequest(err), ctx)
+		return
+	}
+
+	item, err := c.Catalog.UpdateItem(channelId, itemId, services.ItemUpdate{
+		Name:    params.Name,
+		Rarity:  params.Rarity,
+		Image:   params.Image,
+		PrevImg: params.PrevImg,
+		Retired: params.Retired,
+	})
+	if err != nil {
+		addErrorToCtx(err, ctx)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, item)
+}
+
+// Deletes one of the logged in streamer's items. Items viewers own or have
+// selected are retired instead, which the response says.
+func (c *DashboardController) DeleteItem(ctx *gin.Context) {
+	type Response struct {
+		Retired bool `json:"retired"`
+	}
+
+	channelId, ok := c.authenticate(ctx)
+	if !ok {
+		return
+	}
+
+	itemId, err := uuid.Parse(ctx.Param(ItemId))
+	if err != nil {
+		addErrorToCtx(invalidRequest(err), ctx)
+		return
+	}
+
+	retired, err := c.Catalog.RemoveItem(channelId, itemId)
+	if err != nil {
+		addErrorToCtx(err, ctx)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, Response{Retired: retired})
+}
+
+// Puts the logged in streamer's items in the order given, which has to
+// include every one of them.
+func (c *DashboardController) ReorderItems(ctx *gin.Context) {
+	type Params struct {
+		ItemIds []uuid.UUID `json:"item_ids"`
+	}
+
+	channelId, ok := c.authenticate(ctx)
+	if !ok {
+		return
+	}
+
+	var params Params
+	if err := ctx.ShouldBindJSON(&params); err != nil {
+		addErrorToCtx(invalidRequest(err), ctx)
+		return
+	}
+
+	if err := c.Catalog.ReorderItems(channelId, params.ItemIds); err != nil {
+		addErrorToCtx(err, ctx)
+		return
+	}
+
+	ctx.JSON(http.StatusNoContent, nil)
+}
+
+// Changes the item every viewer of the logged in streamer's channel gets for free.
+func (c *DashboardController) SetDefaultItem(ctx *gin.Context) {
+	type Params struct {
+		ItemId string `json:"item_id"`
+	}
+
+	channelId, ok := c.authenticate(ctx)
+	if !ok {
+		return
+	}
+
+	var params Params
+	if err := ctx.ShouldBindJSON(&params); err != nil {
+		addErrorToCtx(invalidRequest(err), ctx)
+		return
+	}
+
+	itemId, err := uuid.Parse(params.ItemId)
+	if err != nil {
+		addErrorToCtx(invalidRequest(err), ctx)
+		return
+	}
+
+	if err := c.Catalog.SetDefaultItem(channelId, itemId); err != nil {
+		addErrorToCtx(err, ctx)
+		return
+	}
+
+	ctx.JSON(http.StatusNoContent, nil)
+}
+
+// Stores an image sent as the 'image' field of a multipart form, along with
+// a preview of it. Responds with their urls, for use as an item's images.
+func (c *DashboardController) UploadImage(ctx *gin.Context) {
+	if _, ok := c.authenticate(ctx); !ok {
+		return
+	}
+
+	ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, int64(services.MaxImageSize)+maxUploadOverhead)
+
+	header, err := ctx.FormFile(ImageField)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			addErrorToCtx(services.ErrImageTooLarge, ctx)
+			return
+		}
+		addErrorToCtx(invalidRequest(err), ctx)
+		return
+	}
+	if header.Size > int64(services.MaxImageSize) {
+		addErrorToCtx(services.ErrImageTooLarge, ctx)
+		return
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		addErrorToCtx(invalidRequest(err), ctx)
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		addErrorToCtx(invalidRequest(err), ctx)
+		return
+	}
+
+	uploaded, err := c.Images.UploadImage(ctx, data)
+	if err != nil {
+		addErrorToCtx(err, ctx)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, uploaded)
+}
@@ -0,0 +1,340 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/ovechkin-dm/mockio/mock"
+	"github.com/streampets/backend/models"
+	"github.com/streampets/backend/services"
+	"github.com/streampets/backend/twitch"
+	"github.com/stretchr/testify/assert"
+)
+
+func setUpCatalogContext(method, body string, itemId string) (*gin.Context, *httptest.ResponseRecorder) {
+	gin.SetMode(gin.TestMode)
+
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+	req, _ := http.NewRequest(method, "/dashboard/items", strings.NewReader(body))
+	req.AddCookie(&http.Cookie{Name: SessionCookie, Value: "session"})
+
+	ctx.Request = req
+	if itemId != "" {
+		ctx.Params = gin.Params{{Key: ItemId, Value: itemId}}
+	}
+	return ctx, recorder
+}
+
+func newCatalogController(channelId twitch.Id, catalog CatalogManager) *DashboardController {
+	logins := mock.Mock[LoginFlow]()
+	mock.When(logins.VerifySession("session")).ThenReturn(channelId, nil)
+
+	return NewDashboardController(mock.Mock[OverlayIdGetter](), mock.Mock[TokenValidator](), mock.Mock[PresenceGetter](), logins, mock.Mock[ChannelManager](), mock.Mock[OverlayDisconnector](), catalog, mock.Mock[ImageUploader](), "https://dashboard")
+}
+
+func TestGetItems(t *testing.T) {
+	t.Run("catalog returned", func(t *testing.T) {
+		mock.SetUp(t)
+
+		channelId := twitch.Id("channel id")
+		items := []models.CatalogItem{{Item: models.Item{ItemId: uuid.New(), Name: "red"}, Retired: true}}
+
+		catalog := mock.Mock[CatalogManager]()
+		mock.When(catalog.GetCatalog(channelId)).ThenReturn(items, nil)
+
+		ctx, recorder := setUpCatalogContext("GET", "", "")
+		newCatalogController(channelId, catalog).GetItems(ctx)
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+
+		var actual []models.CatalogItem
+		if err := json.Unmarshal(recorder.Body.Bytes(), &actual); err != nil {
+			t.Errorf("could not parse json response")
+		}
+		assert.Equal(t, items, actual)
+	})
+
+	t.Run("unauthorized status when not logged in", func(t *testing.T) {
+		mock.SetUp(t)
+
+		gin.SetMode(gin.TestMode)
+		recorder := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(recorder)
+		ctx.Request, _ = http.NewRequest("GET", "/dashboard/items", nil)
+
+		catalog := mock.Mock[CatalogManager]()
+
+		newCatalogController("channel id", catalog).GetItems(ctx)
+
+		assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+		mock.Verify(catalog, mock.Never()).GetCatalog(mock.Any[twitch.Id]())
+	})
+}
+
+func TestCreateItem(t *testing.T) {
+	t.Run("created status when item created", func(t *testing.T) {
+		mock.SetUp(t)
+
+		channelId := twitch.Id("channel id")
+		item := models.Item{Name: "red", Rarity: models.Common, Image: "red.png", PrevImg: "red-prev.png"}
+		created := models.CatalogItem{Item: item, Position: 3}
+		created.ItemId = uuid.New()
+
+		catalog := mock.Mock[CatalogManager]()
+		mock.When(catalog.CreateItem(channelId, item)).ThenReturn(created, nil)
+
+		ctx, recorder := setUpCatalogContext("POST", `{"name":"red","rarity":"common","img":"red.png","prev":"red-prev.png"}`, "")
+		newCatalogController(channelId, catalog).CreateItem(ctx)
+
+		assert.Equal(t, http.StatusCreated, recorder.Code)
+
+		var actual models.CatalogItem
+		if err := json.Unmarshal(recorder.Body.Bytes(), &actual); err != nil {
+			t.Errorf("could not parse json response")
+		}
+		assert.Equal(t, created, actual)
+	})
+
+	t.Run("conflict status when name taken", func(t *testing.T) {
+		mock.SetUp(t)
+
+		catalog := mock.Mock[CatalogManager]()
+		mock.When(catalog.CreateItem(mock.Any[twitch.Id](), mock.Any[models.Item]())).ThenReturn(models.CatalogItem{}, services.ErrItemNameTaken)
+
+		ctx, recorder := setUpCatalogContext("POST", `{"name":"red","rarity":"common","img":"red.png"}`, "")
+		newCatalogController("channel id", catalog).CreateItem(ctx)
+
+		assert.Equal(t, http.StatusConflict, recorder.Code)
+	})
+}
+
+func TestUpdateItem(t *testing.T) {
+	t.Run("only given fields updated", func(t *testing.T) {
+		mock.SetUp(t)
+
+		channelId := twitch.Id("channel id")
+		itemId := uuid.New()
+
+		catalog := mock.Mock[CatalogManager]()
+		mock.When(catalog.UpdateItem(mock.Equal(channelId), mock.Equal(itemId), mock.Any[services.ItemUpdate]())).ThenReturn(models.CatalogItem{}, nil)
+
+		ctx, recorder := setUpCatalogContext("PATCH", `{"name":"crimson","retired":true}`, itemId.String())
+		newCatalogController(channelId, catalog).UpdateItem(ctx)
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+
+		captor := mock.Captor[services.ItemUpdate]()
+		mock.Verify(catalog, mock.Once()).UpdateItem(mock.Equal(channelId), mock.Equal(itemId), captor.Capture())
+
+		update := captor.Last()
+		if assert.NotNil(t, update.Name) && assert.NotNil(t, update.Retired) {
+			assert.Equal(t, "crimson", *update.Name)
+			assert.True(t, *update.Retired)
+		}
+		assert.Nil(t, update.Rarity)
+		assert.Nil(t, update.Image)
+		assert.Nil(t, update.PrevImg)
+	})
+
+	t.Run("bad request status when item id invalid", func(t *testing.T) {
+		mock.SetUp(t)
+
+		catalog := mock.Mock[CatalogManager]()
+
+		ctx, recorder := setUpCatalogContext("PATCH", `{"name":"crimson"}`, "not a uuid")
+		newCatalogController("channel id", catalog).UpdateItem(ctx)
+
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+		mock.Verify(catalog, mock.Never()).UpdateItem(mock.Any[twitch.Id](), mock.Any[uuid.UUID](), mock.Any[services.ItemUpdate]())
+	})
+}
+
+func TestDeleteItem(t *testing.T) {
+	tests := map[string]struct {
+		retired bool
+	}{
+		"item deleted": {false},
+		"item retired": {true},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			mock.SetUp(t)
+
+			channelId := twitch.Id("channel id")
+			itemId := uuid.New()
+
+			catalog := mock.Mock[CatalogManager]()
+			mock.When(catalog.RemoveItem(channelId, itemId)).ThenReturn(test.retired, nil)
+
+			ctx, recorder := setUpCatalogContext("DELETE", "", itemId.String())
+			newCatalogController(channelId, catalog).DeleteItem(ctx)
+
+			assert.Equal(t, http.StatusOK, recorder.Code)
+
+			var actual struct {
+				Retired bool `json:"retired"`
+			}
+			if err := json.Unmarshal(recorder.Body.Bytes(), &actual); err != nil {
+				t.Errorf("could not parse json response")
+			}
+			assert.Equal(t, test.retired, actual.Retired)
+		})
+	}
+
+	t.Run("conflict status when item is the default", func(t *testing.T) {
+		mock.SetUp(t)
+
+		catalog := mock.Mock[CatalogManager]()
+		mock.When(catalog.RemoveItem(mock.Any[twitch.Id](), mock.Any[uuid.UUID]())).ThenReturn(false, services.ErrDefaultItemRemoved)
+
+		ctx, recorder := setUpCatalogContext("DELETE", "", uuid.New().String())
+		newCatalogController("channel id", catalog).DeleteItem(ctx)
+
+		assert.Equal(t, http.StatusConflict, recorder.Code)
+	})
+}
+
+func TestReorderItems(t *testing.T) {
+	t.Run("items reordered", func(t *testing.T) {
+		mock.SetUp(t)
+
+		channelId := twitch.Id("channel id")
+		itemIds := []uuid.UUID{uuid.New(), uuid.New()}
+
+		catalog := mock.Mock[CatalogManager]()
+		mock.When(catalog.ReorderItems(channelId, itemIds)).ThenReturn(nil)
+
+		body, _ := json.Marshal(map[string]interface{}{"item_ids": itemIds})
+		ctx, recorder := setUpCatalogContext("PUT", string(body), "")
+		newCatalogController(channelId, catalog).ReorderItems(ctx)
+
+		assert.Equal(t, http.StatusNoContent, recorder.Code)
+		mock.Verify(catalog, mock.Once()).ReorderItems(channelId, itemIds)
+	})
+
+	t.Run("bad request status when item ids invalid", func(t *testing.T) {
+		mock.SetUp(t)
+
+		catalog := mock.Mock[CatalogManager]()
+
+		ctx, recorder := setUpCatalogContext("PUT", `{"item_ids":["not a uuid"]}`, "")
+		newCatalogController("channel id", catalog).ReorderItems(ctx)
+
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+		mock.Verify(catalog, mock.Never()).ReorderItems(mock.Any[twitch.Id](), mock.Any[[]uuid.UUID]())
+	})
+}
+
+func TestSetDefaultItem(t *testing.T) {
+	t.Run("default item changed", func(t *testing.T) {
+		mock.SetUp(t)
+
+		channelId := twitch.Id("channel id")
+		itemId := uuid.New()
+
+		catalog := mock.Mock[CatalogManager]()
+		mock.When(catalog.SetDefaultItem(channelId, itemId)).ThenReturn(nil)
+
+		ctx, recorder := setUpCatalogContext("PUT", `{"item_id":"`+itemId.String()+`"}`, "")
+		newCatalogController(channelId, catalog).SetDefaultItem(ctx)
+
+		assert.Equal(t, http.StatusNoContent, recorder.Code)
+		mock.Verify(catalog, mock.Once()).SetDefaultItem(channelId, itemId)
+	})
+
+	t.Run("conflict status when item retired", func(t *testing.T) {
+		mock.SetUp(t)
+
+		catalog := mock.Mock[CatalogManager]()
+		mock.When(catalog.SetDefaultItem(mock.Any[twitch.Id](), mock.Any[uuid.UUID]())).ThenReturn(services.ErrRetiredDefaultItem)
+
+		ctx, recorder := setUpCatalogContext("PUT", `{"item_id":"`+uuid.New().String()+`"}`, "")
+		newCatalogController("channel id", catalog).SetDefaultItem(ctx)
+
+		assert.Equal(t, http.StatusConflict, recorder.Code)
+	})
+}
+
+func TestUploadImage(t *testing.T) {
+	setUpContext := func(data []byte) (*gin.Context, *httptest.ResponseRecorder) {
+		gin.SetMode(gin.TestMode)
+
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		part, _ := form.CreateFormFile(ImageField, "pet.png")
+		part.Write(data)
+		form.Close()
+
+		recorder := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(recorder)
+		req, _ := http.NewRequest("POST", "/dashboard/images", &body)
+		req.Header.Set("Content-Type", form.FormDataContentType())
+		req.AddCookie(&http.Cookie{Name: SessionCookie, Value: "session"})
+
+		ctx.Request = req
+		return ctx, recorder
+	}
+
+	newController := func(images ImageUploader) *DashboardController {
+		logins := mock.Mock[LoginFlow]()
+		mock.When(logins.VerifySession("session")).ThenReturn(twitch.Id("channel id"), nil)
+
+		return NewDashboardController(mock.Mock[OverlayIdGetter](), mock.Mock[TokenValidator](), mock.Mock[PresenceGetter](), logins, mock.Mock[ChannelManager](), mock.Mock[OverlayDisconnector](), mock.Mock[CatalogManager](), images, "https://dashboard")
+	}
+
+	t.Run("created status with image urls", func(t *testing.T) {
+		mock.SetUp(t)
+
+		data := []byte("image data")
+		uploaded := services.UploadedImage{Image: "/assets/image.png", PrevImg: "/assets/preview.png"}
+
+		images := mock.Mock[ImageUploader]()
+		mock.When(images.UploadImage(mock.Any[context.Context](), mock.Equal(data))).ThenReturn(uploaded, nil)
+
+		ctx, recorder := setUpContext(data)
+		newController(images).UploadImage(ctx)
+
+		assert.Equal(t, http.StatusCreated, recorder.Code)
+
+		var actual services.UploadedImage
+		if err := json.Unmarshal(recorder.Body.Bytes(), &actual); err != nil {
+			t.Errorf("could not parse json response")
+		}
+		assert.Equal(t, uploaded, actual)
+	})
+
+	t.Run("bad request status when image too large", func(t *testing.T) {
+		mock.SetUp(t)
+
+		images := mock.Mock[ImageUploader]()
+
+		ctx, recorder := setUpContext(make([]byte, services.MaxImageSize+1))
+		newController(images).UploadImage(ctx)
+
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), "image_too_large")
+		mock.Verify(images, mock.Never()).UploadImage(mock.Any[context.Context](), mock.Any[[]byte]())
+	})
+
+	t.Run("bad request status when no image sent", func(t *testing.T) {
+		mock.SetUp(t)
+
+		images := mock.Mock[ImageUploader]()
+
+		ctx, recorder := setUpCatalogContext("POST", "", "")
+		newController(images).UploadImage(ctx)
+
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+		mock.Verify(images, mock.Never()).UploadImage(mock.Any[context.Context](), mock.Any[[]byte]())
+	})
+}
@@ -1,15 +1,21 @@
 package controllers
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/ovechkin-dm/mockio/mock"
+	"github.com/streampets/backend/announcers"
+	"github.com/streampets/backend/models"
 	"github.com/streampets/backend/repositories"
+	"github.com/streampets/backend/services"
 	"github.com/streampets/backend/twitch"
 	"github.com/stretchr/testify/assert"
 )
@@ -39,7 +45,7 @@ func TestHandleLogin(t *testing.T) {
 		overlays := mock.Mock[OverlayIdGetter]()
 		validator := mock.Mock[TokenValidator]()
 
-		controller := NewDashboardController(overlays, validator)
+		controller := NewDashboardController(overlays, validator, mock.Mock[PresenceGetter](), mock.Mock[LoginFlow](), mock.Mock[ChannelManager](), mock.Mock[OverlayDisconnector](), mock.Mock[CatalogManager](), mock.Mock[ImageUploader](), "https://dashboard")
 
 		ctx, recorder := setUpContext("")
 		controller.HandleLogin(ctx)
@@ -58,7 +64,7 @@ func TestHandleLogin(t *testing.T) {
 
 		mock.When(validator.ValidateToken(ctx, invalidToken)).ThenReturn(nil, twitch.ErrInvalidUserToken)
 
-		controller := NewDashboardController(overlays, validator)
+		controller := NewDashboardController(overlays, validator, mock.Mock[PresenceGetter](), mock.Mock[LoginFlow](), mock.Mock[ChannelManager](), mock.Mock[OverlayDisconnector](), mock.Mock[CatalogManager](), mock.Mock[ImageUploader](), "https://dashboard")
 		controller.HandleLogin(ctx)
 
 		assert.Equal(t, http.StatusUnauthorized, recorder.Code)
@@ -75,13 +81,13 @@ func TestHandleLogin(t *testing.T) {
 
 		mock.When(validator.ValidateToken(ctx, invalidToken)).ThenReturn(nil, assert.AnError)
 
-		controller := NewDashboardController(overlays, validator)
+		controller := NewDashboardController(overlays, validator, mock.Mock[PresenceGetter](), mock.Mock[LoginFlow](), mock.Mock[ChannelManager](), mock.Mock[OverlayDisconnector](), mock.Mock[CatalogManager](), mock.Mock[ImageUploader](), "https://dashboard")
 		controller.HandleLogin(ctx)
 
 		assert.Equal(t, http.StatusInternalServerError, recorder.Code)
 	})
 
-	t.Run("status bad request when channel id has no overlay id", func(t *testing.T) {
+	t.Run("status not found when channel id has no overlay id", func(t *testing.T) {
 		mock.SetUp(t)
 
 		token := "token"
@@ -94,10 +100,10 @@ func TestHandleLogin(t *testing.T) {
 		mock.When(validator.ValidateToken(ctx, token)).ThenReturn(channelId, nil)
 		mock.When(overlays.GetOverlayId(channelId)).ThenReturn(nil, repositories.NewErrNoOverlayId(channelId))
 
-		controller := NewDashboardController(overlays, validator)
+		controller := NewDashboardController(overlays, validator, mock.Mock[PresenceGetter](), mock.Mock[LoginFlow](), mock.Mock[ChannelManager](), mock.Mock[OverlayDisconnector](), mock.Mock[CatalogManager](), mock.Mock[ImageUploader](), "https://dashboard")
 		controller.HandleLogin(ctx)
 
-		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+		assert.Equal(t, http.StatusNotFound, recorder.Code)
 	})
 
 	t.Run("status bad request when channel id has no overlay id", func(t *testing.T) {
@@ -113,12 +119,51 @@ func TestHandleLogin(t *testing.T) {
 		mock.When(validator.ValidateToken(ctx, token)).ThenReturn(channelId, nil)
 		mock.When(overlays.GetOverlayId(channelId)).ThenReturn(nil, assert.AnError)
 
-		controller := NewDashboardController(overlays, validator)
+		controller := NewDashboardController(overlays, validator, mock.Mock[PresenceGetter](), mock.Mock[LoginFlow](), mock.Mock[ChannelManager](), mock.Mock[OverlayDisconnector](), mock.Mock[CatalogManager](), mock.Mock[ImageUploader](), "https://dashboard")
 		controller.HandleLogin(ctx)
 
 		assert.Equal(t, http.StatusInternalServerError, recorder.Code)
 	})
 
+	t.Run("session cookie used over 'Authorization' cookie", func(t *testing.T) {
+		mock.SetUp(t)
+
+		channelId := twitch.Id("channel id")
+		ctx, recorder := setUpContext("token")
+		ctx.Request.AddCookie(&http.Cookie{Name: SessionCookie, Value: "session"})
+
+		overlays := mock.Mock[OverlayIdGetter]()
+		validator := mock.Mock[TokenValidator]()
+		logins := mock.Mock[LoginFlow]()
+
+		mock.When(logins.VerifySession("session")).ThenReturn(channelId, nil)
+		mock.When(overlays.GetOverlayId(channelId)).ThenReturn(uuid.New(), nil)
+
+		controller := NewDashboardController(overlays, validator, mock.Mock[PresenceGetter](), logins, mock.Mock[ChannelManager](), mock.Mock[OverlayDisconnector](), mock.Mock[CatalogManager](), mock.Mock[ImageUploader](), "https://dashboard")
+		controller.HandleLogin(ctx)
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		mock.Verify(validator, mock.Never()).ValidateToken(mock.Any[context.Context](), mock.AnyString())
+	})
+
+	t.Run("unauthorized status when session invalid", func(t *testing.T) {
+		mock.SetUp(t)
+
+		ctx, recorder := setUpContext("")
+		ctx.Request.AddCookie(&http.Cookie{Name: SessionCookie, Value: "session"})
+
+		overlays := mock.Mock[OverlayIdGetter]()
+		logins := mock.Mock[LoginFlow]()
+
+		mock.When(logins.VerifySession("session")).ThenReturn(twitch.Id(""), services.ErrInvalidSession)
+
+		controller := NewDashboardController(overlays, mock.Mock[TokenValidator](), mock.Mock[PresenceGetter](), logins, mock.Mock[ChannelManager](), mock.Mock[OverlayDisconnector](), mock.Mock[CatalogManager](), mock.Mock[ImageUploader](), "https://dashboard")
+		controller.HandleLogin(ctx)
+
+		assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+		mock.Verify(overlays, mock.Never()).GetOverlayId(mock.Any[twitch.Id]())
+	})
+
 	t.Run("channel id and overlay id returned in normal case", func(t *testing.T) {
 		mock.SetUp(t)
 
@@ -138,7 +183,7 @@ func TestHandleLogin(t *testing.T) {
 		mock.When(validator.ValidateToken(ctx, token)).ThenReturn(channelId, nil)
 		mock.When(overlays.GetOverlayId(channelId)).ThenReturn(overlayId, nil)
 
-		controller := NewDashboardController(overlays, validator)
+		controller := NewDashboardController(overlays, validator, mock.Mock[PresenceGetter](), mock.Mock[LoginFlow](), mock.Mock[ChannelManager](), mock.Mock[OverlayDisconnector](), mock.Mock[CatalogManager](), mock.Mock[ImageUploader](), "https://dashboard")
 		controller.HandleLogin(ctx)
 
 		assert.Equal(t, http.StatusOK, recorder.Code)
@@ -156,3 +201,479 @@ func TestHandleLogin(t *testing.T) {
 		assert.Equal(t, expected, actual)
 	})
 }
+
+func TestGetPresence(t *testing.T) {
+	setUpContext := func(cookie string) (*gin.Context, *httptest.ResponseRecorder) {
+		gin.SetMode(gin.TestMode)
+
+		recorder := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(recorder)
+		req, _ := http.NewRequest("GET", "/dashboard/presence", nil)
+
+		if cookie != "" {
+			req.AddCookie(&http.Cookie{
+				Name:  "Authorization",
+				Value: cookie,
+			})
+		}
+
+		ctx.Request = req
+		return ctx, recorder
+	}
+
+	t.Run("unauthorized status when no 'Authorization' cookie present", func(t *testing.T) {
+		mock.SetUp(t)
+
+		presence := mock.Mock[PresenceGetter]()
+		controller := NewDashboardController(mock.Mock[OverlayIdGetter](), mock.Mock[TokenValidator](), presence, mock.Mock[LoginFlow](), mock.Mock[ChannelManager](), mock.Mock[OverlayDisconnector](), mock.Mock[CatalogManager](), mock.Mock[ImageUploader](), "https://dashboard")
+
+		ctx, recorder := setUpContext("")
+		controller.GetPresence(ctx)
+
+		assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+		mock.Verify(presence, mock.Never()).GetPresence(mock.Any[twitch.Id]())
+	})
+
+	t.Run("pets on the streamer's channel are returned", func(t *testing.T) {
+		mock.SetUp(t)
+
+		token := "token"
+		channelId := twitch.Id("channel id")
+		joinedAt := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+		ctx, recorder := setUpContext(token)
+
+		expected := []announcers.Presence{{
+			Pet:      services.Pet{UserId: "user id", Username: "username", Image: "image"},
+			JoinedAt: joinedAt,
+			LastSeen: joinedAt.Add(time.Minute),
+		}}
+
+		validator := mock.Mock[TokenValidator]()
+		presence := mock.Mock[PresenceGetter]()
+
+		mock.When(validator.ValidateToken(ctx, token)).ThenReturn(channelId, nil)
+		mock.When(presence.GetPresence(channelId)).ThenReturn(expected)
+
+		controller := NewDashboardController(mock.Mock[OverlayIdGetter](), validator, presence, mock.Mock[LoginFlow](), mock.Mock[ChannelManager](), mock.Mock[OverlayDisconnector](), mock.Mock[CatalogManager](), mock.Mock[ImageUploader](), "https://dashboard")
+		controller.GetPresence(ctx)
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+
+		var actual []announcers.Presence
+		if err := json.Unmarshal(recorder.Body.Bytes(), &actual); err != nil {
+			t.Errorf("could not parse json response")
+		}
+
+		assert.Equal(t, expected, actual)
+	})
+}
+
+func TestStartLogin(t *testing.T) {
+	mock.SetUp(t)
+	gin.SetMode(gin.TestMode)
+
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+	ctx.Request, _ = http.NewRequest("GET", "/dashboard/oauth/login", nil)
+
+	logins := mock.Mock[LoginFlow]()
+	mock.When(logins.StartLogin()).ThenReturn(services.LoginAttempt{Url: "https://twitch/authorize", State: "state", Verifier: "verifier"}, nil)
+
+	controller := NewDashboardController(mock.Mock[OverlayIdGetter](), mock.Mock[TokenValidator](), mock.Mock[PresenceGetter](), logins, mock.Mock[ChannelManager](), mock.Mock[OverlayDisconnector](), mock.Mock[CatalogManager](), mock.Mock[ImageUploader](), "https://dashboard")
+	controller.StartLogin(ctx)
+
+	assert.Equal(t, http.StatusFound, recorder.Code)
+	assert.Equal(t, "https://twitch/authorize", recorder.Header().Get("Location"))
+
+	cookies := map[string]*http.Cookie{}
+	for _, cookie := range recorder.Result().Cookies() {
+		cookies[cookie.Name] = cookie
+	}
+	if assert.Contains(t, cookies, OAuthStateCookie) && assert.Contains(t, cookies, OAuthVerifierCookie) {
+		assert.Equal(t, "state", cookies[OAuthStateCookie].Value)
+		assert.Equal(t, "verifier", cookies[OAuthVerifierCookie].Value)
+		assert.True(t, cookies[OAuthVerifierCookie].HttpOnly)
+		assert.True(t, cookies[OAuthVerifierCookie].Secure)
+	}
+}
+
+func TestFinishLogin(t *testing.T) {
+	setUpContext := func(query string, state string) (*gin.Context, *httptest.ResponseRecorder) {
+		gin.SetMode(gin.TestMode)
+
+		recorder := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(recorder)
+		req, _ := http.NewRequest("GET", "/dashboard/oauth/callback?"+query, nil)
+
+		if state != "" {
+			req.AddCookie(&http.Cookie{Name: OAuthStateCookie, Value: state})
+			req.AddCookie(&http.Cookie{Name: OAuthVerifierCookie, Value: "verifier"})
+		}
+
+		ctx.Request = req
+		return ctx, recorder
+	}
+
+	t.Run("session cookie set and streamer sent to dashboard", func(t *testing.T) {
+		mock.SetUp(t)
+
+		ctx, recorder := setUpContext("code=code&state=state", "state")
+
+		logins := mock.Mock[LoginFlow]()
+		mock.When(logins.FinishLogin(ctx, "code", "verifier")).ThenReturn("session", nil)
+
+		controller := NewDashboardController(mock.Mock[OverlayIdGetter](), mock.Mock[TokenValidator](), mock.Mock[PresenceGetter](), logins, mock.Mock[ChannelManager](), mock.Mock[OverlayDisconnector](), mock.Mock[CatalogManager](), mock.Mock[ImageUploader](), "https://dashboard")
+		controller.FinishLogin(ctx)
+
+		assert.Equal(t, http.StatusFound, recorder.Code)
+		assert.Equal(t, "https://dashboard", recorder.Header().Get("Location"))
+
+		var session *http.Cookie
+		for _, cookie := range recorder.Result().Cookies() {
+			if cookie.Name == SessionCookie {
+				session = cookie
+			}
+		}
+		if assert.NotNil(t, session) {
+			assert.Equal(t, "session", session.Value)
+			assert.True(t, session.HttpOnly)
+			assert.True(t, session.Secure)
+		}
+	})
+
+	t.Run("forbidden status when state does not match", func(t *testing.T) {
+		mock.SetUp(t)
+
+		ctx, recorder := setUpContext("code=code&state=other", "state")
+
+		logins := mock.Mock[LoginFlow]()
+
+		controller := NewDashboardController(mock.Mock[OverlayIdGetter](), mock.Mock[TokenValidator](), mock.Mock[PresenceGetter](), logins, mock.Mock[ChannelManager](), mock.Mock[OverlayDisconnector](), mock.Mock[CatalogManager](), mock.Mock[ImageUploader](), "https://dashboard")
+		controller.FinishLogin(ctx)
+
+		assert.Equal(t, http.StatusForbidden, recorder.Code)
+		mock.Verify(logins, mock.Never()).FinishLogin(mock.Any[context.Context](), mock.AnyString(), mock.AnyString())
+	})
+
+	t.Run("forbidden status when login was not started", func(t *testing.T) {
+		mock.SetUp(t)
+
+		ctx, recorder := setUpContext("code=code&state=", "")
+
+		controller := NewDashboardController(mock.Mock[OverlayIdGetter](), mock.Mock[TokenValidator](), mock.Mock[PresenceGetter](), mock.Mock[LoginFlow](), mock.Mock[ChannelManager](), mock.Mock[OverlayDisconnector](), mock.Mock[CatalogManager](), mock.Mock[ImageUploader](), "https://dashboard")
+		controller.FinishLogin(ctx)
+
+		assert.Equal(t, http.StatusForbidden, recorder.Code)
+	})
+
+	t.Run("unauthorized status when login denied on twitch", func(t *testing.T) {
+		mock.SetUp(t)
+
+		ctx, recorder := setUpContext("error=access_denied&state=state", "state")
+
+		logins := mock.Mock[LoginFlow]()
+
+		controller := NewDashboardController(mock.Mock[OverlayIdGetter](), mock.Mock[TokenValidator](), mock.Mock[PresenceGetter](), logins, mock.Mock[ChannelManager](), mock.Mock[OverlayDisconnector](), mock.Mock[CatalogManager](), mock.Mock[ImageUploader](), "https://dashboard")
+		controller.FinishLogin(ctx)
+
+		assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+		mock.Verify(logins, mock.Never()).FinishLogin(mock.Any[context.Context](), mock.AnyString(), mock.AnyString())
+	})
+
+	t.Run("unauthorized status when code invalid", func(t *testing.T) {
+		mock.SetUp(t)
+
+		ctx, recorder := setUpContext("code=code&state=state", "state")
+
+		logins := mock.Mock[LoginFlow]()
+		mock.When(logins.FinishLogin(ctx, "code", "verifier")).ThenReturn("", twitch.ErrInvalidAuthorizationCode)
+
+		controller := NewDashboardController(mock.Mock[OverlayIdGetter](), mock.Mock[TokenValidator](), mock.Mock[PresenceGetter](), logins, mock.Mock[ChannelManager](), mock.Mock[OverlayDisconnector](), mock.Mock[CatalogManager](), mock.Mock[ImageUploader](), "https://dashboard")
+		controller.FinishLogin(ctx)
+
+		assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+	})
+}
+
+func TestLogout(t *testing.T) {
+	setUpContext := func(session string) (*gin.Context, *httptest.ResponseRecorder) {
+		gin.SetMode(gin.TestMode)
+
+		recorder := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(recorder)
+		req, _ := http.NewRequest("POST", "/dashboard/logout", nil)
+
+		if session != "" {
+			req.AddCookie(&http.Cookie{Name: SessionCookie, Value: session})
+		}
+
+		ctx.Request = req
+		return ctx, recorder
+	}
+
+	sessionCleared := func(recorder *httptest.ResponseRecorder) bool {
+		for _, cookie := range recorder.Result().Cookies() {
+			if cookie.Name == SessionCookie {
+				return cookie.Value == "" && cookie.MaxAge < 0
+			}
+		}
+		return false
+	}
+
+	t.Run("sessions ended and cookie cleared", func(t *testing.T) {
+		mock.SetUp(t)
+
+		ctx, recorder := setUpContext("session")
+
+		logins := mock.Mock[LoginFlow]()
+		mock.When(logins.Logout("session")).ThenReturn(nil)
+
+		controller := NewDashboardController(mock.Mock[OverlayIdGetter](), mock.Mock[TokenValidator](), mock.Mock[PresenceGetter](), logins, mock.Mock[ChannelManager](), mock.Mock[OverlayDisconnector](), mock.Mock[CatalogManager](), mock.Mock[ImageUploader](), "https://dashboard")
+		controller.Logout(ctx)
+
+		assert.Equal(t, http.StatusNoContent, recorder.Code)
+		assert.True(t, sessionCleared(recorder))
+		mock.Verify(logins, mock.Once()).Logout("session")
+	})
+
+	t.Run("cookie cleared when there is no session", func(t *testing.T) {
+		mock.SetUp(t)
+
+		ctx, recorder := setUpContext("")
+
+		logins := mock.Mock[LoginFlow]()
+
+		controller := NewDashboardController(mock.Mock[OverlayIdGetter](), mock.Mock[TokenValidator](), mock.Mock[PresenceGetter](), logins, mock.Mock[ChannelManager](), mock.Mock[OverlayDisconnector](), mock.Mock[CatalogManager](), mock.Mock[ImageUploader](), "https://dashboard")
+		controller.Logout(ctx)
+
+		assert.Equal(t, http.StatusNoContent, recorder.Code)
+		assert.True(t, sessionCleared(recorder))
+		mock.Verify(logins, mock.Never()).Logout(mock.AnyString())
+	})
+
+	t.Run("cookie kept when sessions could not be ended", func(t *testing.T) {
+		mock.SetUp(t)
+
+		ctx, recorder := setUpContext("session")
+
+		logins := mock.Mock[LoginFlow]()
+		mock.When(logins.Logout("session")).ThenReturn(assert.AnError)
+
+		controller := NewDashboardController(mock.Mock[OverlayIdGetter](), mock.Mock[TokenValidator](), mock.Mock[PresenceGetter](), logins, mock.Mock[ChannelManager](), mock.Mock[OverlayDisconnector](), mock.Mock[CatalogManager](), mock.Mock[ImageUploader](), "https://dashboard")
+		controller.Logout(ctx)
+
+		assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+		assert.False(t, sessionCleared(recorder))
+	})
+}
+
+func TestOnboardChannel(t *testing.T) {
+	setUpContext := func() (*gin.Context, *httptest.ResponseRecorder) {
+		gin.SetMode(gin.TestMode)
+
+		recorder := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(recorder)
+		req, _ := http.NewRequest("POST", "/dashboard/channel", strings.NewReader("{}"))
+		req.Header.Set("Content-Type", "application/json")
+		req.AddCookie(&http.Cookie{Name: SessionCookie, Value: "session"})
+
+		ctx.Request = req
+		return ctx, recorder
+	}
+
+	channelId := twitch.Id("channel id")
+	channel := models.Channel{ChannelId: channelId, OverlayId: uuid.New()}
+
+	tests := map[string]struct {
+		created bool
+		status  int
+	}{
+		"created status when channel created":      {true, http.StatusCreated},
+		"ok status when channel already onboarded": {false, http.StatusOK},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			mock.SetUp(t)
+
+			ctx, recorder := setUpContext()
+
+			logins := mock.Mock[LoginFlow]()
+			channels := mock.Mock[ChannelManager]()
+
+			mock.When(logins.VerifySession("session")).ThenReturn(channelId, nil)
+			mock.When(channels.OnboardChannel(ctx, channelId)).ThenReturn(channel, test.created, nil)
+
+			controller := NewDashboardController(mock.Mock[OverlayIdGetter](), mock.Mock[TokenValidator](), mock.Mock[PresenceGetter](), logins, channels, mock.Mock[OverlayDisconnector](), mock.Mock[CatalogManager](), mock.Mock[ImageUploader](), "https://dashboard")
+			controller.OnboardChannel(ctx)
+
+			assert.Equal(t, test.status, recorder.Code)
+
+			var actual userData
+			if err := json.Unmarshal(recorder.Body.Bytes(), &actual); err != nil {
+				t.Errorf("could not parse json response")
+			}
+			assert.Equal(t, userData{OverlayId: channel.OverlayId, ChannelId: channelId}, actual)
+		})
+	}
+
+	t.Run("unauthorized status when not logged in", func(t *testing.T) {
+		mock.SetUp(t)
+
+		gin.SetMode(gin.TestMode)
+		recorder := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(recorder)
+		ctx.Request, _ = http.NewRequest("POST", "/dashboard/channel", nil)
+
+		channels := mock.Mock[ChannelManager]()
+
+		controller := NewDashboardController(mock.Mock[OverlayIdGetter](), mock.Mock[TokenValidator](), mock.Mock[PresenceGetter](), mock.Mock[LoginFlow](), channels, mock.Mock[OverlayDisconnector](), mock.Mock[CatalogManager](), mock.Mock[ImageUploader](), "https://dashboard")
+		controller.OnboardChannel(ctx)
+
+		assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+		mock.Verify(channels, mock.Never()).OnboardChannel(mock.Any[context.Context](), mock.Any[twitch.Id]())
+	})
+
+	bodies := map[string]struct {
+		contentType string
+		body        string
+	}{
+		"bad request status when body missing":    {"application/json", ""},
+		"bad request status when body not json":   {"application/x-www-form-urlencoded", "a=b"},
+		"bad request status when body not object": {"application/json", "[]"},
+	}
+
+	for name, test := range bodies {
+		t.Run(name, func(t *testing.T) {
+			mock.SetUp(t)
+
+			gin.SetMode(gin.TestMode)
+			recorder := httptest.NewRecorder()
+			ctx, _ := gin.CreateTestContext(recorder)
+			ctx.Request, _ = http.NewRequest("POST", "/dashboard/channel", strings.NewReader(test.body))
+			ctx.Request.Header.Set("Content-Type", test.contentType)
+			ctx.Request.AddCookie(&http.Cookie{Name: SessionCookie, Value: "session"})
+
+			logins := mock.Mock[LoginFlow]()
+			channels := mock.Mock[ChannelManager]()
+			mock.When(logins.VerifySession("session")).ThenReturn(channelId, nil)
+
+			controller := NewDashboardController(mock.Mock[OverlayIdGetter](), mock.Mock[TokenValidator](), mock.Mock[PresenceGetter](), logins, channels, mock.Mock[OverlayDisconnector](), mock.Mock[CatalogManager](), mock.Mock[ImageUploader](), "https://dashboard")
+			controller.OnboardChannel(ctx)
+
+			assert.Equal(t, http.StatusBadRequest, recorder.Code)
+			mock.Verify(channels, mock.Never()).OnboardChannel(mock.Any[context.Context](), mock.Any[twitch.Id]())
+		})
+	}
+}
+
+func TestRotateOverlayId(t *testing.T) {
+	setUpContext := func(body string) (*gin.Context, *httptest.ResponseRecorder) {
+		gin.SetMode(gin.TestMode)
+
+		recorder := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(recorder)
+		req, _ := http.NewRequest("POST", "/dashboard/overlay/rotate", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.AddCookie(&http.Cookie{Name: SessionCookie, Value: "session"})
+
+		ctx.Request = req
+		return ctx, recorder
+	}
+
+	channelId := twitch.Id("channel id")
+	overlayId := uuid.New()
+	previous := uuid.New()
+	beforePrevious := uuid.New()
+
+	t.Run("overlays using dropped overlay ids disconnected", func(t *testing.T) {
+		mock.SetUp(t)
+
+		ctx, recorder := setUpContext(`{"grace_period_seconds":0}`)
+
+		logins := mock.Mock[LoginFlow]()
+		channels := mock.Mock[ChannelManager]()
+		overlays := mock.Mock[OverlayDisconnector]()
+
+		mock.When(logins.VerifySession("session")).ThenReturn(channelId, nil)
+		mock.When(channels.RotateOverlayId(channelId, time.Duration(0))).ThenReturn(overlayId, []uuid.UUID{beforePrevious, previous}, nil)
+
+		controller := NewDashboardController(mock.Mock[OverlayIdGetter](), mock.Mock[TokenValidator](), mock.Mock[PresenceGetter](), logins, channels, overlays, mock.Mock[CatalogManager](), mock.Mock[ImageUploader](), "https://dashboard")
+		controller.RotateOverlayId(ctx)
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		mock.Verify(overlays, mock.Once()).DisconnectOverlay(channelId, previous)
+		mock.Verify(overlays, mock.Once()).DisconnectOverlay(channelId, beforePrevious)
+
+		var actual userData
+		if err := json.Unmarshal(recorder.Body.Bytes(), &actual); err != nil {
+			t.Errorf("could not parse json response")
+		}
+		assert.Equal(t, userData{OverlayId: overlayId, ChannelId: channelId}, actual)
+	})
+
+	t.Run("overlays kept connected during grace period", func(t *testing.T) {
+		mock.SetUp(t)
+
+		ctx, recorder := setUpContext(`{"grace_period_seconds":600}`)
+
+		logins := mock.Mock[LoginFlow]()
+		channels := mock.Mock[ChannelManager]()
+		overlays := mock.Mock[OverlayDisconnector]()
+
+		mock.When(logins.VerifySession("session")).ThenReturn(channelId, nil)
+		mock.When(channels.RotateOverlayId(channelId, 10*time.Minute)).ThenReturn(overlayId, []uuid.UUID{}, nil)
+
+		controller := NewDashboardController(mock.Mock[OverlayIdGetter](), mock.Mock[TokenValidator](), mock.Mock[PresenceGetter](), logins, channels, overlays, mock.Mock[CatalogManager](), mock.Mock[ImageUploader](), "https://dashboard")
+		controller.RotateOverlayId(ctx)
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		mock.Verify(overlays, mock.Never()).DisconnectOverlay(mock.Any[twitch.Id](), mock.Any[uuid.UUID]())
+	})
+
+	t.Run("bad request status when grace period too long", func(t *testing.T) {
+		mock.SetUp(t)
+
+		ctx, recorder := setUpContext(`{"grace_period_seconds":90000}`)
+
+		logins := mock.Mock[LoginFlow]()
+		channels := mock.Mock[ChannelManager]()
+
+		mock.When(logins.VerifySession("session")).ThenReturn(channelId, nil)
+
+		controller := NewDashboardController(mock.Mock[OverlayIdGetter](), mock.Mock[TokenValidator](), mock.Mock[PresenceGetter](), logins, channels, mock.Mock[OverlayDisconnector](), mock.Mock[CatalogManager](), mock.Mock[ImageUploader](), "https://dashboard")
+		controller.RotateOverlayId(ctx)
+
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+		mock.Verify(channels, mock.Never()).RotateOverlayId(mock.Any[twitch.Id](), mock.Any[time.Duration]())
+	})
+
+	bodies := map[string]struct {
+		contentType string
+		body        string
+	}{
+		"bad request status when body missing":         {"application/json", ""},
+		"bad request status when grace period missing": {"application/json", "{}"},
+		"bad request status when body not json":        {"application/x-www-form-urlencoded", "grace_period_seconds=0"},
+	}
+
+	for name, test := range bodies {
+		t.Run(name, func(t *testing.T) {
+			mock.SetUp(t)
+
+			ctx, recorder := setUpContext(test.body)
+			ctx.Request.Header.Set("Content-Type", test.contentType)
+
+			logins := mock.Mock[LoginFlow]()
+			channels := mock.Mock[ChannelManager]()
+
+			mock.When(logins.VerifySession("session")).ThenReturn(channelId, nil)
+
+			controller := NewDashboardController(mock.Mock[OverlayIdGetter](), mock.Mock[TokenValidator](), mock.Mock[PresenceGetter](), logins, channels, mock.Mock[OverlayDisconnector](), mock.Mock[CatalogManager](), mock.Mock[ImageUploader](), "https://dashboard")
+			controller.RotateOverlayId(ctx)
+
+			assert.Equal(t, http.StatusBadRequest, recorder.Code)
+			mock.Verify(channels, mock.Never()).RotateOverlayId(mock.Any[twitch.Id](), mock.Any[time.Duration]())
+		})
+	}
+}
@@ -0,0 +1,233 @@
+package controllers
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/streampets/backend/apperrors"
+	"github.com/streampets/backend/services"
+	"github.com/streampets/backend/twitch"
+)
+
+// Actions announced for channel events, which overlays can animate.
+const (
+	FollowAction    string = "follow"
+	SubscribeAction string = "subscribe"
+	RaidAction      string = "raid"
+)
+
+// How long message ids are remembered. Twitch does not resend messages
+// older than this since they would fail verification.
+const eventSubDedupeWindow = 10 * time.Minute
+
+type EventAnnouncer interface {
+	AnnounceJoin(channelId twitch.Id, pet services.Pet)
+	AnnounceAction(channelId, userId twitch.Id, action string)
+	HasPet(channelId, userId twitch.Id) bool
+}
+
+type EventSubController struct {
+	Announcer EventAnnouncer
+	Pets      PetGetter
+	secret    string
+	seen      *messageIds
+	now       func() time.Time
+}
+
+func NewEventSubController(
+	announcer EventAnnouncer,
+	pets PetGetter,
+	secret string,
+) *EventSubController {
+	return &EventSubController{
+		Announcer: announcer,
+		Pets:      pets,
+		secret:    secret,
+		seen:      newMessageIds(),
+		now:       time.Now,
+	}
+}
+
+// Receives EventSub webhooks from Twitch. Chatters, followers, subscribers
+// and raiders are given a pet if they do not have one on the overlay, and
+// chat messages starting with '!' are announced as actions.
+func (c *EventSubController) HandleWebhook(ctx *gin.Context) {
+	body, err := io.ReadAll(ctx.Request.Body)
+	if err != nil {
+		addErrorToCtx(invalidRequest(err), ctx)
+		return
+	}
+
+	now := c.now()
+	if err := twitch.VerifyEventSubMessage(c.secret, ctx.Request.Header, body, now); err != nil {
+		addErrorToCtx(err, ctx)
+		return
+	}
+
+	var message twitch.EventSubMessage
+	if err := json.Unmarshal(body, &message); err != nil {
+		addErrorToCtx(invalidRequest(err), ctx)
+		return
+	}
+
+	switch ctx.GetHeader(twitch.EventSubMessageType) {
+	case twitch.VerificationMessage:
+		ctx.String(http.StatusOK, message.Challenge)
+		return
+	case twitch.RevocationMessage:
+		slog.Warn("eventsub subscription revoked", "type", message.Subscription.Type, "status", message.Subscription.Status)
+		ctx.Status(http.StatusNoContent)
+		return
+	}
+
+	// Twitch resends messages it is not sure were received.
+	id := ctx.GetHeader(twitch.EventSubMessageId)
+	if !c.seen.claim(id, now) {
+		ctx.Status(http.StatusNoContent)
+		return
+	}
+
+	if err := c.handleNotification(message); err != nil {
+		// Forget the message so that Twitch's retry is handled.
+		c.seen.release(id)
+		addErrorToCtx(err, ctx)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+func (c *EventSubController) handleNotification(message twitch.EventSubMessage) error {
+	switch message.Subscription.Type {
+	case twitch.ChatMessageSubscription:
+		var event twitch.ChatMessageEvent
+		if err := json.Unmarshal(message.Event, &event); err != nil {
+			return invalidRequest(err)
+		}
+
+		if err := c.join(event.BroadcasterUserId, event.ChatterUserId, event.ChatterUserName); err != nil {
+			return err
+		}
+
+		if action, ok := chatAction(event.Message.Text); ok {
+			c.Announcer.AnnounceAction(event.BroadcasterUserId, event.ChatterUserId, action)
+		}
+	case twitch.FollowSubscription:
+		var event twitch.FollowEvent
+		if err := json.Unmarshal(message.Event, &event); err != nil {
+			return invalidRequest(err)
+		}
+		return c.joinWithAction(event.BroadcasterUserId, event.UserId, event.UserName, FollowAction)
+	case twitch.SubscribeSubscription:
+		var event twitch.SubscribeEvent
+		if err := json.Unmarshal(message.Event, &event); err != nil {
+			return invalidRequest(err)
+		}
+		return c.joinWithAction(event.BroadcasterUserId, event.UserId, event.UserName, SubscribeAction)
+	case twitch.RaidSubscription:
+		var event twitch.RaidEvent
+		if err := json.Unmarshal(message.Event, &event); err != nil {
+			return invalidRequest(err)
+		}
+		return c.joinWithAction(event.ToBroadcasterUserId, event.FromBroadcasterUserId, event.FromBroadcasterUserName, RaidAction)
+	default:
+		slog.Debug("unhandled eventsub notification", "type", message.Subscription.Type)
+	}
+	return nil
+}
+
+func (c *EventSubController) joinWithAction(channelId, userId twitch.Id, username, action string) error {
+	if err := c.join(channelId, userId, username); err != nil {
+		return err
+	}
+	c.Announcer.AnnounceAction(channelId, userId, action)
+	return nil
+}
+
+// Announces the user's pet unless it is already on the overlay.
+func (c *EventSubController) join(channelId, userId twitch.Id, username string) error {
+	if c.Announcer.HasPet(channelId, userId) {
+		return nil
+	}
+
+	pet, err := c.Pets.GetPet(userId, channelId, username)
+	if err != nil {
+		if kind := apperrors.KindOf(err); kind == apperrors.Unavailable || kind == apperrors.Internal {
+			return err
+		}
+		// Retrying will not help, for example when the channel has no items yet.
+		slog.Debug("could not get pet for eventsub notification", "channel_id", channelId, "user_id", userId, "err", err.Error())
+		return nil
+	}
+
+	c.Announcer.AnnounceJoin(channelId, pet)
+	return nil
+}
+
+// Chat commands such as "!jump" are announced as the action "jump".
+func chatAction(text string) (string, bool) {
+	command, ok := strings.CutPrefix(strings.TrimSpace(text), "!")
+	if !ok {
+		return "", false
+	}
+
+	action, _, _ := strings.Cut(command, " ")
+	if action == "" {
+		return "", false
+	}
+	return strings.ToLower(action), true
+}
+
+// The ids of recently handled messages. Dedupe is per instance: a retry that
+// lands on another instance is handled again. That is acceptable as Twitch
+// retries a delivery soon after it fails, usually to the same instance.
+type messageIds struct {
+	mu  sync.Mutex
+	ids map[string]time.Time
+	// The claims in the order they were made, so expired ids can be popped
+	// from the front instead of scanning every id.
+	order []claimedId
+}
+
+type claimedId struct {
+	id string
+	at time.Time
+}
+
+func newMessageIds() *messageIds {
+	return &messageIds{ids: make(map[string]time.Time)}
+}
+
+// Records the id and returns true unless it was already recorded.
+func (m *messageIds) claim(id string, now time.Time) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for len(m.order) > 0 && now.Sub(m.order[0].at) > eventSubDedupeWindow {
+		expired := m.order[0]
+		m.order = m.order[1:]
+		// A released id may have been claimed again since.
+		if at, ok := m.ids[expired.id]; ok && at.Equal(expired.at) {
+			delete(m.ids, expired.id)
+		}
+	}
+
+	if _, ok := m.ids[id]; ok {
+		return false
+	}
+	m.ids[id] = now
+	m.order = append(m.order, claimedId{id: id, at: now})
+	return true
+}
+
+func (m *messageIds) release(id string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.ids, id)
+}
@@ -0,0 +1,243 @@
+package controllers
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ovechkin-dm/mockio/mock"
+	"github.com/streampets/backend/services"
+	"github.com/streampets/backend/twitch"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandleWebhook(t *testing.T) {
+	secret := "secret"
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	// Replays a payload recorded from Twitch, signed the way Twitch signs it.
+	send := func(controller *EventSubController, messageId, messageType, payload string) *httptest.ResponseRecorder {
+		body, err := os.ReadFile(filepath.Join("testdata", "eventsub", payload))
+		if err != nil {
+			panic(err)
+		}
+
+		timestamp := now.Format(time.RFC3339Nano)
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte(messageId + timestamp))
+		mac.Write(body)
+
+		req, _ := http.NewRequest("POST", "/eventsub", bytes.NewReader(body))
+		req.Header.Set(twitch.EventSubMessageId, messageId)
+		req.Header.Set(twitch.EventSubMessageType, messageType)
+		req.Header.Set(twitch.EventSubMessageTimestamp, timestamp)
+		req.Header.Set(twitch.EventSubMessageSignature, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+
+		gin.SetMode(gin.TestMode)
+		r := gin.New()
+		r.POST("/eventsub", controller.HandleWebhook)
+
+		recorder := httptest.NewRecorder()
+		r.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	setUpController := func() (*EventSubController, EventAnnouncer, PetGetter) {
+		announcer := mock.Mock[EventAnnouncer]()
+		pets := mock.Mock[PetGetter]()
+
+		controller := NewEventSubController(announcer, pets, secret)
+		controller.now = func() time.Time { return now }
+		return controller, announcer, pets
+	}
+
+	t.Run("challenge answered", func(t *testing.T) {
+		mock.SetUp(t)
+
+		controller, _, _ := setUpController()
+		recorder := send(controller, "message id", twitch.VerificationMessage, "challenge.json")
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, "pogchamp-kappa-360noscope-vohiyo", recorder.Body.String())
+	})
+
+	t.Run("forbidden status when signature invalid", func(t *testing.T) {
+		mock.SetUp(t)
+
+		controller, announcer, _ := setUpController()
+		controller.secret = "other secret"
+		recorder := send(controller, "message id", twitch.NotificationMessage, "follow.json")
+
+		assert.Equal(t, http.StatusForbidden, recorder.Code)
+		mock.Verify(announcer, mock.Never()).AnnounceJoin(mock.Any[twitch.Id](), mock.Any[services.Pet]())
+	})
+
+	t.Run("forbidden status when message too old", func(t *testing.T) {
+		mock.SetUp(t)
+
+		controller, _, _ := setUpController()
+		controller.now = func() time.Time { return now.Add(time.Hour) }
+		recorder := send(controller, "message id", twitch.NotificationMessage, "follow.json")
+
+		assert.Equal(t, http.StatusForbidden, recorder.Code)
+	})
+
+	t.Run("chatter joins", func(t *testing.T) {
+		mock.SetUp(t)
+
+		channelId := twitch.Id("1971641")
+		userId := twitch.Id("4145994")
+		pet := services.Pet{UserId: userId, Username: "viewer32"}
+
+		controller, announcer, pets := setUpController()
+		mock.When(announcer.HasPet(channelId, userId)).ThenReturn(false)
+		mock.When(pets.GetPet(userId, channelId, "viewer32")).ThenReturn(pet, nil)
+
+		recorder := send(controller, "message id", twitch.NotificationMessage, "chat_message.json")
+
+		assert.Equal(t, http.StatusNoContent, recorder.Code)
+		mock.Verify(announcer, mock.Once()).AnnounceJoin(channelId, pet)
+		mock.Verify(announcer, mock.Never()).AnnounceAction(mock.Any[twitch.Id](), mock.Any[twitch.Id](), mock.AnyString())
+	})
+
+	t.Run("chat command announced as action for present pet", func(t *testing.T) {
+		mock.SetUp(t)
+
+		channelId := twitch.Id("1971641")
+		userId := twitch.Id("4145994")
+
+		controller, announcer, pets := setUpController()
+		mock.When(announcer.HasPet(channelId, userId)).ThenReturn(true)
+
+		recorder := send(controller, "message id", twitch.NotificationMessage, "chat_command.json")
+
+		assert.Equal(t, http.StatusNoContent, recorder.Code)
+		mock.Verify(pets, mock.Never()).GetPet(mock.Any[twitch.Id](), mock.Any[twitch.Id](), mock.AnyString())
+		mock.Verify(announcer, mock.Once()).AnnounceAction(channelId, userId, "jump")
+	})
+
+	notifications := map[string]struct {
+		payload   string
+		channelId twitch.Id
+		userId    twitch.Id
+		username  string
+		action    string
+	}{
+		"follower joins":   {"follow.json", "1337", "1234", "Cool_User", FollowAction},
+		"subscriber joins": {"subscribe.json", "1337", "1234", "Cool_User", SubscribeAction},
+		"raider joins":     {"raid.json", "1337", "1234", "Cool_User", RaidAction},
+	}
+
+	for name, test := range notifications {
+		t.Run(name, func(t *testing.T) {
+			mock.SetUp(t)
+
+			pet := services.Pet{UserId: test.userId, Username: test.username}
+
+			controller, announcer, pets := setUpController()
+			mock.When(announcer.HasPet(test.channelId, test.userId)).ThenReturn(false)
+			mock.When(pets.GetPet(test.userId, test.channelId, test.username)).ThenReturn(pet, nil)
+
+			recorder := send(controller, "message id", twitch.NotificationMessage, test.payload)
+
+			assert.Equal(t, http.StatusNoContent, recorder.Code)
+			mock.Verify(announcer, mock.Once()).AnnounceJoin(test.channelId, pet)
+			mock.Verify(announcer, mock.Once()).AnnounceAction(test.channelId, test.userId, test.action)
+		})
+	}
+
+	t.Run("duplicate messages handled once", func(t *testing.T) {
+		mock.SetUp(t)
+
+		controller, announcer, pets := setUpController()
+		mock.When(announcer.HasPet(twitch.Id("1337"), twitch.Id("1234"))).ThenReturn(true)
+
+		first := send(controller, "message id", twitch.NotificationMessage, "follow.json")
+		second := send(controller, "message id", twitch.NotificationMessage, "follow.json")
+
+		assert.Equal(t, http.StatusNoContent, first.Code)
+		assert.Equal(t, http.StatusNoContent, second.Code)
+		mock.Verify(pets, mock.Never()).GetPet(mock.Any[twitch.Id](), mock.Any[twitch.Id](), mock.AnyString())
+		mock.Verify(announcer, mock.Once()).AnnounceAction(twitch.Id("1337"), twitch.Id("1234"), FollowAction)
+	})
+
+	t.Run("failed messages handled again when retried", func(t *testing.T) {
+		mock.SetUp(t)
+
+		channelId := twitch.Id("1337")
+		userId := twitch.Id("1234")
+		pet := services.Pet{UserId: userId, Username: "Cool_User"}
+
+		controller, announcer, pets := setUpController()
+		mock.When(announcer.HasPet(channelId, userId)).ThenReturn(false)
+		mock.When(pets.GetPet(userId, channelId, "Cool_User")).
+			ThenReturn(services.Pet{}, assert.AnError).
+			ThenReturn(pet, nil)
+
+		failed := send(controller, "message id", twitch.NotificationMessage, "follow.json")
+		retried := send(controller, "message id", twitch.NotificationMessage, "follow.json")
+
+		assert.Equal(t, http.StatusInternalServerError, failed.Code)
+		assert.Equal(t, http.StatusNoContent, retried.Code)
+		mock.Verify(announcer, mock.Once()).AnnounceJoin(channelId, pet)
+	})
+}
+
+func TestChatAction(t *testing.T) {
+	tests := map[string]struct {
+		action string
+		ok     bool
+	}{
+		"!jump":       {"jump", true},
+		" !Jump high": {"jump", true},
+		"hello !jump": {"", false},
+		"!":           {"", false},
+		"! jump":      {"", false},
+	}
+
+	for text, expected := range tests {
+		action, ok := chatAction(text)
+		assert.Equal(t, expected.action, action, text)
+		assert.Equal(t, expected.ok, ok, text)
+	}
+}
+
+func TestMessageIds(t *testing.T) {
+	now := time.Now()
+
+	t.Run("ids claimed once within the window", func(t *testing.T) {
+		ids := newMessageIds()
+
+		assert.True(t, ids.claim("id", now))
+		assert.False(t, ids.claim("id", now.Add(eventSubDedupeWindow)))
+	})
+
+	t.Run("expired ids forgotten", func(t *testing.T) {
+		ids := newMessageIds()
+		ids.claim("old id", now)
+		ids.claim("new id", now.Add(time.Minute))
+
+		assert.True(t, ids.claim("other id", now.Add(eventSubDedupeWindow+time.Second)))
+		assert.Len(t, ids.ids, 2)
+		assert.Len(t, ids.order, 2)
+		assert.True(t, ids.claim("old id", now.Add(eventSubDedupeWindow+time.Second)))
+	})
+
+	t.Run("released id claimed again keeps its new claim", func(t *testing.T) {
+		ids := newMessageIds()
+		ids.claim("id", now)
+		ids.release("id")
+		ids.claim("id", now.Add(time.Minute))
+
+		// The first claim expires but the second has not.
+		assert.False(t, ids.claim("id", now.Add(eventSubDedupeWindow+time.Second)))
+	})
+}
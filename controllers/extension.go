@@ -1,7 +1,6 @@
 package controllers
 
 import (
-	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -26,24 +25,33 @@ type StoreService interface {
 	SetSelectedItem(userId, channelId twitch.Id, itemId uuid.UUID) error
 	GetChannelsItems(channelId twitch.Id) ([]models.Item, error)
 	GetOwnedItems(channelId, userId twitch.Id) ([]models.Item, error)
-	AddOwnedItem(userId twitch.Id, itemId, transactionId uuid.UUID) error
+	BuyItem(transaction models.Transaction, selectItem bool) (models.Item, error)
+	GetBoughtItem(transactionId uuid.UUID) (item models.Item, found bool, err error)
+}
+
+type RarityResolver interface {
+	GetRarityTiers(channelId twitch.Id) ([]models.RarityTier, error)
+	ResolveSku(channelId twitch.Id, sku string) (models.RarityTier, error)
 }
 
 type ExtensionController struct {
 	Announcer UpdateAnnouncer
 	Verifier  TokenVerifier
 	Store     StoreService
+	Rarities  RarityResolver
 }
 
 func NewExtensionController(
 	announcer UpdateAnnouncer,
 	verifier TokenVerifier,
 	store StoreService,
+	rarities RarityResolver,
 ) *ExtensionController {
 	return &ExtensionController{
 		Announcer: announcer,
 		Verifier:  verifier,
 		Store:     store,
+		Rarities:  rarities,
 	}
 }
 
@@ -65,6 +73,25 @@ func (c *ExtensionController) GetStoreData(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, storeItems)
 }
 
+// Lists the rarities of the channel's items, with the Bits product that buys each.
+func (c *ExtensionController) GetRarityTiers(ctx *gin.Context) {
+	tokenString := ctx.GetHeader(XExtensionJwt)
+
+	token, err := c.Verifier.VerifyExtToken(tokenString)
+	if err != nil {
+		addErrorToCtx(err, ctx)
+		return
+	}
+
+	tiers, err := c.Rarities.GetRarityTiers(token.ChannelId)
+	if err != nil {
+		addErrorToCtx(err, ctx)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, tiers)
+}
+
 func (c *ExtensionController) GetUserData(ctx *gin.Context) {
 	tokenString := ctx.GetHeader(XExtensionJwt)
 
@@ -96,6 +123,8 @@ func (c *ExtensionController) BuyStoreItem(ctx *gin.Context) {
 	type Params struct {
 		Receipt string `json:"receipt"`
 		ItemId  string `json:"item_id"`
+		// Selects the item for the viewer once it is bought.
+		Select bool `json:"select"`
 	}
 
 	tokenString := ctx.GetHeader(XExtensionJwt)
@@ -107,7 +136,13 @@ func (c *ExtensionController) BuyStoreItem(ctx *gin.Context) {
 
 	var params Params
 	if err := ctx.ShouldBindJSON(&params); err != nil {
-		addErrorToCtx(err, ctx)
+		addErrorToCtx(invalidRequest(err), ctx)
+		return
+	}
+
+	itemId, err := uuid.Parse(params.ItemId)
+	if err != nil {
+		addErrorToCtx(invalidRequest(err), ctx)
 		return
 	}
 
@@ -117,11 +152,22 @@ func (c *ExtensionController) BuyStoreItem(ctx *gin.Context) {
 		return
 	}
 
-	itemId, err := uuid.Parse(params.ItemId)
+	if receipt.Data.UserId != token.UserId || receipt.Data.ChannelId != token.ChannelId {
+		addErrorToCtx(services.ErrReceiptMismatch, ctx)
+		return
+	}
+
+	// Retried receipts get the original purchase back, even if the streamer
+	// has changed the item or its price since.
+	bought, replayed, err := c.Store.GetBoughtItem(receipt.Data.TransactionId)
 	if err != nil {
 		addErrorToCtx(err, ctx)
 		return
 	}
+	if replayed {
+		ctx.JSON(http.StatusOK, bought)
+		return
+	}
 
 	item, err := c.Store.GetItemById(itemId)
 	if err != nil {
@@ -129,15 +175,39 @@ func (c *ExtensionController) BuyStoreItem(ctx *gin.Context) {
 		return
 	}
 
-	if item.Rarity != receipt.Data.Product.Rarity {
-		addErrorToCtx(errors.New("receipt and item rarity do not match"), ctx)
+	tier, err := c.Rarities.ResolveSku(token.ChannelId, receipt.Data.Product.Sku)
+	if err != nil {
+		addErrorToCtx(err, ctx)
+		return
+	}
+
+	if item.Rarity != tier.Name {
+		addErrorToCtx(services.ErrRarityMismatch, ctx)
+		return
+	}
+
+	// The price may have changed since the viewer was shown it.
+	if receipt.Data.Product.Cost.Amount != tier.BitsCost {
+		addErrorToCtx(services.ErrCostMismatch, ctx)
 		return
 	}
 
-	if err := c.Store.AddOwnedItem(token.UserId, itemId, receipt.Data.TransactionId); err != nil {
+	bought, err = c.Store.BuyItem(models.Transaction{
+		TransactionId: receipt.Data.TransactionId,
+		UserId:        token.UserId,
+		ChannelId:     token.ChannelId,
+		ItemId:        itemId,
+		Sku:           receipt.Data.Product.Sku,
+		BitsCost:      receipt.Data.Product.Cost.Amount,
+		PurchasedAt:   receipt.Data.Time,
+		Claims:        string(receipt.Claims),
+	}, params.Select)
+	if err != nil {
 		addErrorToCtx(err, ctx)
 		return
 	}
+
+	ctx.JSON(http.StatusOK, bought)
 }
 
 func (c *ExtensionController) SetSelectedItem(ctx *gin.Context) {
@@ -154,13 +224,13 @@ func (c *ExtensionController) SetSelectedItem(ctx *gin.Context) {
 
 	var params Params
 	if err := ctx.ShouldBindJSON(&params); err != nil {
-		addErrorToCtx(err, ctx)
+		addErrorToCtx(invalidRequest(err), ctx)
 		return
 	}
 
 	itemId, err := uuid.Parse(params.ItemId)
 	if err != nil {
-		addErrorToCtx(err, ctx)
+		addErrorToCtx(invalidRequest(err), ctx)
 		return
 	}
 
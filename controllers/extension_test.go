@@ -5,9 +5,11 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -49,6 +51,7 @@ func TestGetStoreData(t *testing.T) {
 			announcerMock,
 			verifierMock,
 			storeMock,
+			mock.Mock[RarityResolver](),
 		)
 
 		ctx, recorder := setUpContext(tokenString)
@@ -78,6 +81,7 @@ func TestGetStoreData(t *testing.T) {
 			announcerMock,
 			verifierMock,
 			storeMock,
+			mock.Mock[RarityResolver](),
 		)
 
 		ctx, recorder := setUpContext(tokenString)
@@ -107,6 +111,7 @@ func TestGetStoreData(t *testing.T) {
 			announcerMock,
 			verifierMock,
 			storeMock,
+			mock.Mock[RarityResolver](),
 		)
 
 		ctx, recorder := setUpContext(tokenString)
@@ -126,6 +131,36 @@ func TestGetStoreData(t *testing.T) {
 	})
 }
 
+func TestGetRarityTiers(t *testing.T) {
+	mock.SetUp(t)
+
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+	ctx.Request, _ = http.NewRequest("GET", "/extension/rarities", nil)
+	ctx.Request.Header.Add("x-extension-jwt", "token string")
+
+	channelId := twitch.Id("channel id")
+	tiers := []models.RarityTier{{Name: "legendary", Sku: "legendary_pet", BitsCost: 1000, Colour: "#ffa500", SortOrder: 3}}
+
+	verifierMock := mock.Mock[TokenVerifier]()
+	rarityMock := mock.Mock[RarityResolver]()
+
+	mock.When(verifierMock.VerifyExtToken("token string")).ThenReturn(&services.ExtToken{ChannelId: channelId}, nil)
+	mock.When(rarityMock.GetRarityTiers(channelId)).ThenReturn(tiers, nil)
+
+	controller := NewExtensionController(mock.Mock[UpdateAnnouncer](), verifierMock, mock.Mock[StoreService](), rarityMock)
+	controller.GetRarityTiers(ctx)
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	var actual []models.RarityTier
+	if err := json.Unmarshal(recorder.Body.Bytes(), &actual); err != nil {
+		t.Errorf("could not parse json response")
+	}
+	assert.Equal(t, tiers, actual)
+}
+
 func TestGetUserData(t *testing.T) {
 	setUpContext := func(tokenString string) (*gin.Context, *httptest.ResponseRecorder) {
 		gin.SetMode(gin.TestMode)
@@ -155,6 +190,7 @@ func TestGetUserData(t *testing.T) {
 			announcerMock,
 			verifierMock,
 			storeMock,
+			mock.Mock[RarityResolver](),
 		)
 
 		ctx, recorder := setUpContext(tokenString)
@@ -186,6 +222,7 @@ func TestGetUserData(t *testing.T) {
 			announcerMock,
 			verifierMock,
 			storeMock,
+			mock.Mock[RarityResolver](),
 		)
 
 		ctx, recorder := setUpContext(tokenString)
@@ -217,6 +254,7 @@ func TestGetUserData(t *testing.T) {
 			announcerMock,
 			verifierMock,
 			storeMock,
+			mock.Mock[RarityResolver](),
 		)
 
 		ctx, recorder := setUpContext(tokenString)
@@ -257,6 +295,7 @@ func TestGetUserData(t *testing.T) {
 			announcerMock,
 			verifierMock,
 			storeMock,
+			mock.Mock[RarityResolver](),
 		)
 
 		ctx, recorder := setUpContext(tokenString)
@@ -273,7 +312,7 @@ func TestGetUserData(t *testing.T) {
 }
 
 func TestBuyStoreItem(t *testing.T) {
-	setUpContext := func(token, receipt, itemId string) *gin.Context {
+	setUpRecordedContext := func(token, receipt, itemId string) (*gin.Context, *httptest.ResponseRecorder) {
 		gin.SetMode(gin.TestMode)
 
 		jsonData := []byte(fmt.Sprintf(`{
@@ -281,23 +320,26 @@ func TestBuyStoreItem(t *testing.T) {
 			"item_id": "%s"
 		}`, receipt, itemId))
 
-		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+		recorder := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(recorder)
 		req, _ := http.NewRequest("POST", "/items", bytes.NewBuffer(jsonData))
 
 		req.Header.Set("Content-Type", "application/json; charset=UTF-8")
 		req.Header.Add("x-extension-jwt", token)
 
 		ctx.Request = req
+		return ctx, recorder
+	}
+
+	setUpContext := func(token, receipt, itemId string) *gin.Context {
+		ctx, _ := setUpRecordedContext(token, receipt, itemId)
 		return ctx
 	}
 
 	t.Run("item not added when extension token is invalid", func(t *testing.T) {
 		mock.SetUp(t)
 
-		userId := twitch.Id("user id")
-
 		itemId := uuid.New()
-		transactionId := uuid.New()
 
 		tokenString := "token string"
 		receiptString := "receipt string"
@@ -312,11 +354,12 @@ func TestBuyStoreItem(t *testing.T) {
 			announcerMock,
 			verifierMock,
 			storeMock,
+			mock.Mock[RarityResolver](),
 		)
 
 		extController.BuyStoreItem(setUpContext(tokenString, receiptString, itemId.String()))
 
-		mock.Verify(storeMock, mock.Never()).AddOwnedItem(userId, itemId, transactionId)
+		mock.Verify(storeMock, mock.Never()).BuyItem(mock.Any[models.Transaction](), mock.Any[bool]())
 	})
 
 	t.Run("item not added when item id is not a valid uuid", func(t *testing.T) {
@@ -335,6 +378,7 @@ func TestBuyStoreItem(t *testing.T) {
 			announcerMock,
 			verifierMock,
 			storeMock,
+			mock.Mock[RarityResolver](),
 		)
 
 		extController.BuyStoreItem(setUpContext(tokenString, receiptString, itemId))
@@ -343,10 +387,7 @@ func TestBuyStoreItem(t *testing.T) {
 	t.Run("item not added when item id does not exist", func(t *testing.T) {
 		mock.SetUp(t)
 
-		userId := twitch.Id("user id")
-
 		itemId := uuid.New()
-		transactionId := uuid.New()
 
 		tokenString := "token string"
 		receiptString := "receipt string"
@@ -355,26 +396,26 @@ func TestBuyStoreItem(t *testing.T) {
 		verifierMock := mock.Mock[TokenVerifier]()
 		storeMock := mock.Mock[StoreService]()
 
+		mock.When(verifierMock.VerifyExtToken(tokenString)).ThenReturn(&services.ExtToken{}, nil)
+		mock.When(verifierMock.VerifyReceipt(receiptString)).ThenReturn(&services.Receipt{}, nil)
 		mock.When(storeMock.GetItemById(itemId)).ThenReturn(nil, ErrTestError)
 
 		extController := NewExtensionController(
 			announcerMock,
 			verifierMock,
 			storeMock,
+			mock.Mock[RarityResolver](),
 		)
 
 		extController.BuyStoreItem(setUpContext(tokenString, receiptString, itemId.String()))
 
-		mock.Verify(storeMock, mock.Never()).AddOwnedItem(userId, itemId, transactionId)
+		mock.Verify(storeMock, mock.Never()).BuyItem(mock.Any[models.Transaction](), mock.Any[bool]())
 	})
 
 	t.Run("item not added when receipt is invalid", func(t *testing.T) {
 		mock.SetUp(t)
 
-		userId := twitch.Id("user id")
-
 		itemId := uuid.New()
-		transactionId := uuid.New()
 
 		tokenString := "token string"
 		receiptString := "receipt string"
@@ -389,11 +430,12 @@ func TestBuyStoreItem(t *testing.T) {
 			announcerMock,
 			verifierMock,
 			storeMock,
+			mock.Mock[RarityResolver](),
 		)
 
 		extController.BuyStoreItem(setUpContext(tokenString, receiptString, itemId.String()))
 
-		mock.Verify(storeMock, mock.Never()).AddOwnedItem(userId, itemId, transactionId)
+		mock.Verify(storeMock, mock.Never()).BuyItem(mock.Any[models.Transaction](), mock.Any[bool]())
 	})
 
 	t.Run("item not added when receipt and item rarity do not match", func(t *testing.T) {
@@ -402,8 +444,6 @@ func TestBuyStoreItem(t *testing.T) {
 		tokenString := "token string"
 		receiptString := "receipt string"
 
-		userId := twitch.Id("user id")
-
 		itemId := uuid.New()
 		transactionId := uuid.New()
 
@@ -415,8 +455,9 @@ func TestBuyStoreItem(t *testing.T) {
 		receipt := &services.Receipt{
 			Data: services.Data{
 				TransactionId: transactionId,
+				ChannelId:     "channel id",
 				Product: services.Product{
-					Rarity: models.Uncommon,
+					Sku: "uncommon sku",
 				},
 			},
 		}
@@ -424,43 +465,183 @@ func TestBuyStoreItem(t *testing.T) {
 		announcerMock := mock.Mock[UpdateAnnouncer]()
 		verifierMock := mock.Mock[TokenVerifier]()
 		storeMock := mock.Mock[StoreService]()
+		rarityMock := mock.Mock[RarityResolver]()
 
+		mock.When(verifierMock.VerifyExtToken(tokenString)).ThenReturn(&services.ExtToken{ChannelId: "channel id"}, nil)
 		mock.When(storeMock.GetItemById(itemId)).ThenReturn(item, nil)
 		mock.When(verifierMock.VerifyReceipt(receiptString)).ThenReturn(receipt, nil)
+		mock.When(rarityMock.ResolveSku(twitch.Id("channel id"), "uncommon sku")).ThenReturn(models.RarityTier{Name: models.Uncommon, Sku: "uncommon sku"}, nil)
 
 		extController := NewExtensionController(
 			announcerMock,
 			verifierMock,
 			storeMock,
+			rarityMock,
 		)
 
 		extController.BuyStoreItem(setUpContext(tokenString, receiptString, itemId.String()))
 
-		mock.Verify(storeMock, mock.Never()).AddOwnedItem(userId, itemId, transactionId)
+		mock.Verify(storeMock, mock.Never()).BuyItem(mock.Any[models.Transaction](), mock.Any[bool]())
 	})
 
-	t.Run("item added when all pre-requisites are met", func(t *testing.T) {
+	t.Run("item not added when no rarity is sold under the receipt's sku", func(t *testing.T) {
+		mock.SetUp(t)
+
+		tokenString := "token string"
+		receiptString := "receipt string"
+		itemId := uuid.New()
+
+		receipt := &services.Receipt{Data: services.Data{ChannelId: "channel id", Product: services.Product{Sku: "unknown sku"}}}
+
+		verifierMock := mock.Mock[TokenVerifier]()
+		storeMock := mock.Mock[StoreService]()
+		rarityMock := mock.Mock[RarityResolver]()
+
+		mock.When(verifierMock.VerifyExtToken(tokenString)).ThenReturn(&services.ExtToken{ChannelId: "channel id"}, nil)
+		mock.When(verifierMock.VerifyReceipt(receiptString)).ThenReturn(receipt, nil)
+		mock.When(storeMock.GetItemById(itemId)).ThenReturn(models.Item{ItemId: itemId, Rarity: models.Common}, nil)
+		mock.When(rarityMock.ResolveSku(twitch.Id("channel id"), "unknown sku")).ThenReturn(models.RarityTier{}, services.ErrUnknownSku)
+
+		extController := NewExtensionController(
+			mock.Mock[UpdateAnnouncer](),
+			verifierMock,
+			storeMock,
+			rarityMock,
+		)
+
+		ctx, recorder := setUpRecordedContext(tokenString, receiptString, itemId.String())
+		extController.BuyStoreItem(ctx)
+
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+		mock.Verify(storeMock, mock.Never()).BuyItem(mock.Any[models.Transaction](), mock.Any[bool]())
+	})
+
+	t.Run("item not added when receipt cost does not match the rarity's price", func(t *testing.T) {
+		mock.SetUp(t)
+
+		tokenString := "token string"
+		receiptString := "receipt string"
+		itemId := uuid.New()
+
+		token := &services.ExtToken{UserId: "user id", ChannelId: "channel id"}
+		receipt := &services.Receipt{
+			Data: services.Data{
+				TransactionId: uuid.New(),
+				UserId:        "user id",
+				ChannelId:     "channel id",
+				Product: services.Product{
+					Sku:  "common sku",
+					Cost: services.Cost{Amount: 100, Type: "bits"},
+				},
+			},
+		}
+
+		verifierMock := mock.Mock[TokenVerifier]()
+		storeMock := mock.Mock[StoreService]()
+		rarityMock := mock.Mock[RarityResolver]()
+
+		mock.When(verifierMock.VerifyExtToken(tokenString)).ThenReturn(token, nil)
+		mock.When(verifierMock.VerifyReceipt(receiptString)).ThenReturn(receipt, nil)
+		mock.When(storeMock.GetItemById(itemId)).ThenReturn(models.Item{ItemId: itemId, Rarity: models.Common}, nil)
+		mock.When(rarityMock.ResolveSku(token.ChannelId, "common sku")).ThenReturn(models.RarityTier{Name: models.Common, Sku: "common sku", BitsCost: 250}, nil)
+
+		extController := NewExtensionController(
+			mock.Mock[UpdateAnnouncer](),
+			verifierMock,
+			storeMock,
+			rarityMock,
+		)
+
+		ctx, recorder := setUpRecordedContext(tokenString, receiptString, itemId.String())
+		extController.BuyStoreItem(ctx)
+
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), "cost_mismatch")
+		mock.Verify(storeMock, mock.Never()).BuyItem(mock.Any[models.Transaction](), mock.Any[bool]())
+	})
+
+	t.Run("original item returned when receipt replayed after price change", func(t *testing.T) {
+		mock.SetUp(t)
+
+		tokenString := "token string"
+		receiptString := "receipt string"
+		itemId := uuid.New()
+		transactionId := uuid.New()
+
+		token := &services.ExtToken{UserId: "user id", ChannelId: "channel id"}
+		receipt := &services.Receipt{
+			Data: services.Data{
+				TransactionId: transactionId,
+				UserId:        "user id",
+				ChannelId:     "channel id",
+				Product: services.Product{
+					Sku:  "common sku",
+					Cost: services.Cost{Amount: 100, Type: "bits"},
+				},
+			},
+		}
+		item := models.Item{ItemId: itemId, Rarity: models.Common}
+
+		verifierMock := mock.Mock[TokenVerifier]()
+		storeMock := mock.Mock[StoreService]()
+		rarityMock := mock.Mock[RarityResolver]()
+
+		mock.When(verifierMock.VerifyExtToken(tokenString)).ThenReturn(token, nil)
+		mock.When(verifierMock.VerifyReceipt(receiptString)).ThenReturn(receipt, nil)
+		mock.When(storeMock.GetBoughtItem(transactionId)).ThenReturn(item, true, nil)
+		// The streamer has raised the price since the item was bought.
+		mock.When(rarityMock.ResolveSku(token.ChannelId, "common sku")).ThenReturn(models.RarityTier{Name: models.Common, Sku: "common sku", BitsCost: 250}, nil)
+
+		extController := NewExtensionController(
+			mock.Mock[UpdateAnnouncer](),
+			verifierMock,
+			storeMock,
+			rarityMock,
+		)
+
+		ctx, recorder := setUpRecordedContext(tokenString, receiptString, itemId.String())
+		extController.BuyStoreItem(ctx)
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+
+		var actual models.Item
+		if err := json.Unmarshal(recorder.Body.Bytes(), &actual); err != nil {
+			t.Errorf("could not parse json response")
+		}
+		assert.Equal(t, item, actual)
+		mock.Verify(storeMock, mock.Never()).BuyItem(mock.Any[models.Transaction](), mock.Any[bool]())
+	})
+
+	t.Run("item bought when all pre-requisites are met", func(t *testing.T) {
 		mock.SetUp(t)
 
 		tokenString := "token string"
 		receiptString := "receipt string"
 
 		userId := twitch.Id("user id")
+		channelId := twitch.Id("channel id")
+		purchasedAt := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
 
 		itemId := uuid.New()
 		transactionId := uuid.New()
 
 		token := &services.ExtToken{
-			UserId: userId,
+			UserId:    userId,
+			ChannelId: channelId,
 		}
 
 		receipt := &services.Receipt{
 			Data: services.Data{
 				TransactionId: transactionId,
+				Time:          purchasedAt,
+				UserId:        userId,
+				ChannelId:     channelId,
 				Product: services.Product{
-					Rarity: models.Common,
+					Sku:  "common sku",
+					Cost: services.Cost{Amount: 100, Type: "bits"},
 				},
 			},
+			Claims: []byte(`{"data":{}}`),
 		}
 
 		item := models.Item{
@@ -471,20 +652,120 @@ func TestBuyStoreItem(t *testing.T) {
 		announcerMock := mock.Mock[UpdateAnnouncer]()
 		verifierMock := mock.Mock[TokenVerifier]()
 		storeMock := mock.Mock[StoreService]()
+		rarityMock := mock.Mock[RarityResolver]()
 
 		mock.When(verifierMock.VerifyExtToken(tokenString)).ThenReturn(token, nil)
 		mock.When(verifierMock.VerifyReceipt(receiptString)).ThenReturn(receipt, nil)
 		mock.When(storeMock.GetItemById(itemId)).ThenReturn(item, nil)
+		mock.When(storeMock.BuyItem(mock.Any[models.Transaction](), mock.Any[bool]())).ThenReturn(item, nil)
+		mock.When(rarityMock.ResolveSku(channelId, "common sku")).ThenReturn(models.RarityTier{Name: models.Common, Sku: "common sku", BitsCost: 100}, nil)
 
 		extController := NewExtensionController(
 			announcerMock,
 			verifierMock,
 			storeMock,
+			rarityMock,
 		)
 
-		extController.BuyStoreItem(setUpContext(tokenString, receiptString, itemId.String()))
+		ctx, recorder := setUpRecordedContext(tokenString, receiptString, itemId.String())
+		extController.BuyStoreItem(ctx)
+
+		mock.Verify(storeMock, mock.Once()).BuyItem(models.Transaction{
+			TransactionId: transactionId,
+			UserId:        userId,
+			ChannelId:     channelId,
+			ItemId:        itemId,
+			Sku:           "common sku",
+			BitsCost:      100,
+			PurchasedAt:   purchasedAt,
+			Claims:        `{"data":{}}`,
+		}, false)
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+
+		var actual models.Item
+		if err := json.Unmarshal(recorder.Body.Bytes(), &actual); err != nil {
+			t.Errorf("could not parse json response")
+		}
+		assert.Equal(t, item, actual)
+	})
+
+	t.Run("bought item selected when asked to", func(t *testing.T) {
+		mock.SetUp(t)
+
+		itemId := uuid.New()
+		token := &services.ExtToken{UserId: "user id", ChannelId: "channel id"}
+		receipt := &services.Receipt{
+			Data: services.Data{
+				TransactionId: uuid.New(),
+				UserId:        "user id",
+				ChannelId:     "channel id",
+				Product:       services.Product{Sku: "common sku"},
+			},
+		}
+		item := models.Item{ItemId: itemId, Rarity: models.Common}
+
+		verifierMock := mock.Mock[TokenVerifier]()
+		storeMock := mock.Mock[StoreService]()
+		rarityMock := mock.Mock[RarityResolver]()
+
+		mock.When(verifierMock.VerifyExtToken("token string")).ThenReturn(token, nil)
+		mock.When(verifierMock.VerifyReceipt("receipt string")).ThenReturn(receipt, nil)
+		mock.When(storeMock.GetItemById(itemId)).ThenReturn(item, nil)
+		mock.When(storeMock.BuyItem(mock.Any[models.Transaction](), mock.Any[bool]())).ThenReturn(item, nil)
+		mock.When(rarityMock.ResolveSku(token.ChannelId, "common sku")).ThenReturn(models.RarityTier{Name: models.Common}, nil)
+
+		extController := NewExtensionController(mock.Mock[UpdateAnnouncer](), verifierMock, storeMock, rarityMock)
+
+		ctx, recorder := setUpRecordedContext("token string", "receipt string", itemId.String())
+		ctx.Request.Body = io.NopCloser(bytes.NewBufferString(fmt.Sprintf(`{"receipt": "receipt string", "item_id": "%s", "select": true}`, itemId)))
+		extController.BuyStoreItem(ctx)
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		mock.Verify(storeMock, mock.Once()).BuyItem(mock.Any[models.Transaction](), mock.Equal(true))
+	})
+
+	t.Run("item not bought when receipt belongs to another user or channel", func(t *testing.T) {
+		tests := map[string]services.Data{
+			"other user":    {UserId: "other user id", ChannelId: "channel id"},
+			"other channel": {UserId: "user id", ChannelId: "other channel id"},
+		}
+
+		for name, data := range tests {
+			t.Run(name, func(t *testing.T) {
+				mock.SetUp(t)
+
+				tokenString := "token string"
+				receiptString := "receipt string"
+				itemId := uuid.New()
+
+				token := &services.ExtToken{UserId: "user id", ChannelId: "channel id"}
+				data.Product.Sku = "common sku"
+				receipt := &services.Receipt{Data: data}
+
+				verifierMock := mock.Mock[TokenVerifier]()
+				storeMock := mock.Mock[StoreService]()
+				rarityMock := mock.Mock[RarityResolver]()
 
-		mock.Verify(storeMock, mock.Once()).AddOwnedItem(userId, itemId, transactionId)
+				mock.When(verifierMock.VerifyExtToken(tokenString)).ThenReturn(token, nil)
+				mock.When(verifierMock.VerifyReceipt(receiptString)).ThenReturn(receipt, nil)
+				mock.When(storeMock.GetItemById(itemId)).ThenReturn(models.Item{ItemId: itemId, Rarity: models.Common}, nil)
+				mock.When(rarityMock.ResolveSku(twitch.Id("channel id"), "common sku")).ThenReturn(models.RarityTier{Name: models.Common, Sku: "common sku"}, nil)
+
+				extController := NewExtensionController(
+					mock.Mock[UpdateAnnouncer](),
+					verifierMock,
+					storeMock,
+					rarityMock,
+				)
+
+				ctx, recorder := setUpRecordedContext(tokenString, receiptString, itemId.String())
+				extController.BuyStoreItem(ctx)
+
+				assert.Equal(t, http.StatusForbidden, recorder.Code)
+				mock.Verify(storeMock, mock.Never()).BuyItem(mock.Any[models.Transaction](), mock.Any[bool]())
+			})
+		}
 	})
 }
 
@@ -526,6 +807,7 @@ func TestSetSelectedItem(t *testing.T) {
 			announcerMock,
 			verifierMock,
 			storeMock,
+			mock.Mock[RarityResolver](),
 		)
 
 		controller.SetSelectedItem(setUpContext(tokenString, itemId.String()))
@@ -551,6 +833,7 @@ func TestSetSelectedItem(t *testing.T) {
 			announcerMock,
 			verifierMock,
 			storeMock,
+			mock.Mock[RarityResolver](),
 		)
 
 		controller.SetSelectedItem(setUpContext(tokenString, itemId))
@@ -578,6 +861,7 @@ func TestSetSelectedItem(t *testing.T) {
 			announcerMock,
 			verifierMock,
 			storeMock,
+			mock.Mock[RarityResolver](),
 		)
 
 		controller.SetSelectedItem(setUpContext(tokenString, itemId.String()))
@@ -611,6 +895,7 @@ func TestSetSelectedItem(t *testing.T) {
 			announcerMock,
 			verifierMock,
 			storeMock,
+			mock.Mock[RarityResolver](),
 		)
 
 		controller.SetSelectedItem(setUpContext(tokenString, itemId.String()))
@@ -647,6 +932,7 @@ func TestSetSelectedItem(t *testing.T) {
 			announcerMock,
 			verifierMock,
 			storeMock,
+			mock.Mock[RarityResolver](),
 		)
 
 		controller.SetSelectedItem(setUpContext(tokenString, itemId.String()))
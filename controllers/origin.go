@@ -0,0 +1,39 @@
+package controllers
+
+import (
+	"log/slog"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+	"github.com/streampets/backend/apperrors"
+)
+
+var ErrForbiddenOrigin = apperrors.New(apperrors.Forbidden, "forbidden_origin", "request did not come from an allowed origin")
+
+// Rejects requests whose Origin header is not the origin of allowedUrl. The
+// dashboard's session cookie is sent cross-site, so requests that change
+// anything are only accepted from the dashboard itself. Browsers always send
+// Origin with cross-origin requests that are not GETs, so requests without
+// one are rejected too.
+func RequireOrigin(allowedUrl string) gin.HandlerFunc {
+	allowed := origin(allowedUrl)
+
+	return func(ctx *gin.Context) {
+		if got := ctx.GetHeader("Origin"); allowed == "" || got != allowed {
+			slog.Debug("request from forbidden origin", "path", ctx.FullPath(), "origin", got)
+			addErrorToCtx(ErrForbiddenOrigin, ctx)
+			return
+		}
+
+		ctx.Next()
+	}
+}
+
+// Returns the scheme and host of rawUrl, as browsers send them in Origin.
+func origin(rawUrl string) string {
+	parsed, err := url.Parse(rawUrl)
+	if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+		return ""
+	}
+	return parsed.Scheme + "://" + parsed.Host
+}
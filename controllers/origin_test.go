@@ -0,0 +1,46 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequireOrigin(t *testing.T) {
+	tests := map[string]struct {
+		allowedUrl string
+		origin     string
+		allowed    bool
+	}{
+		"dashboard allowed":                   {"https://dashboard.example", "https://dashboard.example", true},
+		"path of dashboard url ignored":       {"https://dashboard.example/app/", "https://dashboard.example", true},
+		"port of dashboard url kept":          {"http://localhost:5173", "http://localhost:5173", true},
+		"other site forbidden":                {"https://dashboard.example", "https://evil.example", false},
+		"other scheme forbidden":              {"https://dashboard.example", "http://dashboard.example", false},
+		"missing origin forbidden":            {"https://dashboard.example", "", false},
+		"null origin forbidden":               {"https://dashboard.example", "null", false},
+		"everything forbidden when url unset": {"", "", false},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			recorder := httptest.NewRecorder()
+			ctx, _ := gin.CreateTestContext(recorder)
+			ctx.Request, _ = http.NewRequest("POST", "/dashboard/logout", nil)
+			if test.origin != "" {
+				ctx.Request.Header.Set("Origin", test.origin)
+			}
+
+			RequireOrigin(test.allowedUrl)(ctx)
+
+			assert.Equal(t, !test.allowed, ctx.IsAborted())
+			if !test.allowed {
+				assert.Equal(t, http.StatusForbidden, recorder.Code)
+			}
+		})
+	}
+}
@@ -1,17 +1,35 @@
 package controllers
 
 import (
+	"encoding/json"
 	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
 	"time"
 
+	"github.com/gin-contrib/sse"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
 	"github.com/streampets/backend/announcers"
+	"github.com/streampets/backend/apperrors"
+	"github.com/streampets/backend/services"
 	"github.com/streampets/backend/twitch"
 )
 
+const heartbeatInterval = 60 * time.Second
+
+// WebSocket overlays are pinged every pingInterval and dropped when nothing,
+// not even a pong, is heard from them within readTimeout. Writes that take
+// longer than writeTimeout also drop the overlay.
+const pingInterval = 30 * time.Second
+const readTimeout = 2 * pingInterval
+const writeTimeout = 10 * time.Second
+
 type clientAddRemover interface {
 	AddClient(channelId twitch.Id) announcers.Client
+	ResumeClient(channelId twitch.Id, lastEventId string) announcers.Client
 	RemoveClient(client announcers.Client)
 }
 
@@ -19,57 +37,320 @@ type OverlayIdVerifier interface {
 	VerifyOverlayId(channelId twitch.Id, overlayId uuid.UUID) error
 }
 
+type PositionReporter interface {
+	ReportPosition(channelId, userId twitch.Id, position services.Position)
+}
+
+// Shares revoked overlay ids between backend instances.
+type revocationBroker interface {
+	Publish(announcement announcers.Announcement) error
+	Subscribe(handler func(announcers.Announcement))
+}
+
 type OverlayController struct {
-	announcer clientAddRemover
-	Overlay   OverlayIdVerifier
+	announcer    clientAddRemover
+	Overlay      OverlayIdVerifier
+	Reporter     PositionReporter
+	broker       revocationBroker
+	upgrader     websocket.Upgrader
+	connections  *overlayConnections
+	pingInterval time.Duration
+	readTimeout  time.Duration
 }
 
 func NewOverlayController(
 	announcer clientAddRemover,
 	overlay OverlayIdVerifier,
+	reporter PositionReporter,
+	broker revocationBroker,
 ) *OverlayController {
-	return &OverlayController{
+	controller := &OverlayController{
 		announcer: announcer,
 		Overlay:   overlay,
+		Reporter:  reporter,
+		broker:    broker,
+		upgrader: websocket.Upgrader{
+			// Overlays are hosted on another origin. Access is granted by the
+			// overlay id, the same as for the event stream.
+			CheckOrigin: func(r *http.Request) bool { return true },
+		},
+		connections:  newOverlayConnections(),
+		pingInterval: pingInterval,
+		readTimeout:  readTimeout,
 	}
+	broker.Subscribe(controller.handleRevocation)
+	return controller
+}
+
+// Disconnects the overlays connected with the given overlay id to any
+// instance, for when it was revoked.
+func (c *OverlayController) DisconnectOverlay(channelId twitch.Id, overlayId uuid.UUID) {
+	if err := c.broker.Publish(announcers.OverlayRevokedAnnouncement(channelId, overlayId)); err != nil {
+		// Overlays connected elsewhere still notice at their next heartbeat,
+		// when the overlay id is checked again.
+		slog.Error("error when publishing revoked overlay id", "channel_id", channelId, "err", err.Error())
+		c.connections.revoke(channelId, overlayId)
+	}
+}
+
+func (c *OverlayController) handleRevocation(a announcers.Announcement) {
+	if payload, ok := a.Message.(announcers.OverlayRevokedPayload); ok {
+		c.connections.revoke(payload.ChannelId, payload.OverlayId)
+	}
+}
+
+// A message sent to an overlay over a WebSocket.
+type overlayEvent struct {
+	Id    string      `json:"id,omitempty"`
+	Event string      `json:"event"`
+	Data  interface{} `json:"data"`
+}
+
+// A message sent by an overlay over a WebSocket.
+type overlayReport struct {
+	Type     string            `json:"type"`
+	Id       string            `json:"id"`
+	UserId   twitch.Id         `json:"user_id"`
+	Position services.Position `json:"position"`
 }
 
+const (
+	AckReport      string = "ack"
+	PositionReport string = "position"
+)
+
+// Streams events to an overlay. Overlays choose the event protocol with the
+// version query parameter and the version used is echoed in a response header.
 func (c *OverlayController) HandleListen(ctx *gin.Context) {
-	channelId := twitch.Id(ctx.Query(ChannelId))
-	overlayId, err := uuid.Parse(ctx.Query(OverlayId))
-	if err != nil {
-		addErrorToCtx(err, ctx)
+	channelId, overlayId, ok := c.verifyOverlay(ctx)
+	if !ok {
 		return
 	}
 
-	if err := c.Overlay.VerifyOverlayId(channelId, overlayId); err != nil {
-		addErrorToCtx(err, ctx)
+	version, ok := protocolVersion(ctx)
+	if !ok {
 		return
 	}
+	ctx.Header(ProtocolVersionHeader, strconv.Itoa(int(version)))
 
-	client := c.announcer.AddClient(channelId)
-	defer func() {
-		go func() {
-			for range client.Stream {
-			}
-		}()
-		c.announcer.RemoveClient(client)
-	}()
+	client := c.addClient(channelId, lastEventId(ctx))
+	defer c.removeClient(client)
 
-	ticker := time.NewTicker(60 * time.Second)
+	connection := c.connections.add(channelId, overlayId)
+	defer c.connections.remove(connection)
+
+	ticker := time.NewTicker(heartbeatInterval)
 	defer ticker.Stop()
 
 	ctx.Stream(func(w io.Writer) bool {
 		select {
 		case announcement, ok := <-client.Stream:
 			if ok {
-				ctx.SSEvent(announcement.Event, announcement.Message)
+				event, data := announcement.Encode(version)
+				ctx.Render(-1, sse.Event{
+					Id:    announcement.Id,
+					Event: event,
+					Data:  data,
+				})
 				return true
 			}
 			return false
-		case <-ticker.C:
-			ctx.SSEvent("heartbeat", "ping")
+		case now := <-ticker.C:
+			if c.revoked(channelId, overlayId) {
+				return false
+			}
+			ctx.SSEvent(announcers.EncodeHeartbeat(version, now))
 			return true
+		case <-connection.revoked:
+			return false
 		}
 	})
 }
+
+// Serves the same events as HandleListen over a WebSocket, for overlay hosts
+// that handle long-lived event streams badly. Overlays can also report where
+// their pets are, and ack the events they handled. Overlays that reconnect
+// pass the id of the last event they handled, as for the event stream, or
+// resume after the last event they acked.
+func (c *OverlayController) HandleWebSocket(ctx *gin.Context) {
+	channelId, overlayId, ok := c.verifyOverlay(ctx)
+	if !ok {
+		return
+	}
+
+	version, ok := protocolVersion(ctx)
+	if !ok {
+		return
+	}
+
+	header := http.Header{ProtocolVersionHeader: {strconv.Itoa(int(version))}}
+	conn, err := c.upgrader.Upgrade(ctx.Writer, ctx.Request, header)
+	if err != nil {
+		// The upgrader has already responded to the request.
+		slog.Debug("could not upgrade overlay connection", "err", err.Error())
+		return
+	}
+	defer conn.Close()
+
+	resumeFrom := lastEventId(ctx)
+	if resumeFrom == "" {
+		resumeFrom = c.connections.lastAck(overlayId)
+	}
+
+	client := c.addClient(channelId, resumeFrom)
+	defer c.removeClient(client)
+
+	connection := c.connections.add(channelId, overlayId)
+	defer c.connections.remove(connection)
+
+	closed := make(chan struct{})
+	go c.readReports(conn, connection, closed)
+
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+
+	pinger := time.NewTicker(c.pingInterval)
+	defer pinger.Stop()
+
+	for {
+		var event overlayEvent
+		select {
+		case announcement, ok := <-client.Stream:
+			if !ok {
+				return
+			}
+			name, data := announcement.Encode(version)
+			event = overlayEvent{Id: announcement.Id, Event: name, Data: data}
+		case now := <-ticker.C:
+			if c.revoked(channelId, overlayId) {
+				return
+			}
+			name, data := announcers.EncodeHeartbeat(version, now)
+			event = overlayEvent{Event: name, Data: data}
+		case <-connection.revoked:
+			return
+		case <-closed:
+			return
+		case <-pinger.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeTimeout)); err != nil {
+				slog.Debug("could not ping overlay", "channel_id", channelId, "err", err.Error())
+				return
+			}
+			continue
+		}
+
+		conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+		if err := conn.WriteJSON(event); err != nil {
+			slog.Debug("could not write to overlay", "channel_id", channelId, "err", err.Error())
+			return
+		}
+	}
+}
+
+// Handles messages from the overlay until the connection closes or the
+// overlay stops answering pings.
+func (c *OverlayController) readReports(conn *websocket.Conn, connection *overlayConnection, closed chan struct{}) {
+	defer close(closed)
+
+	channelId := connection.channelId
+	extendDeadline := func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(c.readTimeout))
+	}
+	extendDeadline("")
+	conn.SetPongHandler(extendDeadline)
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			slog.Debug("overlay disconnected", "channel_id", channelId, "err", err.Error())
+			return
+		}
+		extendDeadline("")
+
+		var report overlayReport
+		if err := json.Unmarshal(data, &report); err != nil {
+			slog.Debug("invalid report from overlay", "channel_id", channelId, "err", err.Error())
+			continue
+		}
+
+		switch report.Type {
+		case AckReport:
+			if report.Id == "" {
+				slog.Debug("ack without event id from overlay", "channel_id", channelId)
+				continue
+			}
+			c.connections.ack(connection, report.Id)
+		case PositionReport:
+			c.Reporter.ReportPosition(channelId, report.UserId, report.Position)
+		default:
+			slog.Debug("unknown report from overlay", "channel_id", channelId, "type", report.Type)
+		}
+	}
+}
+
+func (c *OverlayController) verifyOverlay(ctx *gin.Context) (twitch.Id, uuid.UUID, bool) {
+	channelId := twitch.Id(ctx.Query(ChannelId))
+	overlayId, err := uuid.Parse(ctx.Query(OverlayId))
+	if err != nil {
+		addErrorToCtx(invalidRequest(err), ctx)
+		return "", uuid.UUID{}, false
+	}
+
+	if err := c.Overlay.VerifyOverlayId(channelId, overlayId); err != nil {
+		addErrorToCtx(err, ctx)
+		return "", uuid.UUID{}, false
+	}
+
+	return channelId, overlayId, true
+}
+
+// Checks again whether a connected overlay's id is still accepted. Overlays
+// are only dropped when it was refused, not when the check failed.
+func (c *OverlayController) revoked(channelId twitch.Id, overlayId uuid.UUID) bool {
+	err := c.Overlay.VerifyOverlayId(channelId, overlayId)
+	if err == nil {
+		return false
+	}
+
+	if apperrors.KindOf(err) != apperrors.Forbidden {
+		slog.Warn("could not check overlay id", "channel_id", channelId, "err", err.Error())
+		return false
+	}
+
+	slog.Debug("overlay id no longer accepted", "channel_id", channelId)
+	return true
+}
+
+func protocolVersion(ctx *gin.Context) (announcers.ProtocolVersion, bool) {
+	version, err := announcers.ParseProtocolVersion(ctx.Query(Version))
+	if err != nil {
+		addErrorToCtx(err, ctx)
+		return 0, false
+	}
+	return version, true
+}
+
+func (c *OverlayController) addClient(channelId twitch.Id, lastEventId string) announcers.Client {
+	if lastEventId != "" {
+		return c.announcer.ResumeClient(channelId, lastEventId)
+	}
+	return c.announcer.AddClient(channelId)
+}
+
+func (c *OverlayController) removeClient(client announcers.Client) {
+	// Keep draining so the announcer is never blocked on this client while it is removed.
+	go func() {
+		for range client.Stream {
+		}
+	}()
+	c.announcer.RemoveClient(client)
+}
+
+// Browsers send Last-Event-ID when an EventSource reconnects by itself.
+// Overlays that reload can pass the id they last saw as a query parameter instead.
+func lastEventId(ctx *gin.Context) string {
+	if id := ctx.GetHeader(LastEventIdHeader); id != "" {
+		return id
+	}
+	return ctx.Query(LastEventId)
+}
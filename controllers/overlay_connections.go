@@ -0,0 +1,97 @@
+package controllers
+
+import (
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/streampets/backend/twitch"
+)
+
+// An overlay connected to this instance.
+type overlayConnection struct {
+	channelId twitch.Id
+	overlayId uuid.UUID
+	// Closed when the overlay id was revoked and the overlay has to go.
+	revoked chan struct{}
+	// The id of the last event the overlay acked.
+	lastAck string
+}
+
+// Keeps track of which overlay ids the connected overlays used, so they can
+// be disconnected when their overlay id is revoked. The last event acked on
+// a connection is kept after it closes, so the overlay can resume from it.
+type overlayConnections struct {
+	mu          sync.Mutex
+	connections map[twitch.Id]map[*overlayConnection]struct{}
+	lastAcks    map[uuid.UUID]string
+}
+
+func newOverlayConnections() *overlayConnections {
+	return &overlayConnections{
+		connections: map[twitch.Id]map[*overlayConnection]struct{}{},
+		lastAcks:    map[uuid.UUID]string{},
+	}
+}
+
+func (o *overlayConnections) add(channelId twitch.Id, overlayId uuid.UUID) *overlayConnection {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	connection := &overlayConnection{
+		channelId: channelId,
+		overlayId: overlayId,
+		revoked:   make(chan struct{}),
+	}
+
+	if o.connections[channelId] == nil {
+		o.connections[channelId] = map[*overlayConnection]struct{}{}
+	}
+	o.connections[channelId][connection] = struct{}{}
+
+	return connection
+}
+
+func (o *overlayConnections) remove(connection *overlayConnection) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	delete(o.connections[connection.channelId], connection)
+	if len(o.connections[connection.channelId]) == 0 {
+		delete(o.connections, connection.channelId)
+	}
+
+	if connection.lastAck != "" {
+		o.lastAcks[connection.overlayId] = connection.lastAck
+	}
+}
+
+// Records that the overlay handled every event up to the given one.
+func (o *overlayConnections) ack(connection *overlayConnection, eventId string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	connection.lastAck = eventId
+}
+
+// Returns the id of the last event acked by an overlay that has since
+// disconnected, or an empty string if there is none.
+func (o *overlayConnections) lastAck(overlayId uuid.UUID) string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return o.lastAcks[overlayId]
+}
+
+// Signals every overlay connected with the overlay id to disconnect.
+func (o *overlayConnections) revoke(channelId twitch.Id, overlayId uuid.UUID) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	delete(o.lastAcks, overlayId)
+	for connection := range o.connections[channelId] {
+		if connection.overlayId == overlayId {
+			close(connection.revoked)
+			delete(o.connections[channelId], connection)
+		}
+	}
+}
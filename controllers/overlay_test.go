@@ -3,11 +3,15 @@ package controllers
 import (
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
 	"github.com/ovechkin-dm/mockio/mock"
 	"github.com/streampets/backend/announcers"
 	"github.com/streampets/backend/services"
@@ -59,6 +63,8 @@ func TestHandleListen(t *testing.T) {
 		controller := NewOverlayController(
 			announcerMock,
 			verifierMock,
+			mock.Mock[PositionReporter](),
+			announcers.NewMemoryBroker(),
 		)
 
 		var wg sync.WaitGroup
@@ -85,6 +91,172 @@ func TestHandleListen(t *testing.T) {
 		assert.Contains(t, recorder.Body.String(), "data:message")
 	})
 
+	t.Run("client resumed from last event id header", func(t *testing.T) {
+		mock.SetUp(t)
+
+		lastEventId := "last event id"
+		ctx, recorder := setUpContext(channelId, overlayId)
+		ctx.Request.Header.Add("Last-Event-ID", lastEventId)
+
+		stream := make(chan announcers.Announcement)
+		client := announcers.Client{Stream: stream}
+
+		announcerMock := mock.Mock[clientAddRemover]()
+		verifierMock := mock.Mock[OverlayIdVerifier]()
+
+		mock.When(announcerMock.ResumeClient(channelId, lastEventId)).ThenReturn(client)
+
+		controller := NewOverlayController(
+			announcerMock,
+			verifierMock,
+			mock.Mock[PositionReporter](),
+			announcers.NewMemoryBroker(),
+		)
+
+		var wg sync.WaitGroup
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			controller.HandleListen(ctx)
+		}()
+
+		stream <- announcers.Announcement{
+			Id:      "event id",
+			Event:   "event",
+			Message: "message",
+		}
+
+		close(stream)
+		wg.Wait()
+
+		mock.Verify(announcerMock, mock.Once()).ResumeClient(channelId, lastEventId)
+		mock.Verify(announcerMock, mock.Never()).AddClient(channelId)
+
+		assert.Contains(t, recorder.Body.String(), "id:event id")
+		assert.Contains(t, recorder.Body.String(), "event:event")
+	})
+
+	t.Run("events encoded for the requested protocol version", func(t *testing.T) {
+		pet := services.Pet{UserId: "user id", Username: "username", Image: "image"}
+		tests := map[string]struct {
+			version string
+			header  string
+			event   string
+			data    string
+		}{
+			"no version": {"", "1", "event:JOIN", `data:{"userId":"user id","username":"username","color":"image"}`},
+			"version 1":  {"1", "1", "event:JOIN", `data:{"userId":"user id","username":"username","color":"image"}`},
+			"version 2":  {"2", "2", "event:join", `data:{"pet":{"userId":"user id","username":"username","color":"image"}}`},
+			"newer":      {"3", "2", "event:join", `data:{"pet":{"userId":"user id","username":"username","color":"image"}}`},
+		}
+
+		for name, test := range tests {
+			t.Run(name, func(t *testing.T) {
+				mock.SetUp(t)
+
+				ctx, recorder := setUpContext(channelId, overlayId)
+				values := ctx.Request.URL.Query()
+				values.Add(Version, test.version)
+				ctx.Request.URL.RawQuery = values.Encode()
+
+				stream := make(chan announcers.Announcement)
+				client := announcers.Client{Stream: stream}
+
+				announcerMock := mock.Mock[clientAddRemover]()
+				mock.When(announcerMock.AddClient(channelId)).ThenReturn(client)
+
+				controller := NewOverlayController(
+					announcerMock,
+					mock.Mock[OverlayIdVerifier](),
+					mock.Mock[PositionReporter](),
+					announcers.NewMemoryBroker(),
+				)
+
+				var wg sync.WaitGroup
+				wg.Add(1)
+
+				go func() {
+					defer wg.Done()
+					controller.HandleListen(ctx)
+				}()
+
+				stream <- announcers.Announcement{
+					Event:   announcers.JoinEvent,
+					Message: announcers.JoinPayload{Pet: pet},
+				}
+
+				close(stream)
+				wg.Wait()
+
+				assert.Equal(t, test.header, recorder.Header().Get(ProtocolVersionHeader))
+				assert.Contains(t, recorder.Body.String(), test.event)
+				assert.Contains(t, recorder.Body.String(), test.data)
+			})
+		}
+	})
+
+	t.Run("stream ended when overlay id revoked", func(t *testing.T) {
+		mock.SetUp(t)
+
+		ctx, _ := setUpContext(channelId, overlayId)
+
+		stream := make(chan announcers.Announcement)
+		client := announcers.Client{Stream: stream}
+
+		announcerMock := mock.Mock[clientAddRemover]()
+		mock.When(announcerMock.AddClient(channelId)).ThenReturn(client)
+
+		controller := NewOverlayController(
+			announcerMock,
+			mock.Mock[OverlayIdVerifier](),
+			mock.Mock[PositionReporter](),
+			announcers.NewMemoryBroker(),
+		)
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			controller.HandleListen(ctx)
+		}()
+
+		// Once the event is taken the overlay is known to be connected.
+		stream <- announcers.Announcement{Event: "event", Message: "message"}
+
+		controller.DisconnectOverlay(channelId, uuid.New())
+		controller.DisconnectOverlay(channelId, overlayId)
+
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("stream was not ended")
+		}
+	})
+
+	t.Run("client not added when protocol version is invalid", func(t *testing.T) {
+		mock.SetUp(t)
+
+		ctx, recorder := setUpContext(channelId, overlayId)
+		values := ctx.Request.URL.Query()
+		values.Add(Version, "0")
+		ctx.Request.URL.RawQuery = values.Encode()
+
+		clientMock := mock.Mock[clientAddRemover]()
+
+		controller := NewOverlayController(
+			clientMock,
+			mock.Mock[OverlayIdVerifier](),
+			mock.Mock[PositionReporter](),
+			announcers.NewMemoryBroker(),
+		)
+
+		controller.HandleListen(ctx)
+
+		mock.Verify(clientMock, mock.Never()).AddClient(channelId)
+
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	})
+
 	t.Run("client not added when overlay id and channel id do not match", func(t *testing.T) {
 		mock.SetUp(t)
 
@@ -98,6 +270,8 @@ func TestHandleListen(t *testing.T) {
 		controller := NewOverlayController(
 			clientMock,
 			verifierMock,
+			mock.Mock[PositionReporter](),
+			announcers.NewMemoryBroker(),
 		)
 
 		controller.HandleListen(ctx)
@@ -108,3 +282,342 @@ func TestHandleListen(t *testing.T) {
 		assert.Contains(t, recorder.Body.String(), services.ErrIdMismatch.Error())
 	})
 }
+
+// Mocks cannot be called from the goroutines of a running server, so the
+// WebSocket tests use these fakes instead.
+type positionRecorder struct {
+	channelId twitch.Id
+	userId    twitch.Id
+	positions chan services.Position
+}
+
+func (r *positionRecorder) ReportPosition(channelId, userId twitch.Id, position services.Position) {
+	r.channelId = channelId
+	r.userId = userId
+	r.positions <- position
+}
+
+type fakeClients struct {
+	client  announcers.Client
+	added   chan twitch.Id
+	resumed chan string
+	removed chan announcers.Client
+}
+
+func (f *fakeClients) AddClient(channelId twitch.Id) announcers.Client {
+	f.added <- channelId
+	return f.client
+}
+
+func (f *fakeClients) ResumeClient(channelId twitch.Id, lastEventId string) announcers.Client {
+	if f.resumed != nil {
+		f.resumed <- lastEventId
+	}
+	return f.AddClient(channelId)
+}
+
+func (f *fakeClients) RemoveClient(client announcers.Client) {
+	f.removed <- client
+}
+
+type fakeOverlayVerifier struct {
+	err error
+}
+
+func (f *fakeOverlayVerifier) VerifyOverlayId(channelId twitch.Id, overlayId uuid.UUID) error {
+	return f.err
+}
+
+func TestHandleWebSocket(t *testing.T) {
+	setUpServer := func(controller *OverlayController) *httptest.Server {
+		gin.SetMode(gin.TestMode)
+
+		r := gin.New()
+		r.GET("/ws", controller.HandleWebSocket)
+
+		server := httptest.NewServer(r)
+		t.Cleanup(server.Close)
+		return server
+	}
+
+	dial := func(server *httptest.Server, channelId twitch.Id, overlayId uuid.UUID) (*websocket.Conn, *http.Response, error) {
+		values := url.Values{}
+		values.Add("channelId", string(channelId))
+		values.Add("overlayId", overlayId.String())
+
+		wsUrl := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws?" + values.Encode()
+		return websocket.DefaultDialer.Dial(wsUrl, nil)
+	}
+
+	channelId := twitch.Id("channel id")
+	overlayId := uuid.New()
+
+	t.Run("events are sent and reports are received", func(t *testing.T) {
+		userId := twitch.Id("user id")
+		position := services.Position{X: 1, Y: 2}
+
+		stream := make(chan announcers.Announcement)
+		client := announcers.Client{Stream: stream}
+
+		clients := &fakeClients{
+			client:  client,
+			added:   make(chan twitch.Id, 1),
+			removed: make(chan announcers.Client, 1),
+		}
+		reporter := &positionRecorder{positions: make(chan services.Position, 1)}
+
+		server := setUpServer(NewOverlayController(clients, &fakeOverlayVerifier{}, reporter, announcers.NewMemoryBroker()))
+
+		conn, _, err := dial(server, channelId, overlayId)
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer conn.Close()
+
+		assert.Equal(t, channelId, <-clients.added)
+
+		stream <- announcers.Announcement{
+			Id:      "event id",
+			Event:   "event",
+			Message: "message",
+		}
+
+		var actual map[string]string
+		assert.NoError(t, conn.ReadJSON(&actual))
+		assert.Equal(t, map[string]string{"id": "event id", "event": "event", "data": "message"}, actual)
+
+		assert.NoError(t, conn.WriteJSON(map[string]any{
+			"type":     "position",
+			"user_id":  userId,
+			"position": map[string]float64{"x": 1, "y": 2},
+		}))
+
+		select {
+		case actual := <-reporter.positions:
+			assert.Equal(t, position, actual)
+			assert.Equal(t, channelId, reporter.channelId)
+			assert.Equal(t, userId, reporter.userId)
+		case <-time.After(time.Second):
+			t.Fatal("position was not reported")
+		}
+
+		close(stream)
+
+		select {
+		case removed := <-clients.removed:
+			assert.Equal(t, client, removed)
+		case <-time.After(time.Second):
+			t.Fatal("client was not removed")
+		}
+
+		_, _, err = conn.ReadMessage()
+		assert.Error(t, err)
+	})
+
+	t.Run("reconnecting overlay resumes after last acked event", func(t *testing.T) {
+		stream := make(chan announcers.Announcement)
+		client := announcers.Client{Stream: stream}
+
+		clients := &fakeClients{
+			client:  client,
+			added:   make(chan twitch.Id, 1),
+			resumed: make(chan string, 1),
+			removed: make(chan announcers.Client, 1),
+		}
+		reporter := &positionRecorder{positions: make(chan services.Position, 1)}
+
+		server := setUpServer(NewOverlayController(clients, &fakeOverlayVerifier{}, reporter, announcers.NewMemoryBroker()))
+
+		conn, _, err := dial(server, channelId, overlayId)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		<-clients.added
+		stream <- announcers.Announcement{Id: "event id", Event: "event", Message: "message"}
+
+		var event map[string]string
+		assert.NoError(t, conn.ReadJSON(&event))
+
+		assert.NoError(t, conn.WriteJSON(map[string]any{"type": "ack", "id": "event id"}))
+		// Reports are handled in order, so the ack is recorded once the position is.
+		assert.NoError(t, conn.WriteJSON(map[string]any{"type": "position", "user_id": "user id"}))
+		select {
+		case <-reporter.positions:
+		case <-time.After(time.Second):
+			t.Fatal("position was not reported")
+		}
+
+		conn.Close()
+		select {
+		case <-clients.removed:
+		case <-time.After(time.Second):
+			t.Fatal("client was not removed")
+		}
+
+		conn, _, err = dial(server, channelId, overlayId)
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer conn.Close()
+
+		select {
+		case lastEventId := <-clients.resumed:
+			assert.Equal(t, "event id", lastEventId)
+		case <-time.After(time.Second):
+			t.Fatal("client was not resumed")
+		}
+		<-clients.added
+	})
+
+	t.Run("connection closed when overlay id revoked", func(t *testing.T) {
+		stream := make(chan announcers.Announcement)
+		client := announcers.Client{Stream: stream}
+
+		clients := &fakeClients{
+			client:  client,
+			added:   make(chan twitch.Id, 1),
+			removed: make(chan announcers.Client, 1),
+		}
+
+		controller := NewOverlayController(clients, &fakeOverlayVerifier{}, &positionRecorder{}, announcers.NewMemoryBroker())
+		server := setUpServer(controller)
+
+		conn, _, err := dial(server, channelId, overlayId)
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer conn.Close()
+
+		<-clients.added
+		stream <- announcers.Announcement{Event: "event", Message: "message"}
+
+		var event map[string]string
+		assert.NoError(t, conn.ReadJSON(&event))
+
+		controller.DisconnectOverlay(channelId, overlayId)
+
+		select {
+		case <-clients.removed:
+		case <-time.After(time.Second):
+			t.Fatal("client was not removed")
+		}
+
+		_, _, err = conn.ReadMessage()
+		assert.Error(t, err)
+	})
+
+	t.Run("connection closed when overlay id revoked on another instance", func(t *testing.T) {
+		stream := make(chan announcers.Announcement)
+		clients := &fakeClients{
+			client:  announcers.Client{Stream: stream},
+			added:   make(chan twitch.Id, 1),
+			removed: make(chan announcers.Client, 1),
+		}
+
+		broker := announcers.NewMemoryBroker()
+		connected := NewOverlayController(clients, &fakeOverlayVerifier{}, &positionRecorder{}, broker)
+		other := NewOverlayController(&fakeClients{}, &fakeOverlayVerifier{}, &positionRecorder{}, broker)
+		server := setUpServer(connected)
+
+		conn, _, err := dial(server, channelId, overlayId)
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer conn.Close()
+
+		// Once an event arrives the connection is being tracked.
+		<-clients.added
+		stream <- announcers.Announcement{Event: "event", Message: "message"}
+
+		var event map[string]string
+		assert.NoError(t, conn.ReadJSON(&event))
+
+		other.DisconnectOverlay(channelId, overlayId)
+
+		select {
+		case <-clients.removed:
+		case <-time.After(time.Second):
+			t.Fatal("client was not removed")
+		}
+	})
+
+	t.Run("connection closed when overlay stops answering pings", func(t *testing.T) {
+		stream := make(chan announcers.Announcement)
+		clients := &fakeClients{
+			client:  announcers.Client{Stream: stream},
+			added:   make(chan twitch.Id, 1),
+			removed: make(chan announcers.Client, 1),
+		}
+
+		controller := NewOverlayController(clients, &fakeOverlayVerifier{}, &positionRecorder{}, announcers.NewMemoryBroker())
+		controller.pingInterval = 10 * time.Millisecond
+		controller.readTimeout = 50 * time.Millisecond
+		server := setUpServer(controller)
+
+		// Pongs are only sent while reading, so this overlay never answers.
+		conn, _, err := dial(server, channelId, overlayId)
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer conn.Close()
+
+		<-clients.added
+
+		select {
+		case <-clients.removed:
+		case <-time.After(time.Second):
+			t.Fatal("client was not removed")
+		}
+	})
+
+	t.Run("connection kept while overlay answers pings", func(t *testing.T) {
+		stream := make(chan announcers.Announcement)
+		clients := &fakeClients{
+			client:  announcers.Client{Stream: stream},
+			added:   make(chan twitch.Id, 1),
+			removed: make(chan announcers.Client, 1),
+		}
+
+		controller := NewOverlayController(clients, &fakeOverlayVerifier{}, &positionRecorder{}, announcers.NewMemoryBroker())
+		controller.pingInterval = 10 * time.Millisecond
+		controller.readTimeout = 50 * time.Millisecond
+		server := setUpServer(controller)
+
+		conn, _, err := dial(server, channelId, overlayId)
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer conn.Close()
+
+		<-clients.added
+
+		// Reading answers the pings.
+		go func() {
+			for {
+				if _, _, err := conn.ReadMessage(); err != nil {
+					return
+				}
+			}
+		}()
+
+		select {
+		case <-clients.removed:
+			t.Fatal("client was removed")
+		case <-time.After(200 * time.Millisecond):
+		}
+	})
+
+	t.Run("connection refused when overlay id and channel id do not match", func(t *testing.T) {
+		clients := &fakeClients{added: make(chan twitch.Id, 1)}
+		verifier := &fakeOverlayVerifier{err: services.ErrIdMismatch}
+
+		server := setUpServer(NewOverlayController(clients, verifier, &positionRecorder{}, announcers.NewMemoryBroker()))
+
+		_, response, err := dial(server, channelId, overlayId)
+
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusForbidden, response.StatusCode)
+		assert.Empty(t, clients.added)
+	})
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/streampets/backend/announcers"
 	"github.com/streampets/backend/models"
 	"github.com/streampets/backend/services"
 	"github.com/streampets/backend/twitch"
@@ -15,6 +16,7 @@ type Announcer interface {
 	AnnouncePart(channelId, userId twitch.Id)
 	AnnounceAction(channelId, userId twitch.Id, action string)
 	AnnounceUpdate(channelId, userId twitch.Id, image string)
+	GetPresence(channelId twitch.Id) []announcers.Presence
 }
 
 type PetGetter interface {
@@ -52,7 +54,7 @@ func (c *TwitchBotController) AddPetToChannel(ctx *gin.Context) {
 
 	var params Params
 	if err := ctx.ShouldBindJSON(&params); err != nil {
-		addErrorToCtx(err, ctx)
+		addErrorToCtx(invalidRequest(err), ctx)
 		return
 	}
 
@@ -67,6 +69,11 @@ func (c *TwitchBotController) AddPetToChannel(ctx *gin.Context) {
 	ctx.JSON(http.StatusNoContent, nil)
 }
 
+func (c *TwitchBotController) GetUsersInChannel(ctx *gin.Context) {
+	channelId := twitch.Id(ctx.Param(ChannelId))
+	ctx.JSON(http.StatusOK, c.Announcer.GetPresence(channelId))
+}
+
 func (c *TwitchBotController) RemoveUserFromChannel(ctx *gin.Context) {
 	channelId := twitch.Id(ctx.Param(ChannelId))
 	userId := twitch.Id(ctx.Param(UserId))
@@ -91,7 +98,7 @@ func (c *TwitchBotController) UpdateUser(ctx *gin.Context) {
 
 	var params Params
 	if err := ctx.ShouldBindJSON(&params); err != nil {
-		addErrorToCtx(err, ctx)
+		addErrorToCtx(invalidRequest(err), ctx)
 		return
 	}
 
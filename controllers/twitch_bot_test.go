@@ -2,17 +2,21 @@ package controllers
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/ovechkin-dm/mockio/mock"
+	"github.com/streampets/backend/announcers"
 	"github.com/streampets/backend/models"
 	"github.com/streampets/backend/services"
 	"github.com/streampets/backend/twitch"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestAddUserToChannel(t *testing.T) {
@@ -57,6 +61,42 @@ func TestAddUserToChannel(t *testing.T) {
 	mock.Verify(announcerMock, mock.Once()).AnnounceJoin(channelId, pet)
 }
 
+func TestGetUsersInChannel(t *testing.T) {
+	mock.SetUp(t)
+
+	channelId := twitch.Id("channel id")
+	joinedAt := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	expected := []announcers.Presence{{
+		Pet:      services.Pet{UserId: "user id", Username: "username", Image: "image"},
+		JoinedAt: joinedAt,
+		LastSeen: joinedAt,
+	}}
+
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+	ctx.Params = gin.Params{{Key: ChannelId, Value: string(channelId)}}
+
+	announcerMock := mock.Mock[Announcer]()
+	mock.When(announcerMock.GetPresence(channelId)).ThenReturn(expected)
+
+	controller := NewTwitchBotController(
+		announcerMock,
+		mock.Mock[ItemGetSetter](),
+		mock.Mock[PetGetter](),
+	)
+
+	controller.GetUsersInChannel(ctx)
+
+	var actual []announcers.Presence
+	if err := json.Unmarshal(recorder.Body.Bytes(), &actual); err != nil {
+		t.Errorf("could not parse json response")
+	}
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, expected, actual)
+}
+
 func TestRemoveUserFromChannel(t *testing.T) {
 	mock.SetUp(t)
 
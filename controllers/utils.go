@@ -1,20 +1,94 @@
 package controllers
 
 import (
+	"log/slog"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/streampets/backend/apperrors"
 )
 
 const XExtensionJwt string = "x-extension-jwt"
+const Authorization string = "Authorization"
+const ImageField string = "image"
+const LastEventIdHeader string = "Last-Event-ID"
+const ProtocolVersionHeader string = "X-Protocol-Version"
 const Action string = "action"
 
+const AssetKey string = "key"
 const ChannelId string = "channelId"
+const ItemId string = "itemId"
+const LastEventId string = "lastEventId"
 const OverlayId string = "overlayId"
 const UserId string = "userId"
+const Version string = "version"
 
+var ErrJSONBodyRequired = apperrors.New(apperrors.Invalid, "json_body_required", "request body must be JSON with content type application/json")
+
+// The body of every error response. Code is stable and meant for clients to
+// match on, message is meant for people.
+type errorResponse struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+var errorStatuses = map[apperrors.Kind]int{
+	apperrors.Invalid:      http.StatusBadRequest,
+	apperrors.Unauthorized: http.StatusUnauthorized,
+	apperrors.Forbidden:    http.StatusForbidden,
+	apperrors.NotFound:     http.StatusNotFound,
+	apperrors.Conflict:     http.StatusConflict,
+	apperrors.Unavailable:  http.StatusServiceUnavailable,
+	apperrors.Internal:     http.StatusInternalServerError,
+}
+
+// Aborts the request with the status matching the kind of err. Only the
+// error's own message is sent; the errors it wraps are logged, as are the
+// details of errors without a kind, which are internal.
 func addErrorToCtx(err error, ctx *gin.Context) {
-	ctx.JSON(http.StatusBadRequest, gin.H{
-		"message": err.Error(),
+	coded, ok := apperrors.As(err)
+	if !ok {
+		slog.Error("internal error", "path", ctx.FullPath(), "err", err.Error())
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, errorResponse{
+			Code:    string(apperrors.Internal),
+			Message: "internal server error",
+		})
+		return
+	}
+
+	status, ok := errorStatuses[coded.Kind()]
+	if !ok {
+		status = http.StatusInternalServerError
+	}
+
+	message := coded.Message()
+	if status >= http.StatusInternalServerError {
+		slog.Error("request failed", "path", ctx.FullPath(), "code", coded.Code(), "err", err.Error())
+		message = http.StatusText(status)
+	} else {
+		slog.Debug("request rejected", "path", ctx.FullPath(), "code", coded.Code(), "err", err.Error())
+	}
+
+	ctx.AbortWithStatusJSON(status, errorResponse{
+		Code:    coded.Code(),
+		Message: message,
 	})
 }
+
+// Binds the request's JSON body to obj. Unlike ShouldBindJSON, requests have
+// to say their body is JSON and have one. Browsers cannot send that content
+// type cross-site without asking first, so forms on other sites are turned away.
+func bindJSONBody(ctx *gin.Context, obj any) error {
+	if ctx.ContentType() != "application/json" || ctx.Request.ContentLength == 0 {
+		return ErrJSONBodyRequired
+	}
+	if err := ctx.ShouldBindJSON(obj); err != nil {
+		return invalidRequest(err)
+	}
+	return nil
+}
+
+// Marks err, such as a failure to parse the request body, as the client's mistake.
+func invalidRequest(err error) error {
+	return apperrors.Wrap(err, apperrors.Invalid, "invalid_request", "invalid request")
+}
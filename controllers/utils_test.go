@@ -0,0 +1,84 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/streampets/backend/apperrors"
+	"github.com/streampets/backend/services"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddErrorToCtx(t *testing.T) {
+	tests := map[string]struct {
+		err      error
+		status   int
+		expected errorResponse
+	}{
+		"invalid request": {
+			invalidRequest(errors.New("bad json")),
+			http.StatusBadRequest,
+			errorResponse{Code: "invalid_request", Message: "invalid request"},
+		},
+		"unauthorized": {
+			services.ErrInvalidToken,
+			http.StatusUnauthorized,
+			errorResponse{Code: "invalid_token", Message: "token is not valid"},
+		},
+		"forbidden": {
+			services.ErrSelectUnownedItem,
+			http.StatusForbidden,
+			errorResponse{Code: "select_unowned_item", Message: "user tried to select an item they do not own"},
+		},
+		"wrapped causes are not sent": {
+			apperrors.Wrap(errors.New("pq: duplicate key value violates unique constraint"), apperrors.Conflict, "owned_item_exists", "owned_item already exists"),
+			http.StatusConflict,
+			errorResponse{Code: "owned_item_exists", Message: "owned_item already exists"},
+		},
+		"wrapped not found": {
+			fmt.Errorf("getting item: %w", apperrors.New(apperrors.NotFound, "item_not_found", "item not found")),
+			http.StatusNotFound,
+			errorResponse{Code: "item_not_found", Message: "item not found"},
+		},
+		"conflict": {
+			apperrors.New(apperrors.Conflict, "owned_item_exists", "owned_item already exists"),
+			http.StatusConflict,
+			errorResponse{Code: "owned_item_exists", Message: "owned_item already exists"},
+		},
+		"unavailable hides the cause": {
+			apperrors.Wrap(errors.New("dial tcp: connection refused"), apperrors.Unavailable, "database_unavailable", "database unavailable"),
+			http.StatusServiceUnavailable,
+			errorResponse{Code: "database_unavailable", Message: "Service Unavailable"},
+		},
+		"unknown errors are internal": {
+			errors.New("something broke"),
+			http.StatusInternalServerError,
+			errorResponse{Code: "internal", Message: "internal server error"},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			recorder := httptest.NewRecorder()
+			ctx, _ := gin.CreateTestContext(recorder)
+			ctx.Request, _ = http.NewRequest("GET", "/", nil)
+
+			addErrorToCtx(test.err, ctx)
+
+			var actual errorResponse
+			if err := json.Unmarshal(recorder.Body.Bytes(), &actual); err != nil {
+				t.Errorf("could not parse json response")
+			}
+
+			assert.True(t, ctx.IsAborted())
+			assert.Equal(t, test.status, recorder.Code)
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
@@ -1,22 +1,33 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/joho/godotenv"
 	"github.com/streampets/backend/announcers"
 	"github.com/streampets/backend/config"
 	"github.com/streampets/backend/controllers"
+	"github.com/streampets/backend/migrations"
 	"github.com/streampets/backend/repositories"
 	"github.com/streampets/backend/routes"
 	"github.com/streampets/backend/services"
 	"github.com/streampets/backend/twitch"
+	"gorm.io/gorm"
 )
 
-func run() error {
+func run(args []string) error {
 	env := os.Getenv("ENVIRONMENT")
 	if env != "PRODUCTION" {
 		err := godotenv.Load()
@@ -27,31 +38,250 @@ func run() error {
 
 	db := config.ConnectDB()
 
-	twitchApi := twitch.New(http.DefaultClient, "https://id.twitch.tv")
+	if len(args) > 0 && args[0] == "migrate" {
+		return migrate(db, args[1:])
+	}
+	if _, err := migrations.Up(db); err != nil {
+		return err
+	}
+
+	twitchApi := config.CreateTwitchApi()
 	itemRepo := repositories.NewItemRepository(db)
 	channels := repositories.NewChannelRepo(db)
 
 	auth := config.CreateAuthService(channels)
+	bots := services.NewBotService(repositories.NewBotRepo(db))
 
-	announcer := announcers.NewAnnouncerService()
-	cachedAnnouncer := announcers.NewCachedAnnouncerService(announcer)
+	if len(args) > 0 {
+		switch args[0] {
+		case "create-bot":
+			return createBot(bots, args[1:])
+		case "list-bots":
+			return listBots(bots, args[1:])
+		case "set-bot-channels":
+			return setBotChannels(bots, args[1:])
+		case "revoke-bot":
+			return revokeBot(bots, args[1:])
+		}
+	}
+
+	broker := config.CreateBroker()
+	defer broker.Close()
+
+	announcer := config.CreateAnnouncerService(broker)
+	cachedAnnouncer := announcers.NewCachedAnnouncerService(announcer, broker)
+
+	presence := repositories.NewPresenceRepo(db)
+	presenceConfig := config.GetPresenceConfig()
+	if err := cachedAnnouncer.RestorePresence(presence, presenceConfig.MaxAge); err != nil {
+		return err
+	}
 
-	items := services.NewItemService(itemRepo)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var background sync.WaitGroup
+	background.Add(3)
+	go func() {
+		defer background.Done()
+		announcer.ReportDropped(ctx, config.GetDroppedReportInterval())
+	}()
+	go func() {
+		defer background.Done()
+		cachedAnnouncer.SnapshotPresence(ctx, presence, presenceConfig.SnapshotInterval)
+	}()
+	go func() {
+		defer background.Done()
+		cachedAnnouncer.SweepIdlePets(ctx, channels, presenceConfig.IdleTimeout, presenceConfig.SweepInterval)
+	}()
+	// Waits for the last presence snapshot, which is taken once ctx is cancelled.
+	defer background.Wait()
+	defer cancel()
+
+	rarities := services.NewRarityService(repositories.NewRarityRepo(db))
+	items := services.NewItemService(itemRepo, rarities)
 	pets := services.NewPetService(items)
 
-	overlay := controllers.NewOverlayController(cachedAnnouncer, auth)
-	extension := controllers.NewExtensionController(cachedAnnouncer, auth, items)
-	dashboard := controllers.NewDashboardController(channels, twitchApi)
+	overlay := controllers.NewOverlayController(cachedAnnouncer, auth, cachedAnnouncer, broker)
+	extension := controllers.NewExtensionController(cachedAnnouncer, auth, items, rarities)
+	logins := config.CreateLoginService(twitchApi, repositories.NewTwitchTokenRepo(db), repositories.NewSessionVersionRepo(db))
+	validator := config.CreateTokenValidator(twitchApi)
+	channelService := services.NewChannelService(channels, twitchApi)
+	assetStore := config.CreateAssetStore()
+	images := services.NewImageService(assetStore, config.GetAssetUrl())
+	dashboard := controllers.NewDashboardController(channels, validator, cachedAnnouncer, logins, channelService, overlay, items, images, config.GetDashboardUrl())
+	assets := controllers.NewAssetController(assetStore)
 	twitchBot := controllers.NewTwitchBotController(cachedAnnouncer, items, pets)
+	eventSub := config.CreateEventSubController(cachedAnnouncer, pets)
+
+	chatBot := controllers.NewChatBot(cachedAnnouncer, items, pets)
+	if chat := config.CreateChatClient(chatBot); chat != nil {
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			if err := chat.Run(ctx); err != nil {
+				slog.Error("chat bot stopped", "err", err.Error())
+			}
+		}()
+	}
 
 	r := gin.Default()
-	routes.RegisterRoutes(r, overlay, extension, dashboard, twitchBot)
+	routes.RegisterRoutes(r, overlay, extension, dashboard, assets, twitchBot, controllers.BotAuth(bots), eventSub)
+
+	server := config.CreateServer(r)
+	// Event streams never finish on their own, so end them as soon as the
+	// server stops accepting connections and let overlays know to reconnect.
+	server.RegisterOnShutdown(announcer.Close)
+
+	return serve(server, config.GetShutdownTimeout())
+}
+
+// Usage: create-bot <name> <channel id>...
+// Prints the new bot's API key, which is not stored and cannot be shown again.
+func createBot(bots *services.BotService, args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("usage: create-bot <name> <channel id>...")
+	}
+
+	key, err := bots.CreateBot(args[0], toChannelIds(args[1:]))
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(key)
+	return nil
+}
+
+// Usage: list-bots
+// Prints each bot's id and name, and the channels it may act for.
+func listBots(bots *services.BotService, args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("usage: list-bots")
+	}
+
+	summaries, err := bots.ListBots()
+	if err != nil {
+		return err
+	}
+
+	for _, summary := range summaries {
+		channelIds := make([]string, 0, len(summary.ChannelIds))
+		for _, channelId := range summary.ChannelIds {
+			channelIds = append(channelIds, string(channelId))
+		}
+		fmt.Printf("%s\t%s\t%s\n", summary.Bot.BotId, summary.Bot.Name, strings.Join(channelIds, ","))
+	}
+	return nil
+}
+
+// Usage: set-bot-channels <bot id> <channel id>...
+// Replaces the channels a bot may act for, keeping its API key.
+func setBotChannels(bots *services.BotService, args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("usage: set-bot-channels <bot id> <channel id>...")
+	}
+
+	botId, err := uuid.Parse(args[0])
+	if err != nil {
+		return fmt.Errorf("bot id is not valid: %w", err)
+	}
 
-	return r.Run()
+	return bots.SetBotChannels(botId, toChannelIds(args[1:]))
+}
+
+// Usage: revoke-bot <bot id>
+// Deletes a bot, after which its API key is no longer accepted.
+func revokeBot(bots *services.BotService, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: revoke-bot <bot id>")
+	}
+
+	botId, err := uuid.Parse(args[0])
+	if err != nil {
+		return fmt.Errorf("bot id is not valid: %w", err)
+	}
+
+	return bots.RevokeBot(botId)
+}
+
+func toChannelIds(args []string) []twitch.Id {
+	channelIds := make([]twitch.Id, 0, len(args))
+	for _, channelId := range args {
+		channelIds = append(channelIds, twitch.Id(channelId))
+	}
+	return channelIds
+}
+
+// Usage: migrate [up | down [steps] | version]
+// Migrates the database without starting the server. Down reverts one
+// migration unless told how many.
+func migrate(db *gorm.DB, args []string) error {
+	command := "up"
+	if len(args) > 0 {
+		command = args[0]
+	}
+
+	switch {
+	case command == "up" && len(args) <= 1:
+		applied, err := migrations.Up(db)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("applied %d migrations\n", applied)
+	case command == "down" && len(args) <= 2:
+		steps := 1
+		if len(args) == 2 {
+			var err error
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				return fmt.Errorf("steps must be a positive number")
+			}
+		}
+		reverted, err := migrations.Down(db, steps)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("reverted %d migrations\n", reverted)
+	case command == "version" && len(args) <= 1:
+		version, err := migrations.Version(db)
+		if err != nil {
+			return err
+		}
+		fmt.Println(version)
+	default:
+		return fmt.Errorf("usage: migrate [up | down [steps] | version]")
+	}
+	return nil
+}
+
+// Runs the server until it fails or the process is asked to stop, then gives
+// in-flight requests until the timeout to finish.
+func serve(server *http.Server, timeout time.Duration) error {
+	stop, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-stop.Done():
+	}
+
+	slog.Info("shutting down", "timeout", timeout)
+
+	ctx, cancelTimeout := context.WithTimeout(context.Background(), timeout)
+	defer cancelTimeout()
+
+	if err := server.Shutdown(ctx); err != nil {
+		return fmt.Errorf("could not finish in-flight requests: %w", err)
+	}
+	return nil
 }
 
 func main() {
-	if err := run(); err != nil {
+	if err := run(os.Args[1:]); err != nil {
 		fmt.Fprintf(os.Stderr, "%s\n", err)
 		os.Exit(1)
 	}
@@ -0,0 +1,226 @@
+// Package migrations holds the versioned SQL migrations that create the
+// database schema, embedded in the binary with one set per SQL dialect.
+//
+// Migrations are named <version>_<name>.up.sql and <version>_<name>.down.sql.
+// Applied versions are recorded in the schema_migrations table.
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+//go:embed postgres/*.sql sqlite/*.sql
+var files embed.FS
+
+// Held while migrating so replicas starting at the same time take turns.
+const postgresLockId = 7261543
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Returns the migrations for the dialect in version order.
+func Load(dialect string) ([]Migration, error) {
+	entries, err := fs.ReadDir(files, dialect)
+	if err != nil {
+		return nil, fmt.Errorf("no migrations for %s", dialect)
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		base, direction, ok := strings.Cut(strings.TrimSuffix(entry.Name(), ".sql"), ".")
+		prefix, name, found := strings.Cut(base, "_")
+		version, err := strconv.Atoi(prefix)
+		if !ok || !found || err != nil || (direction != "up" && direction != "down") {
+			return nil, fmt.Errorf("badly named migration %s", entry.Name())
+		}
+
+		contents, err := files.ReadFile(path.Join(dialect, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: name}
+			byVersion[version] = migration
+		}
+		if direction == "up" {
+			migration.Up = string(contents)
+		} else {
+			migration.Down = string(contents)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %d is missing its up or down file", migration.Version)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// Applies every migration newer than the database's version and returns how
+// many were applied.
+func Up(db *gorm.DB) (int, error) {
+	applied := 0
+	err := withMigrator(db, func(m *migrator) error {
+		current, err := m.version()
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if migration.Version <= current {
+				continue
+			}
+			if err := m.apply(migration, migration.Up, true); err != nil {
+				return err
+			}
+			applied++
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Reverts the last steps applied migrations and returns how many were reverted.
+func Down(db *gorm.DB, steps int) (int, error) {
+	reverted := 0
+	err := withMigrator(db, func(m *migrator) error {
+		for ; reverted < steps; reverted++ {
+			current, err := m.version()
+			if err != nil {
+				return err
+			}
+			if current == 0 {
+				return nil
+			}
+
+			migration, ok := m.find(current)
+			if !ok {
+				return fmt.Errorf("database is at version %d, which this binary does not know", current)
+			}
+			if err := m.apply(migration, migration.Down, false); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// Returns the version of the last applied migration, zero if there is none.
+func Version(db *gorm.DB) (int, error) {
+	version := 0
+	err := withMigrator(db, func(m *migrator) error {
+		var err error
+		version, err = m.version()
+		return err
+	})
+	return version, err
+}
+
+type migrator struct {
+	ctx        context.Context
+	conn       *sql.Conn
+	migrations []Migration
+}
+
+// Runs fn on a single connection with the schema_migrations table in place.
+// On Postgres the connection holds an advisory lock until fn returns.
+func withMigrator(db *gorm.DB, fn func(m *migrator) error) error {
+	dialect := db.Dialector.Name()
+	migrations, err := Load(dialect)
+	if err != nil {
+		return err
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if dialect == "postgres" {
+		if _, err := conn.ExecContext(ctx, fmt.Sprintf("SELECT pg_advisory_lock(%d)", postgresLockId)); err != nil {
+			return err
+		}
+		defer conn.ExecContext(ctx, fmt.Sprintf("SELECT pg_advisory_unlock(%d)", postgresLockId))
+	}
+
+	if _, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+	version bigint NOT NULL PRIMARY KEY,
+	name text NOT NULL,
+	applied_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP
+)`); err != nil {
+		return err
+	}
+
+	return fn(&migrator{ctx: ctx, conn: conn, migrations: migrations})
+}
+
+func (m *migrator) version() (int, error) {
+	var version int
+	err := m.conn.QueryRowContext(m.ctx, "SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&version)
+	return version, err
+}
+
+func (m *migrator) find(version int) (Migration, bool) {
+	for _, migration := range m.migrations {
+		if migration.Version == version {
+			return migration, true
+		}
+	}
+	return Migration{}, false
+}
+
+// Runs the migration's up or down script and records it in one transaction.
+func (m *migrator) apply(migration Migration, script string, up bool) error {
+	tx, err := m.conn.BeginTx(m.ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(m.ctx, script); err != nil {
+		return fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+	}
+
+	// The version is an int and the name comes from the embedded file names,
+	// so neither needs escaping.
+	record := fmt.Sprintf("INSERT INTO schema_migrations (version, name) VALUES (%d, '%s')", migration.Version, migration.Name)
+	if !up {
+		record = fmt.Sprintf("DELETE FROM schema_migrations WHERE version = %d", migration.Version)
+	}
+	if _, err := tx.ExecContext(m.ctx, record); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
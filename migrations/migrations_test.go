@@ -0,0 +1,337 @@
+package migrations
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/streampets/backend/models"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+var allModels = []interface{}{
+	&models.Bot{},
+	&models.BotChannel{},
+	&models.ChannelItem{},
+	&models.Channel{},
+	&models.DefaultChannelItem{},
+	&models.Item{},
+	&models.OwnedItem{},
+	&models.PetPresence{},
+	&models.RarityTier{},
+	&models.SelectedItem{},
+	&models.SessionVersion{},
+	&models.Transaction{},
+	&models.TwitchToken{},
+	&models.User{},
+}
+
+type column struct {
+	Type       string
+	PrimaryKey bool
+	Nullable   bool
+}
+
+func TestLoad(t *testing.T) {
+	for _, dialect := range []string{"postgres", "sqlite"} {
+		t.Run(dialect, func(t *testing.T) {
+			migrations, err := Load(dialect)
+
+			assert.NoError(t, err)
+			for i, migration := range migrations {
+				assert.Equal(t, i+1, migration.Version)
+			}
+		})
+	}
+
+	t.Run("both dialects have the same migrations", func(t *testing.T) {
+		postgresMigrations, err := Load("postgres")
+		assert.NoError(t, err)
+		sqliteMigrations, err := Load("sqlite")
+		assert.NoError(t, err)
+
+		assert.Equal(t, len(postgresMigrations), len(sqliteMigrations))
+		for i := range postgresMigrations {
+			assert.Equal(t, postgresMigrations[i].Name, sqliteMigrations[i].Name)
+		}
+	})
+}
+
+func TestSchemaMatchesModels(t *testing.T) {
+	t.Run("sqlite", func(t *testing.T) {
+		assertSchemaMatchesModels(t, openSqlite(), openSqlite())
+	})
+
+	t.Run("postgres", func(t *testing.T) {
+		dsn := os.Getenv("TEST_POSTGRES_DSN")
+		if dsn == "" {
+			t.Skip("TEST_POSTGRES_DSN is not set")
+		}
+		assertSchemaMatchesModels(t, openPostgres(t, dsn), openPostgres(t, dsn))
+	})
+
+	t.Run("postgres database from before migrations", func(t *testing.T) {
+		dsn := os.Getenv("TEST_POSTGRES_DSN")
+		if dsn == "" {
+			t.Skip("TEST_POSTGRES_DSN is not set")
+		}
+
+		existing := openPostgres(t, dsn)
+		if err := existing.Exec(baselineSchema).Error; err != nil {
+			t.Fatal(err)
+		}
+		if err := existing.Exec(baselineRows).Error; err != nil {
+			t.Fatal(err)
+		}
+
+		assertSchemaMatchesModels(t, existing, openPostgres(t, dsn))
+		assertSameColumns(t, existing, openPostgres(t, dsn))
+
+		var channel models.Channel
+		assert.NoError(t, existing.First(&channel, "channel_id = ?", "channel id").Error)
+		assert.Equal(t, 0, channel.IdleTimeoutSeconds)
+
+		var unnamed models.Channel
+		assert.NoError(t, existing.First(&unnamed, "channel_id = ?", "unnamed channel id").Error)
+		assert.Equal(t, "", unnamed.ChannelName)
+		assert.NotEqual(t, uuid.UUID{}, unnamed.OverlayId)
+	})
+}
+
+// The tables as the old db.sql created them, without its broken foreign key.
+const baselineSchema = `
+CREATE TABLE channels (
+	channel_id text NOT NULL,
+	channel_name text NULL,
+	overlay_id text NULL,
+	CONSTRAINT channels_pk PRIMARY KEY (channel_id)
+);
+
+CREATE TABLE items (
+	item_id uuid NOT NULL,
+	"name" varchar NOT NULL,
+	rarity varchar NOT NULL,
+	image varchar NOT NULL,
+	prev_img varchar NOT NULL,
+	CONSTRAINT items_pk PRIMARY KEY (item_id)
+);
+
+CREATE TABLE users (
+	user_id varchar NOT NULL,
+	username varchar NOT NULL,
+	CONSTRAINT users_pk PRIMARY KEY (user_id)
+);
+
+CREATE TABLE channel_items (
+	channel_id varchar NOT NULL,
+	item_id uuid NOT NULL,
+	CONSTRAINT channelitems_pk PRIMARY KEY (channel_id, item_id),
+	CONSTRAINT channelitems_unique UNIQUE (item_id),
+	CONSTRAINT channelitems_items_fk FOREIGN KEY (item_id) REFERENCES items(item_id)
+);
+
+CREATE TABLE default_channel_items (
+	channel_id varchar NOT NULL,
+	item_id uuid NOT NULL,
+	CONSTRAINT defaultchannelitems_pk PRIMARY KEY (channel_id),
+	CONSTRAINT defaultchannelitems_channelitems_fk FOREIGN KEY (channel_id,item_id) REFERENCES channel_items(channel_id,item_id)
+);
+
+CREATE TABLE owned_items (
+	user_id varchar NOT NULL,
+	transaction_id varchar NOT NULL,
+	item_id uuid NOT NULL,
+	channel_id varchar NOT NULL,
+	CONSTRAINT owneditems_pk PRIMARY KEY (user_id, item_id, channel_id),
+	CONSTRAINT owneditems_unique UNIQUE (transaction_id),
+	CONSTRAINT owneditems_channelitems_fk FOREIGN KEY (channel_id,item_id) REFERENCES channel_items(channel_id,item_id),
+	CONSTRAINT owneditems_users_fk FOREIGN KEY (user_id) REFERENCES users(user_id)
+);
+
+CREATE TABLE selected_items (
+	user_id varchar NOT NULL,
+	channel_id varchar NOT NULL,
+	item_id uuid NOT NULL,
+	CONSTRAINT selecteditems_pk PRIMARY KEY (user_id, channel_id),
+	CONSTRAINT selecteditems_channelitems_fk FOREIGN KEY (channel_id,item_id) REFERENCES channel_items(channel_id,item_id),
+	CONSTRAINT selecteditems_users_fk FOREIGN KEY (user_id) REFERENCES users(user_id)
+);`
+
+const baselineRows = `
+INSERT INTO channels (channel_id, channel_name, overlay_id) VALUES ('channel id', 'channel', '1c5a2e7e-5d1f-4b0c-9d55-3d0c8d3c6f11');
+INSERT INTO channels (channel_id, channel_name, overlay_id) VALUES ('unnamed channel id', NULL, NULL);
+INSERT INTO items (item_id, "name", rarity, image, prev_img) VALUES ('9b0f0d1e-8a3c-4c6e-b1f5-0a2e4c6d8f10', 'red', 'common', 'red.png', 'red.png');
+INSERT INTO channel_items (channel_id, item_id) VALUES ('channel id', '9b0f0d1e-8a3c-4c6e-b1f5-0a2e4c6d8f10');
+INSERT INTO users (user_id, username) VALUES ('user id', 'user');
+INSERT INTO owned_items (user_id, transaction_id, item_id, channel_id) VALUES ('user id', '4e3b2a19-0c8d-4f7e-a6b5-9d8c7b6a5f40', '9b0f0d1e-8a3c-4c6e-b1f5-0a2e4c6d8f10', 'channel id');`
+
+func TestUpAndDown(t *testing.T) {
+	db := openSqlite()
+	migrations, err := Load("sqlite")
+	assert.NoError(t, err)
+	latest := migrations[len(migrations)-1].Version
+
+	applied, err := Up(db)
+	assert.NoError(t, err)
+	assert.Equal(t, len(migrations), applied)
+
+	applied, err = Up(db)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, applied)
+
+	version, err := Version(db)
+	assert.NoError(t, err)
+	assert.Equal(t, latest, version)
+
+	reverted, err := Down(db, len(migrations)+1)
+	assert.NoError(t, err)
+	assert.Equal(t, len(migrations), reverted)
+
+	tables, err := db.Migrator().GetTables()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"schema_migrations"}, tables)
+
+	applied, err = Up(db)
+	assert.NoError(t, err)
+	assert.Equal(t, len(migrations), applied)
+}
+
+func TestInitialMigrationAddsRarityTiers(t *testing.T) {
+	db := openSqlite()
+	_, err := Up(db)
+	assert.NoError(t, err)
+
+	tiers := []models.RarityTier{}
+	assert.NoError(t, db.Order("sort_order").Find(&tiers).Error)
+
+	assert.Equal(t, []models.RarityTier{
+		{Name: models.Common, Sku: "common", BitsCost: 100, Colour: "#9e9e9e", SortOrder: 0},
+		{Name: models.Uncommon, Sku: "uncommon", BitsCost: 500, Colour: "#4caf50", SortOrder: 1},
+	}, tiers)
+}
+
+// Migrates one database and auto migrates the other from the models, then
+// checks both have the same tables, columns, column types and primary keys,
+// and that the migrated one has the indexes the models ask for.
+func assertSchemaMatchesModels(t *testing.T, migrated, autoMigrated *gorm.DB) {
+	_, err := Up(migrated)
+	assert.NoError(t, err)
+	assert.NoError(t, autoMigrated.AutoMigrate(allModels...))
+
+	migratedTables, err := migrated.Migrator().GetTables()
+	assert.NoError(t, err)
+	autoMigratedTables, err := autoMigrated.Migrator().GetTables()
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, append(autoMigratedTables, "schema_migrations"), migratedTables)
+
+	for _, model := range allModels {
+		stmt := &gorm.Statement{DB: migrated}
+		assert.NoError(t, stmt.Parse(model))
+		table := stmt.Schema.Table
+
+		// AutoMigrate leaves every column but the primary keys nullable, so
+		// nullability is only compared between migrated databases.
+		assert.Equal(t, withoutNullability(columns(t, autoMigrated, table)), withoutNullability(columns(t, migrated, table)), table)
+
+		for name := range stmt.Schema.ParseIndexes() {
+			assert.True(t, migrated.Migrator().HasIndex(model, name), "%s has no index %s", table, name)
+		}
+
+		migratedTypes, err := migrated.Migrator().ColumnTypes(model)
+		assert.NoError(t, err)
+		for _, columnType := range migratedTypes {
+			field := stmt.Schema.LookUpField(columnType.Name())
+			if field == nil || !field.Unique {
+				continue
+			}
+			unique, _ := columnType.Unique()
+			assert.True(t, unique, "%s.%s is not unique", table, columnType.Name())
+		}
+	}
+}
+
+// Migrates both databases and checks their tables have the same columns,
+// nullability included.
+func assertSameColumns(t *testing.T, migrated, other *gorm.DB) {
+	_, err := Up(migrated)
+	assert.NoError(t, err)
+	_, err = Up(other)
+	assert.NoError(t, err)
+
+	for _, model := range allModels {
+		stmt := &gorm.Statement{DB: migrated}
+		assert.NoError(t, stmt.Parse(model))
+		table := stmt.Schema.Table
+
+		assert.Equal(t, columns(t, other, table), columns(t, migrated, table), table)
+	}
+}
+
+func withoutNullability(columns map[string]column) map[string]column {
+	for name, column := range columns {
+		column.Nullable = false
+		columns[name] = column
+	}
+	return columns
+}
+
+func columns(t *testing.T, db *gorm.DB, table string) map[string]column {
+	columnTypes, err := db.Migrator().ColumnTypes(table)
+	assert.NoError(t, err)
+
+	columns := map[string]column{}
+	for _, columnType := range columnTypes {
+		primaryKey, _ := columnType.PrimaryKey()
+		nullable, _ := columnType.Nullable()
+		columns[columnType.Name()] = column{
+			Type:       strings.ToLower(columnType.DatabaseTypeName()),
+			PrimaryKey: primaryKey,
+			Nullable:   nullable,
+		}
+	}
+	return columns
+}
+
+func openSqlite() *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{TranslateError: true})
+	if err != nil {
+		panic(err)
+	}
+	return db
+}
+
+// Opens the database in a schema of its own, which is dropped when the test ends.
+func openPostgres(t *testing.T, dsn string) *gorm.DB {
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{TranslateError: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// search_path is set per connection, so keep to one.
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	sqlDB.SetConnMaxLifetime(0)
+	sqlDB.SetConnMaxIdleTime(0)
+
+	schema := "test_" + strings.ReplaceAll(uuid.NewString(), "-", "")
+	if err := db.Exec(fmt.Sprintf("CREATE SCHEMA %s", schema)).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Exec(fmt.Sprintf("SET search_path TO %s", schema)).Error; err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		db.Exec(fmt.Sprintf("DROP SCHEMA %s CASCADE", schema))
+		sqlDB.Close()
+	})
+
+	return db
+}
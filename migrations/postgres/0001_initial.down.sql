@@ -0,0 +1,13 @@
+DROP TABLE rarity_tiers;
+DROP TABLE transactions;
+DROP TABLE twitch_tokens;
+DROP TABLE bot_channels;
+DROP TABLE bots;
+DROP TABLE pet_presences;
+DROP TABLE selected_items;
+DROP TABLE owned_items;
+DROP TABLE default_channel_items;
+DROP TABLE channel_items;
+DROP TABLE users;
+DROP TABLE items;
+DROP TABLE channels;
@@ -0,0 +1,129 @@
+-- The schema as it was when migrations were introduced. Databases created
+-- before then already have some of these tables, so missing ones are created
+-- and existing ones are brought up to date by the next migration.
+
+CREATE TABLE IF NOT EXISTS channels (
+    channel_id text NOT NULL,
+    channel_name text NOT NULL DEFAULT '',
+    overlay_id uuid NOT NULL,
+    previous_overlay_id uuid NOT NULL DEFAULT '00000000-0000-0000-0000-000000000000',
+    previous_overlay_id_expires_at timestamptz NOT NULL DEFAULT '0001-01-01 00:00:00+00',
+    idle_timeout_seconds bigint NOT NULL DEFAULT 0,
+    CONSTRAINT channels_pk PRIMARY KEY (channel_id)
+);
+
+CREATE TABLE IF NOT EXISTS items (
+    item_id uuid NOT NULL,
+    "name" text NOT NULL,
+    rarity text NOT NULL,
+    image text NOT NULL,
+    prev_img text NOT NULL,
+    CONSTRAINT items_pk PRIMARY KEY (item_id)
+);
+
+CREATE TABLE IF NOT EXISTS users (
+    user_id text NOT NULL,
+    username text NOT NULL,
+    CONSTRAINT users_pk PRIMARY KEY (user_id)
+);
+
+CREATE TABLE IF NOT EXISTS channel_items (
+    channel_id text NOT NULL,
+    item_id uuid NOT NULL,
+    "position" bigint NOT NULL DEFAULT 0,
+    retired boolean NOT NULL DEFAULT false,
+    CONSTRAINT channelitems_pk PRIMARY KEY (channel_id, item_id),
+    CONSTRAINT channelitems_unique UNIQUE (item_id),
+    CONSTRAINT channelitems_channels_fk FOREIGN KEY (channel_id) REFERENCES channels(channel_id),
+    CONSTRAINT channelitems_items_fk FOREIGN KEY (item_id) REFERENCES items(item_id)
+);
+
+CREATE TABLE IF NOT EXISTS default_channel_items (
+    channel_id text NOT NULL,
+    item_id uuid NOT NULL,
+    CONSTRAINT defaultchannelitems_pk PRIMARY KEY (channel_id),
+    CONSTRAINT defaultchannelitems_channelitems_fk FOREIGN KEY (channel_id, item_id) REFERENCES channel_items(channel_id, item_id)
+);
+
+CREATE TABLE IF NOT EXISTS owned_items (
+    user_id text NOT NULL,
+    channel_id text NOT NULL,
+    item_id uuid NOT NULL,
+    transaction_id uuid NOT NULL UNIQUE,
+    CONSTRAINT owneditems_pk PRIMARY KEY (user_id, channel_id, item_id),
+    CONSTRAINT owneditems_channelitems_fk FOREIGN KEY (channel_id, item_id) REFERENCES channel_items(channel_id, item_id)
+);
+CREATE INDEX IF NOT EXISTS owneditems_userid_idx ON owned_items (user_id, channel_id);
+
+CREATE TABLE IF NOT EXISTS selected_items (
+    user_id text NOT NULL,
+    channel_id text NOT NULL,
+    item_id uuid NOT NULL,
+    CONSTRAINT selecteditems_pk PRIMARY KEY (user_id, channel_id),
+    CONSTRAINT selecteditems_channelitems_fk FOREIGN KEY (channel_id, item_id) REFERENCES channel_items(channel_id, item_id)
+);
+
+CREATE TABLE IF NOT EXISTS pet_presences (
+    channel_id text NOT NULL,
+    user_id text NOT NULL,
+    username text NOT NULL,
+    image text NOT NULL,
+    joined_at timestamptz NOT NULL,
+    last_seen timestamptz NOT NULL,
+    CONSTRAINT petpresences_pk PRIMARY KEY (channel_id, user_id)
+);
+CREATE INDEX IF NOT EXISTS idx_pet_presences_last_seen ON pet_presences (last_seen);
+
+CREATE TABLE IF NOT EXISTS bots (
+    bot_id uuid NOT NULL,
+    "name" text NOT NULL,
+    key_hash text NOT NULL,
+    CONSTRAINT bots_pk PRIMARY KEY (bot_id)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_bots_key_hash ON bots (key_hash);
+
+CREATE TABLE IF NOT EXISTS bot_channels (
+    bot_id uuid NOT NULL,
+    channel_id text NOT NULL,
+    CONSTRAINT botchannels_pk PRIMARY KEY (bot_id, channel_id),
+    CONSTRAINT botchannels_bots_fk FOREIGN KEY (bot_id) REFERENCES bots(bot_id)
+);
+
+CREATE TABLE IF NOT EXISTS twitch_tokens (
+    user_id text NOT NULL,
+    access_token text NOT NULL,
+    refresh_token text NOT NULL,
+    scopes text NOT NULL,
+    expires_at timestamptz NOT NULL,
+    CONSTRAINT twitchtokens_pk PRIMARY KEY (user_id)
+);
+
+CREATE TABLE IF NOT EXISTS transactions (
+    transaction_id uuid NOT NULL,
+    user_id text NOT NULL,
+    channel_id text NOT NULL,
+    item_id uuid NOT NULL,
+    sku text NOT NULL,
+    bits_cost bigint NOT NULL,
+    purchased_at timestamptz NOT NULL,
+    claims text NOT NULL,
+    CONSTRAINT transactions_pk PRIMARY KEY (transaction_id)
+);
+CREATE INDEX IF NOT EXISTS idx_transactions_user_id ON transactions (user_id);
+
+-- An empty channel_id makes a tier global.
+CREATE TABLE IF NOT EXISTS rarity_tiers (
+    channel_id text NOT NULL DEFAULT '',
+    "name" text NOT NULL,
+    sku text NOT NULL,
+    bits_cost bigint NOT NULL,
+    colour text NOT NULL,
+    sort_order bigint NOT NULL DEFAULT 0,
+    CONSTRAINT raritytiers_pk PRIMARY KEY (channel_id, "name")
+);
+
+-- The Bits costs are only shown to viewers, what they pay is set on the extension's products.
+INSERT INTO rarity_tiers (channel_id, "name", sku, bits_cost, colour, sort_order) VALUES
+    ('', 'common', 'common', 100, '#9e9e9e', 0),
+    ('', 'uncommon', 'uncommon', 500, '#4caf50', 1)
+ON CONFLICT DO NOTHING;
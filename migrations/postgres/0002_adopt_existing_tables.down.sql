@@ -0,0 +1,3 @@
+-- The columns and types this fixed are part of the initial schema, so there
+-- is nothing to undo.
+SELECT 1;
@@ -0,0 +1,49 @@
+-- Brings tables created before migrations, by AutoMigrate or from the old
+-- db.sql, up to the schema of the initial migration. Does nothing to tables
+-- the initial migration created.
+
+ALTER TABLE channels
+    ADD COLUMN IF NOT EXISTS previous_overlay_id uuid NOT NULL DEFAULT '00000000-0000-0000-0000-000000000000',
+    ADD COLUMN IF NOT EXISTS previous_overlay_id_expires_at timestamptz NOT NULL DEFAULT '0001-01-01 00:00:00+00',
+    ADD COLUMN IF NOT EXISTS idle_timeout_seconds bigint NOT NULL DEFAULT 0,
+    ALTER COLUMN channel_name TYPE text,
+    ALTER COLUMN overlay_id TYPE uuid USING overlay_id::uuid;
+
+-- db.sql left channel_name and overlay_id nullable. Channels without an
+-- overlay id get a new one, as they could not have had an overlay before.
+UPDATE channels SET channel_name = '' WHERE channel_name IS NULL;
+UPDATE channels SET overlay_id = gen_random_uuid() WHERE overlay_id IS NULL;
+
+ALTER TABLE channels
+    ALTER COLUMN channel_name SET DEFAULT '',
+    ALTER COLUMN channel_name SET NOT NULL,
+    ALTER COLUMN overlay_id SET NOT NULL;
+
+ALTER TABLE channel_items
+    ADD COLUMN IF NOT EXISTS "position" bigint NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS retired boolean NOT NULL DEFAULT false,
+    ALTER COLUMN channel_id TYPE text;
+
+-- db.sql used varchar where the models use text, and declared
+-- owned_items.transaction_id as varchar instead of uuid.
+ALTER TABLE items
+    ALTER COLUMN "name" TYPE text,
+    ALTER COLUMN rarity TYPE text,
+    ALTER COLUMN image TYPE text,
+    ALTER COLUMN prev_img TYPE text;
+
+ALTER TABLE users
+    ALTER COLUMN user_id TYPE text,
+    ALTER COLUMN username TYPE text;
+
+ALTER TABLE default_channel_items
+    ALTER COLUMN channel_id TYPE text;
+
+ALTER TABLE owned_items
+    ALTER COLUMN user_id TYPE text,
+    ALTER COLUMN channel_id TYPE text,
+    ALTER COLUMN transaction_id TYPE uuid USING transaction_id::uuid;
+
+ALTER TABLE selected_items
+    ALTER COLUMN user_id TYPE text,
+    ALTER COLUMN channel_id TYPE text;
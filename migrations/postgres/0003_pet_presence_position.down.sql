@@ -0,0 +1,2 @@
+ALTER TABLE pet_presences DROP COLUMN IF EXISTS position_y;
+ALTER TABLE pet_presences DROP COLUMN IF EXISTS position_x;
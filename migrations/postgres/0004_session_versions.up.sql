@@ -0,0 +1,7 @@
+-- Streamers without a row are at version 0, which is what sessions issued
+-- before this table existed carry.
+CREATE TABLE IF NOT EXISTS session_versions (
+    user_id text NOT NULL,
+    "version" bigint NOT NULL,
+    CONSTRAINT sessionversions_pk PRIMARY KEY (user_id)
+);
@@ -0,0 +1,3 @@
+-- SQLite databases are only created for tests, so there are never tables
+-- from before migrations to adopt.
+SELECT 1;
@@ -0,0 +1,2 @@
+ALTER TABLE pet_presences DROP COLUMN position_y;
+ALTER TABLE pet_presences DROP COLUMN position_x;
@@ -0,0 +1,4 @@
+-- Where overlays last placed each pet, so restored pets come back in the same
+-- spot. Pets that were never placed have no position.
+ALTER TABLE pet_presences ADD COLUMN position_x real NULL;
+ALTER TABLE pet_presences ADD COLUMN position_y real NULL;
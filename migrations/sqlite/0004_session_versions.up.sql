@@ -0,0 +1,7 @@
+-- Streamers without a row are at version 0, which is what sessions issued
+-- before this table existed carry.
+CREATE TABLE session_versions (
+    user_id text NOT NULL,
+    "version" integer NOT NULL,
+    PRIMARY KEY (user_id)
+);
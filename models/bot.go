@@ -0,0 +1,19 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"github.com/streampets/backend/twitch"
+)
+
+// A chat bot allowed to use the bot API. Only a hash of its API key is stored.
+type Bot struct {
+	BotId   uuid.UUID `gorm:"primaryKey;type:uuid"`
+	Name    string
+	KeyHash string `gorm:"uniqueIndex"`
+}
+
+// A channel a bot is allowed to act for.
+type BotChannel struct {
+	BotId     uuid.UUID `gorm:"primaryKey;type:uuid"`
+	ChannelId twitch.Id `gorm:"primaryKey"`
+}
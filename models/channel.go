@@ -1,6 +1,8 @@
 package models
 
 import (
+	"time"
+
 	"github.com/google/uuid"
 	"github.com/streampets/backend/twitch"
 )
@@ -9,4 +11,9 @@ type Channel struct {
 	ChannelId   twitch.Id `gorm:"primaryKey"`
 	ChannelName string
 	OverlayId   uuid.UUID `gorm:"type:uuid"`
+	// The overlay id before it was last rotated, still accepted until PreviousOverlayIdExpiresAt.
+	PreviousOverlayId          uuid.UUID `gorm:"type:uuid"`
+	PreviousOverlayIdExpiresAt time.Time
+	// How long pets may be idle before they leave the overlay, zero for the default.
+	IdleTimeoutSeconds int
 }
@@ -8,4 +8,15 @@ import (
 type ChannelItem struct {
 	ChannelId twitch.Id `gorm:"primaryKey"`
 	ItemId    uuid.UUID `gorm:"primaryKey;type:uuid"`
+	// Where the item comes in the channel's store, lowest first.
+	Position int
+	// Retired items are no longer sold, but viewers who bought them keep them.
+	Retired bool
+}
+
+// An item as the streamer sees it when managing their channel's items.
+type CatalogItem struct {
+	Item
+	Position int  `json:"position"`
+	Retired  bool `json:"retired"`
 }
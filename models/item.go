@@ -2,8 +2,10 @@ package models
 
 import "github.com/google/uuid"
 
+// The name of a RarityTier.
 type Rarity string
 
+// The rarities every channel starts with.
 const (
 	Common   Rarity = "common"
 	Uncommon Rarity = "uncommon"
@@ -9,5 +9,5 @@ type OwnedItem struct {
 	UserId        twitch.Id `gorm:"primaryKey"`
 	ChannelId     twitch.Id `gorm:"primaryKey"`
 	ItemId        uuid.UUID `gorm:"primaryKey;type:uuid"`
-	TransactionId uuid.UUID `gorm:"unique"`
+	TransactionId uuid.UUID `gorm:"unique;type:uuid"`
 }
@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+
+	"github.com/streampets/backend/twitch"
+)
+
+// A snapshot of a pet shown on a channel's overlay, kept so pets survive restarts.
+type PetPresence struct {
+	ChannelId twitch.Id `gorm:"primaryKey"`
+	UserId    twitch.Id `gorm:"primaryKey"`
+	Username  string
+	Image     string
+	JoinedAt  time.Time
+	LastSeen  time.Time `gorm:"index"`
+	// Where an overlay last placed the pet, nil if it never did.
+	PositionX *float64
+	PositionY *float64
+}
@@ -0,0 +1,16 @@
+package models
+
+import "github.com/streampets/backend/twitch"
+
+// A rarity items can have, and the Bits product that buys items of it.
+// Tiers without a channel id apply to every channel. A channel's own tier
+// replaces the global tier of the same name.
+type RarityTier struct {
+	ChannelId twitch.Id `gorm:"primaryKey" json:"-"`
+	Name      Rarity    `gorm:"primaryKey" json:"name"`
+	// The SKU of the extension's Bits product for the tier.
+	Sku       string `json:"sku"`
+	BitsCost  int    `json:"bits_cost"`
+	Colour    string `json:"colour"`
+	SortOrder int    `json:"sort_order"`
+}
@@ -0,0 +1,10 @@
+package models
+
+import "github.com/streampets/backend/twitch"
+
+// The version of a streamer's dashboard sessions. Logging out bumps it, which
+// ends every session issued with an older version.
+type SessionVersion struct {
+	UserId  twitch.Id `gorm:"primaryKey"`
+	Version int
+}
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/streampets/backend/twitch"
+)
+
+// A Bits purchase as recorded from its receipt. Claims holds the receipt's
+// claims as JSON so the purchase can be checked against Twitch later.
+type Transaction struct {
+	TransactionId uuid.UUID `gorm:"primaryKey;type:uuid"`
+	UserId        twitch.Id `gorm:"index"`
+	ChannelId     twitch.Id
+	ItemId        uuid.UUID `gorm:"type:uuid"`
+	Sku           string
+	BitsCost      int
+	PurchasedAt   time.Time
+	Claims        string
+}
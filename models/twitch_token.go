@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+
+	"github.com/streampets/backend/twitch"
+)
+
+// The Twitch tokens of a streamer who logged in to the dashboard, kept so the
+// backend can call Twitch on their behalf. Scopes are space separated.
+type TwitchToken struct {
+	UserId       twitch.Id `gorm:"primaryKey"`
+	AccessToken  string
+	RefreshToken string
+	Scopes       string
+	ExpiresAt    time.Time
+}
@@ -0,0 +1,99 @@
+package repositories
+
+import (
+	"github.com/google/uuid"
+	"github.com/streampets/backend/models"
+	"github.com/streampets/backend/twitch"
+	"gorm.io/gorm"
+)
+
+type BotRepo struct {
+	db *gorm.DB
+}
+
+func NewBotRepo(db *gorm.DB) *BotRepo {
+	return &BotRepo{db: db}
+}
+
+func (r *BotRepo) GetBotByKeyHash(keyHash string) (models.Bot, error) {
+	var bot models.Bot
+	result := r.db.Where("key_hash = ?", keyHash).First(&bot)
+	return bot, dbError(result.Error, "bot")
+}
+
+func (r *BotRepo) CanActForChannel(botId uuid.UUID, channelId twitch.Id) (bool, error) {
+	var count int64
+	result := r.db.Model(&models.BotChannel{}).Where("bot_id = ? AND channel_id = ?", botId, channelId).Count(&count)
+	return count > 0, dbError(result.Error, "bot_channel")
+}
+
+// Stores the bot along with the channels it may act for.
+func (r *BotRepo) CreateBot(bot models.Bot, channelIds []twitch.Id) error {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&bot).Error; err != nil {
+			return err
+		}
+
+		for _, channelId := range channelIds {
+			if err := tx.Create(&models.BotChannel{BotId: bot.BotId, ChannelId: channelId}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return dbError(err, "bot")
+}
+
+// Returns every bot, ordered by name.
+func (r *BotRepo) GetBots() ([]models.Bot, error) {
+	var bots []models.Bot
+	result := r.db.Order("name").Find(&bots)
+	return bots, dbError(result.Error, "bot")
+}
+
+// Returns the channels each bot may act for.
+func (r *BotRepo) GetBotChannels() ([]models.BotChannel, error) {
+	var channels []models.BotChannel
+	result := r.db.Order("channel_id").Find(&channels)
+	return channels, dbError(result.Error, "bot_channel")
+}
+
+// Replaces the channels the bot may act for.
+func (r *BotRepo) SetBotChannels(botId uuid.UUID, channelIds []twitch.Id) error {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&models.Bot{}, "bot_id = ?", botId).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("bot_id = ?", botId).Delete(&models.BotChannel{}).Error; err != nil {
+			return err
+		}
+
+		for _, channelId := range channelIds {
+			if err := tx.Create(&models.BotChannel{BotId: botId, ChannelId: channelId}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return dbError(err, "bot")
+}
+
+// Deletes the bot and its channels, so its API key stops working.
+func (r *BotRepo) DeleteBot(botId uuid.UUID) error {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("bot_id = ?", botId).Delete(&models.BotChannel{}).Error; err != nil {
+			return err
+		}
+
+		result := tx.Where("bot_id = ?", botId).Delete(&models.Bot{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+	return dbError(err, "bot")
+}
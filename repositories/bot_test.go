@@ -0,0 +1,76 @@
+package repositories
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/streampets/backend/apperrors"
+	"github.com/streampets/backend/models"
+	"github.com/streampets/backend/test"
+	"github.com/streampets/backend/twitch"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestBotRepo(t *testing.T) {
+	channelId := twitch.Id("channel id")
+	bot := models.Bot{BotId: uuid.New(), Name: "bot", KeyHash: "key hash"}
+
+	repo := NewBotRepo(test.CreateTestDB())
+
+	err := repo.CreateBot(bot, []twitch.Id{channelId})
+	assert.NoError(t, err)
+
+	got, err := repo.GetBotByKeyHash(bot.KeyHash)
+	assert.NoError(t, err)
+	assert.Equal(t, bot, got)
+
+	_, err = repo.GetBotByKeyHash("unknown")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	allowed, err := repo.CanActForChannel(bot.BotId, channelId)
+	assert.NoError(t, err)
+	assert.True(t, allowed)
+
+	allowed, err = repo.CanActForChannel(bot.BotId, "other channel")
+	assert.NoError(t, err)
+	assert.False(t, allowed)
+}
+
+func TestBotRepoManagement(t *testing.T) {
+	bot := models.Bot{BotId: uuid.New(), Name: "bot", KeyHash: "key hash"}
+	other := models.Bot{BotId: uuid.New(), Name: "another bot", KeyHash: "other key hash"}
+
+	repo := NewBotRepo(test.CreateTestDB())
+	assert.NoError(t, repo.CreateBot(bot, []twitch.Id{"channel one"}))
+	assert.NoError(t, repo.CreateBot(other, []twitch.Id{"channel one"}))
+
+	bots, err := repo.GetBots()
+	assert.NoError(t, err)
+	assert.Equal(t, []models.Bot{other, bot}, bots)
+
+	assert.NoError(t, repo.SetBotChannels(bot.BotId, []twitch.Id{"channel two", "channel three"}))
+
+	allowed, err := repo.CanActForChannel(bot.BotId, "channel one")
+	assert.NoError(t, err)
+	assert.False(t, allowed)
+
+	allowed, err = repo.CanActForChannel(bot.BotId, "channel two")
+	assert.NoError(t, err)
+	assert.True(t, allowed)
+
+	err = repo.SetBotChannels(uuid.New(), []twitch.Id{"channel one"})
+	assert.Equal(t, apperrors.NotFound, apperrors.KindOf(err))
+
+	assert.NoError(t, repo.DeleteBot(bot.BotId))
+
+	_, err = repo.GetBotByKeyHash(bot.KeyHash)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	channels, err := repo.GetBotChannels()
+	assert.NoError(t, err)
+	assert.Equal(t, []models.BotChannel{{BotId: other.BotId, ChannelId: "channel one"}}, channels)
+
+	err = repo.DeleteBot(bot.BotId)
+	assert.Equal(t, apperrors.NotFound, apperrors.KindOf(err))
+}
@@ -2,11 +2,14 @@ package repositories
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/streampets/backend/apperrors"
 	"github.com/streampets/backend/models"
 	"github.com/streampets/backend/twitch"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type ErrNoOverlayId struct {
@@ -17,6 +20,18 @@ func (e *ErrNoOverlayId) Error() string {
 	return fmt.Sprintf("no overlay id associated with the channel id %s", e.ChannelId)
 }
 
+func (e *ErrNoOverlayId) Kind() apperrors.Kind {
+	return apperrors.NotFound
+}
+
+func (e *ErrNoOverlayId) Code() string {
+	return "overlay_id_not_found"
+}
+
+func (e *ErrNoOverlayId) Message() string {
+	return e.Error()
+}
+
 func NewErrNoOverlayId(channelId twitch.Id) error {
 	return &ErrNoOverlayId{ChannelId: channelId}
 }
@@ -35,8 +50,83 @@ func (r *ChannelRepo) GetOverlayId(channelId twitch.Id) (uuid.UUID, error) {
 	if result := r.db.Where("channel_id = ?", channelId).First(&channel); result.Error == gorm.ErrRecordNotFound {
 		return uuid.UUID{}, NewErrNoOverlayId(channelId)
 	} else if result.Error != nil {
-		return uuid.UUID{}, result.Error
+		return uuid.UUID{}, dbError(result.Error, "channel")
 	}
 
 	return channel.OverlayId, nil
 }
+
+// Returns the overlay id the channel had before its last rotation and until
+// when it is still accepted.
+func (r *ChannelRepo) GetPreviousOverlayId(channelId twitch.Id) (uuid.UUID, time.Time, error) {
+	var channel models.Channel
+	result := r.db.Where("channel_id = ?", channelId).First(&channel)
+	if result.Error != nil {
+		return uuid.UUID{}, time.Time{}, dbError(result.Error, "channel")
+	}
+
+	return channel.PreviousOverlayId, channel.PreviousOverlayIdExpiresAt, nil
+}
+
+// Replaces the channel's overlay id. The one it replaces is kept as the
+// previous overlay id until previousExpiresAt. Returns the channel as it was
+// before, locking it meanwhile so concurrent rotations see each other's ids.
+func (r *ChannelRepo) RotateOverlayId(channelId twitch.Id, overlayId uuid.UUID, previousExpiresAt time.Time) (models.Channel, error) {
+	var before models.Channel
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("channel_id = ?", channelId).First(&before).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&models.Channel{}).Where("channel_id = ?", channelId).Updates(map[string]interface{}{
+			"overlay_id":                     overlayId,
+			"previous_overlay_id":            before.OverlayId,
+			"previous_overlay_id_expires_at": previousExpiresAt,
+		}).Error
+	})
+	if err != nil {
+		return models.Channel{}, dbError(err, "channel")
+	}
+
+	return before, nil
+}
+
+// Returns zero when the channel has no idle timeout of its own.
+func (r *ChannelRepo) GetIdleTimeout(channelId twitch.Id) (time.Duration, error) {
+	var channel models.Channel
+	if result := r.db.Where("channel_id = ?", channelId).First(&channel); result.Error == gorm.ErrRecordNotFound {
+		return 0, nil
+	} else if result.Error != nil {
+		return 0, dbError(result.Error, "channel")
+	}
+
+	return time.Duration(channel.IdleTimeoutSeconds) * time.Second, nil
+}
+
+func (r *ChannelRepo) GetChannel(channelId twitch.Id) (models.Channel, error) {
+	var channel models.Channel
+	result := r.db.Where("channel_id = ?", channelId).First(&channel)
+	return channel, dbError(result.Error, "channel")
+}
+
+// Stores a new channel along with its items. defaultItemId has to be one of the items.
+func (r *ChannelRepo) CreateChannel(channel models.Channel, items []models.Item, defaultItemId uuid.UUID) error {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&channel).Error; err != nil {
+			return dbError(err, "channel")
+		}
+
+		for i, item := range items {
+			if err := tx.Create(&item).Error; err != nil {
+				return dbError(err, "item")
+			}
+			if err := tx.Create(&models.ChannelItem{ChannelId: channel.ChannelId, ItemId: item.ItemId, Position: i}).Error; err != nil {
+				return dbError(err, "channel_item")
+			}
+		}
+
+		return dbError(tx.Create(&models.DefaultChannelItem{ChannelId: channel.ChannelId, ItemId: defaultItemId}).Error, "default_item")
+	})
+
+	return err
+}
@@ -2,8 +2,10 @@ package repositories
 
 import (
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/streampets/backend/apperrors"
 	"github.com/streampets/backend/models"
 	"github.com/streampets/backend/test"
 	"github.com/streampets/backend/twitch"
@@ -31,3 +33,129 @@ func TestGetOverlayId(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, overlayId, got)
 }
+
+func TestGetIdleTimeout(t *testing.T) {
+	t.Run("channel timeout is returned", func(t *testing.T) {
+		channelId := twitch.Id("channel id")
+		channel := models.Channel{ChannelId: channelId, OverlayId: uuid.New(), IdleTimeoutSeconds: 90}
+
+		db := test.CreateTestDB()
+		if result := db.Create(&channel); result.Error != nil {
+			panic(result.Error)
+		}
+
+		got, err := NewChannelRepo(db).GetIdleTimeout(channelId)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 90*time.Second, got)
+	})
+
+	t.Run("unknown channel has no timeout", func(t *testing.T) {
+		got, err := NewChannelRepo(test.CreateTestDB()).GetIdleTimeout("unknown")
+
+		assert.NoError(t, err)
+		assert.Equal(t, time.Duration(0), got)
+	})
+}
+
+func TestCreateChannel(t *testing.T) {
+	channel := models.Channel{ChannelId: "channel id", ChannelName: "channel", OverlayId: uuid.New()}
+	items := []models.Item{
+		{ItemId: uuid.New(), Name: "first", Rarity: models.Common, Image: "first"},
+		{ItemId: uuid.New(), Name: "second", Rarity: models.Uncommon, Image: "second"},
+	}
+
+	t.Run("channel created with items and default item", func(t *testing.T) {
+		db := test.CreateTestDB()
+		repo := NewChannelRepo(db)
+
+		err := repo.CreateChannel(channel, items, items[0].ItemId)
+		assert.NoError(t, err)
+
+		got, err := repo.GetChannel(channel.ChannelId)
+		assert.NoError(t, err)
+		assert.Equal(t, channel, got)
+
+		itemRepo := NewItemRepository(db)
+
+		channelItems, err := itemRepo.GetChannelsItems(channel.ChannelId)
+		assert.NoError(t, err)
+		assert.ElementsMatch(t, items, channelItems)
+
+		defaultItem, err := itemRepo.GetDefaultItem(channel.ChannelId)
+		assert.NoError(t, err)
+		assert.Equal(t, items[0], defaultItem)
+	})
+
+	t.Run("nothing created when channel exists", func(t *testing.T) {
+		db := test.CreateTestDB()
+		repo := NewChannelRepo(db)
+
+		assert.NoError(t, repo.CreateChannel(channel, items[:1], items[0].ItemId))
+
+		err := repo.CreateChannel(channel, items[1:], items[1].ItemId)
+		assert.Equal(t, apperrors.Conflict, apperrors.KindOf(err))
+
+		var count int64
+		db.Model(&models.Item{}).Count(&count)
+		assert.Equal(t, int64(1), count)
+	})
+}
+
+func TestRotateOverlayId(t *testing.T) {
+	t.Run("overlay id replaced and previous one kept", func(t *testing.T) {
+		channel := models.Channel{ChannelId: "channel id", OverlayId: uuid.New()}
+		overlayId := uuid.New()
+		expiresAt := time.Now().UTC().Truncate(time.Second).Add(time.Hour)
+
+		db := test.CreateTestDB()
+		if result := db.Create(&channel); result.Error != nil {
+			panic(result.Error)
+		}
+
+		repo := NewChannelRepo(db)
+
+		before, err := repo.RotateOverlayId(channel.ChannelId, overlayId, expiresAt)
+		assert.NoError(t, err)
+		assert.Equal(t, channel.OverlayId, before.OverlayId)
+
+		got, err := repo.GetOverlayId(channel.ChannelId)
+		assert.NoError(t, err)
+		assert.Equal(t, overlayId, got)
+
+		previous, gotExpiresAt, err := repo.GetPreviousOverlayId(channel.ChannelId)
+		assert.NoError(t, err)
+		assert.Equal(t, channel.OverlayId, previous)
+		assert.True(t, expiresAt.Equal(gotExpiresAt))
+	})
+
+	t.Run("previous overlay id it replaced returned", func(t *testing.T) {
+		channel := models.Channel{ChannelId: "channel id", OverlayId: uuid.New()}
+		expiresAt := time.Now().UTC().Truncate(time.Second).Add(time.Hour)
+
+		db := test.CreateTestDB()
+		if result := db.Create(&channel); result.Error != nil {
+			panic(result.Error)
+		}
+
+		repo := NewChannelRepo(db)
+
+		second := uuid.New()
+		_, err := repo.RotateOverlayId(channel.ChannelId, second, expiresAt)
+		assert.NoError(t, err)
+
+		before, err := repo.RotateOverlayId(channel.ChannelId, uuid.New(), expiresAt)
+		assert.NoError(t, err)
+		assert.Equal(t, second, before.OverlayId)
+		assert.Equal(t, channel.OverlayId, before.PreviousOverlayId)
+		assert.True(t, expiresAt.Equal(before.PreviousOverlayIdExpiresAt))
+	})
+
+	t.Run("not found error when channel does not exist", func(t *testing.T) {
+		repo := NewChannelRepo(test.CreateTestDB())
+
+		_, err := repo.RotateOverlayId("channel id", uuid.New(), time.Now())
+
+		assert.Equal(t, apperrors.NotFound, apperrors.KindOf(err))
+	})
+}
@@ -0,0 +1,35 @@
+package repositories
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"net"
+
+	"github.com/streampets/backend/apperrors"
+	"gorm.io/gorm"
+)
+
+// Converts a database error into a domain error about the resource, such as
+// "item_not_found". The original error is kept for errors.Is.
+func dbError(err error, resource string) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return apperrors.Wrap(err, apperrors.NotFound, resource+"_not_found", resource+" not found")
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		return apperrors.Wrap(err, apperrors.Conflict, resource+"_exists", resource+" already exists")
+	case isUnavailable(err):
+		return apperrors.Wrap(err, apperrors.Unavailable, "database_unavailable", "database unavailable")
+	default:
+		return err
+	}
+}
+
+func isUnavailable(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, context.DeadlineExceeded) ||
+		errors.As(err, &netErr)
+}
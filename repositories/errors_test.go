@@ -0,0 +1,38 @@
+package repositories
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/streampets/backend/apperrors"
+	"github.com/streampets/backend/models"
+	"github.com/streampets/backend/test"
+	"github.com/streampets/backend/twitch"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestDbError(t *testing.T) {
+	t.Run("missing records are not found", func(t *testing.T) {
+		_, err := NewItemRepository(test.CreateTestDB()).GetItemById(uuid.New())
+
+		assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+		assert.Equal(t, apperrors.NotFound, apperrors.KindOf(err))
+	})
+
+	t.Run("duplicate records conflict", func(t *testing.T) {
+		repo := NewBotRepo(test.CreateTestDB())
+		bot := models.Bot{BotId: uuid.New(), Name: "bot", KeyHash: "key hash"}
+
+		assert.NoError(t, repo.CreateBot(bot, []twitch.Id{}))
+		err := repo.CreateBot(bot, []twitch.Id{})
+
+		assert.Equal(t, apperrors.Conflict, apperrors.KindOf(err))
+	})
+
+	t.Run("other errors are left alone", func(t *testing.T) {
+		err := dbError(assert.AnError, "item")
+
+		assert.Equal(t, assert.AnError, err)
+	})
+}
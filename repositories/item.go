@@ -19,64 +19,203 @@ func NewItemRepository(db *gorm.DB) *itemRepository {
 func (repo *itemRepository) GetItemByName(channelId twitch.Id, itemName string) (models.Item, error) {
 	var item models.Item
 	result := repo.db.Joins("JOIN channel_items ON channel_items.item_id = items.item_id AND channel_items.channel_id = ? AND items.name = ?", channelId, itemName).First(&item)
-	return item, result.Error
+	return item, dbError(result.Error, "item")
 }
 
 func (repo *itemRepository) GetItemById(itemId uuid.UUID) (models.Item, error) {
 	var item models.Item
 	result := repo.db.Where("item_id = ?", itemId).First(&item)
-	return item, result.Error
+	return item, dbError(result.Error, "item")
 }
 
 func (repo *itemRepository) GetSelectedItem(userId, channelId twitch.Id) (models.Item, error) {
 	var item models.Item
 	result := repo.db.Joins(`JOIN selected_items ON selected_items.item_id = items.item_id AND selected_items.user_id = ? AND selected_items.channel_id = ?`, userId, channelId).First(&item)
-	return item, result.Error
+	return item, dbError(result.Error, "selected_item")
 }
 
 func (repo *itemRepository) SetSelectedItem(userId, channelId twitch.Id, itemId uuid.UUID) error {
-	return repo.db.Clauses(clause.OnConflict{
+	result := repo.db.Clauses(clause.OnConflict{
 		DoNothing: false,
 		UpdateAll: true,
 	}).Create(&models.SelectedItem{
 		UserId:    userId,
 		ChannelId: channelId,
 		ItemId:    itemId,
-	}).Error
+	})
+	return dbError(result.Error, "selected_item")
 }
 
 func (repo *itemRepository) DeleteSelectedItem(userId, channelId twitch.Id) error {
 	selectedItem := models.SelectedItem{UserId: userId, ChannelId: channelId}
-	return repo.db.Delete(&selectedItem).Error
+	return dbError(repo.db.Delete(&selectedItem).Error, "selected_item")
 }
 
+// Returns the items on sale in the channel's store, in store order.
 func (repo *itemRepository) GetChannelsItems(channelId twitch.Id) ([]models.Item, error) {
 	var items []models.Item
-	result := repo.db.Joins("JOIN channel_items ON channel_items.item_id = items.item_id AND channel_items.channel_id = ?", channelId).Find(&items)
-	return items, result.Error
+	result := repo.db.Joins("JOIN channel_items ON channel_items.item_id = items.item_id AND channel_items.channel_id = ?", channelId).
+		Where("channel_items.retired = ?", false).
+		Order("channel_items.position").
+		Find(&items)
+	return items, dbError(result.Error, "item")
+}
+
+// Returns all of the channel's items, retired ones included, in store order.
+func (repo *itemRepository) GetCatalog(channelId twitch.Id) ([]models.CatalogItem, error) {
+	items := []models.CatalogItem{}
+	result := repo.catalog(channelId).Order("channel_items.position").Find(&items)
+	return items, dbError(result.Error, "item")
+}
+
+func (repo *itemRepository) GetCatalogItem(channelId twitch.Id, itemId uuid.UUID) (models.CatalogItem, error) {
+	var item models.CatalogItem
+	result := repo.catalog(channelId).Where("items.item_id = ?", itemId).Take(&item)
+	return item, dbError(result.Error, "item")
+}
+
+func (repo *itemRepository) catalog(channelId twitch.Id) *gorm.DB {
+	return repo.db.Model(&models.Item{}).
+		Select("items.*, channel_items.position, channel_items.retired").
+		Joins("JOIN channel_items ON channel_items.item_id = items.item_id AND channel_items.channel_id = ?", channelId)
+}
+
+// Adds a new item to the end of the channel's store.
+func (repo *itemRepository) CreateItem(channelId twitch.Id, item models.Item) error {
+	return repo.db.Transaction(func(tx *gorm.DB) error {
+		var position int
+		result := tx.Model(&models.ChannelItem{}).Select("COALESCE(MAX(position) + 1, 0)").Where("channel_id = ?", channelId).Scan(&position)
+		if result.Error != nil {
+			return dbError(result.Error, "channel_item")
+		}
+
+		if err := tx.Create(&item).Error; err != nil {
+			return dbError(err, "item")
+		}
+
+		return dbError(tx.Create(&models.ChannelItem{ChannelId: channelId, ItemId: item.ItemId, Position: position}).Error, "channel_item")
+	})
+}
+
+// Saves the item's name, rarity and images.
+func (repo *itemRepository) UpdateItem(item models.Item) error {
+	result := repo.db.Model(&item).Select("name", "rarity", "image", "prev_img").Updates(item)
+	if result.Error == nil && result.RowsAffected == 0 {
+		return dbError(gorm.ErrRecordNotFound, "item")
+	}
+	return dbError(result.Error, "item")
+}
+
+func (repo *itemRepository) SetItemRetired(channelId twitch.Id, itemId uuid.UUID, retired bool) error {
+	result := repo.db.Model(&models.ChannelItem{}).Where("channel_id = ? AND item_id = ?", channelId, itemId).Update("retired", retired)
+	if result.Error == nil && result.RowsAffected == 0 {
+		return dbError(gorm.ErrRecordNotFound, "item")
+	}
+	return dbError(result.Error, "item")
+}
+
+// Deletes the item from the channel, unless a viewer owns or has selected
+// it, in which case it is retired instead. Returns whether it was retired.
+func (repo *itemRepository) RemoveItem(channelId twitch.Id, itemId uuid.UUID) (bool, error) {
+	retired := false
+	err := repo.db.Transaction(func(tx *gorm.DB) error {
+		var uses int64
+		result := tx.Raw(`SELECT (SELECT COUNT(*) FROM owned_items WHERE item_id = ?) + (SELECT COUNT(*) FROM selected_items WHERE item_id = ?)`, itemId, itemId).Scan(&uses)
+		if result.Error != nil {
+			return dbError(result.Error, "item")
+		}
+
+		if uses > 0 {
+			retired = true
+			return dbError(tx.Model(&models.ChannelItem{}).Where("channel_id = ? AND item_id = ?", channelId, itemId).Update("retired", true).Error, "item")
+		}
+
+		result = tx.Where("channel_id = ? AND item_id = ?", channelId, itemId).Delete(&models.ChannelItem{})
+		if result.Error != nil {
+			return dbError(result.Error, "channel_item")
+		}
+		if result.RowsAffected == 0 {
+			return dbError(gorm.ErrRecordNotFound, "item")
+		}
+
+		return dbError(tx.Where("item_id = ?", itemId).Delete(&models.Item{}).Error, "item")
+	})
+
+	return retired, err
+}
+
+// Puts the channel's items in the store in the order given.
+func (repo *itemRepository) SetItemPositions(channelId twitch.Id, itemIds []uuid.UUID) error {
+	return repo.db.Transaction(func(tx *gorm.DB) error {
+		for position, itemId := range itemIds {
+			result := tx.Model(&models.ChannelItem{}).Where("channel_id = ? AND item_id = ?", channelId, itemId).Update("position", position)
+			if result.Error != nil {
+				return dbError(result.Error, "channel_item")
+			}
+			if result.RowsAffected == 0 {
+				return dbError(gorm.ErrRecordNotFound, "item")
+			}
+		}
+		return nil
+	})
 }
 
 func (repo *itemRepository) GetOwnedItems(channelId, userId twitch.Id) ([]models.Item, error) {
 	var items []models.Item
 	result := repo.db.Joins("JOIN owned_items ON owned_items.item_id = items.item_id AND owned_items.channel_id = ? AND owned_items.user_id = ?", channelId, userId).Find(&items)
-	return items, result.Error
+	return items, dbError(result.Error, "owned_item")
 }
 
-func (repo *itemRepository) AddOwnedItem(userId twitch.Id, itemId, transactionId uuid.UUID) error {
-	var channelItem models.ChannelItem
-	result := repo.db.Where("item_id = ?", itemId).Find(&channelItem)
-	if result.Error != nil {
-		return result.Error
-	}
-
-	result = repo.db.Create(&models.OwnedItem{
-		UserId:        userId,
-		ChannelId:     channelItem.ChannelId,
-		ItemId:        itemId,
-		TransactionId: transactionId,
+// Records the purchase, gives the user the item it bought and, if asked to,
+// selects it for them, all in one transaction. The item must be on sale in
+// the transaction's channel. Receipts can be replayed, so if the transaction
+// was already recorded nothing changes and the original transaction is
+// returned instead.
+func (repo *itemRepository) AddTransaction(transaction models.Transaction, selectItem bool) (models.Transaction, error) {
+	err := repo.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&transaction)
+		if result.Error != nil {
+			return dbError(result.Error, "transaction")
+		}
+
+		if result.RowsAffected == 0 {
+			return dbError(tx.Where("transaction_id = ?", transaction.TransactionId).First(&transaction).Error, "transaction")
+		}
+
+		result = tx.Where("channel_id = ? AND item_id = ? AND retired = ?", transaction.ChannelId, transaction.ItemId, false).First(&models.ChannelItem{})
+		if result.Error != nil {
+			return dbError(result.Error, "item")
+		}
+
+		result = tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.User{UserId: transaction.UserId})
+		if result.Error != nil {
+			return dbError(result.Error, "user")
+		}
+
+		result = tx.Create(&models.OwnedItem{
+			UserId:        transaction.UserId,
+			ChannelId:     transaction.ChannelId,
+			ItemId:        transaction.ItemId,
+			TransactionId: transaction.TransactionId,
+		})
+		if result.Error != nil || !selectItem {
+			return dbError(result.Error, "owned_item")
+		}
+
+		return dbError(tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(&models.SelectedItem{
+			UserId:    transaction.UserId,
+			ChannelId: transaction.ChannelId,
+			ItemId:    transaction.ItemId,
+		}).Error, "selected_item")
 	})
 
-	return result.Error
+	return transaction, err
+}
+
+func (repo *itemRepository) GetTransaction(transactionId uuid.UUID) (models.Transaction, error) {
+	var transaction models.Transaction
+	result := repo.db.Where("transaction_id = ?", transactionId).First(&transaction)
+	return transaction, dbError(result.Error, "transaction")
 }
 
 func (repo *itemRepository) CheckOwnedItem(userId twitch.Id, itemId uuid.UUID) (bool, error) {
@@ -85,14 +224,25 @@ func (repo *itemRepository) CheckOwnedItem(userId twitch.Id, itemId uuid.UUID) (
 		return false, nil
 	}
 	if result.Error != nil {
-		return false, result.Error
+		return false, dbError(result.Error, "owned_item")
 	}
 
 	return true, nil
 }
 
+func (repo *itemRepository) SetDefaultItem(channelId twitch.Id, itemId uuid.UUID) error {
+	result := repo.db.Clauses(clause.OnConflict{
+		DoNothing: false,
+		UpdateAll: true,
+	}).Create(&models.DefaultChannelItem{
+		ChannelId: channelId,
+		ItemId:    itemId,
+	})
+	return dbError(result.Error, "default_item")
+}
+
 func (repo *itemRepository) GetDefaultItem(channelId twitch.Id) (models.Item, error) {
 	var item models.Item
 	result := repo.db.Joins("JOIN default_channel_items ON default_channel_items.item_id = items.item_id AND default_channel_items.channel_id = ?", channelId).First(&item)
-	return item, result.Error
+	return item, dbError(result.Error, "default_item")
 }
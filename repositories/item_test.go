@@ -2,12 +2,15 @@ package repositories
 
 import (
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/streampets/backend/apperrors"
 	"github.com/streampets/backend/models"
 	"github.com/streampets/backend/test"
 	"github.com/streampets/backend/twitch"
 	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
 )
 
 func TestGetSelectedItem(t *testing.T) {
@@ -208,29 +211,6 @@ func TestGetOwnedItems(t *testing.T) {
 	assert.NoError(t, err)
 }
 
-func TestAddOwnedItem(t *testing.T) {
-	channelId := twitch.Id("channel id")
-	userId := twitch.Id("user id")
-	itemId := uuid.New()
-	transactionId := uuid.New()
-
-	channelItem := models.ChannelItem{
-		ItemId:    itemId,
-		ChannelId: channelId,
-	}
-
-	db := test.CreateTestDB()
-	if result := db.Create(&channelItem); result.Error != nil {
-		panic(result.Error)
-	}
-
-	itemRepo := NewItemRepository(db)
-
-	err := itemRepo.AddOwnedItem(userId, itemId, transactionId)
-
-	assert.NoError(t, err)
-}
-
 func TestCheckOwnedItem(t *testing.T) {
 	t.Run("true when user owns item", func(t *testing.T) {
 		userId := twitch.Id("user id")
@@ -264,3 +244,366 @@ func TestCheckOwnedItem(t *testing.T) {
 		assert.False(t, owned)
 	})
 }
+
+func TestAddTransaction(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	transaction := models.Transaction{
+		TransactionId: uuid.New(),
+		UserId:        "user id",
+		ChannelId:     "channel id",
+		ItemId:        uuid.New(),
+		Sku:           "common",
+		BitsCost:      100,
+		PurchasedAt:   now,
+		Claims:        `{"data":{}}`,
+	}
+
+	setUpDB := func(channelItems ...models.ChannelItem) *gorm.DB {
+		db := test.CreateTestDB()
+		for _, channelItem := range channelItems {
+			if result := db.Create(&channelItem); result.Error != nil {
+				panic(result.Error)
+			}
+		}
+		return db
+	}
+	onSale := models.ChannelItem{ChannelId: transaction.ChannelId, ItemId: transaction.ItemId}
+
+	countTransactions := func(db *gorm.DB) int64 {
+		var count int64
+		db.Model(&models.Transaction{}).Count(&count)
+		return count
+	}
+
+	t.Run("transaction recorded and item owned", func(t *testing.T) {
+		db := setUpDB(onSale)
+		itemRepo := NewItemRepository(db)
+
+		recorded, err := itemRepo.AddTransaction(transaction, false)
+		assert.NoError(t, err)
+		assert.Equal(t, transaction, recorded)
+
+		owned, err := itemRepo.CheckOwnedItem(transaction.UserId, transaction.ItemId)
+		assert.NoError(t, err)
+		assert.True(t, owned)
+
+		var ownedItem models.OwnedItem
+		assert.NoError(t, db.First(&ownedItem).Error)
+		assert.Equal(t, transaction.ChannelId, ownedItem.ChannelId)
+
+		err = db.First(&models.SelectedItem{}).Error
+		assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	})
+
+	t.Run("user added when not already stored", func(t *testing.T) {
+		db := setUpDB(onSale)
+		if result := db.Create(&models.User{UserId: "other user id", Username: "other"}); result.Error != nil {
+			panic(result.Error)
+		}
+		itemRepo := NewItemRepository(db)
+
+		_, err := itemRepo.AddTransaction(transaction, false)
+		assert.NoError(t, err)
+
+		var user models.User
+		assert.NoError(t, db.Where("user_id = ?", transaction.UserId).First(&user).Error)
+	})
+
+	t.Run("bought item selected when asked to", func(t *testing.T) {
+		db := setUpDB(onSale)
+		if result := db.Create(&models.SelectedItem{UserId: transaction.UserId, ChannelId: transaction.ChannelId, ItemId: uuid.New()}); result.Error != nil {
+			panic(result.Error)
+		}
+		itemRepo := NewItemRepository(db)
+
+		_, err := itemRepo.AddTransaction(transaction, true)
+		assert.NoError(t, err)
+
+		var selected models.SelectedItem
+		assert.NoError(t, db.Where("user_id = ? AND channel_id = ?", transaction.UserId, transaction.ChannelId).First(&selected).Error)
+		assert.Equal(t, transaction.ItemId, selected.ItemId)
+	})
+
+	t.Run("nothing recorded when item is not on sale in the channel", func(t *testing.T) {
+		tests := map[string]models.ChannelItem{
+			"other channel": {ChannelId: "other channel id", ItemId: transaction.ItemId},
+			"retired":       {ChannelId: transaction.ChannelId, ItemId: transaction.ItemId, Retired: true},
+		}
+
+		for name, channelItem := range tests {
+			t.Run(name, func(t *testing.T) {
+				db := setUpDB(channelItem)
+				itemRepo := NewItemRepository(db)
+
+				_, err := itemRepo.AddTransaction(transaction, true)
+				assert.Equal(t, apperrors.NotFound, apperrors.KindOf(err))
+
+				assert.Equal(t, int64(0), countTransactions(db))
+				owned, err := itemRepo.CheckOwnedItem(transaction.UserId, transaction.ItemId)
+				assert.NoError(t, err)
+				assert.False(t, owned)
+				assert.ErrorIs(t, db.First(&models.User{}).Error, gorm.ErrRecordNotFound)
+			})
+		}
+	})
+
+	t.Run("original transaction returned when replayed", func(t *testing.T) {
+		replayed := transaction
+		replayed.ItemId = uuid.New()
+
+		db := setUpDB(onSale, models.ChannelItem{ChannelId: transaction.ChannelId, ItemId: replayed.ItemId})
+		itemRepo := NewItemRepository(db)
+
+		_, err := itemRepo.AddTransaction(transaction, false)
+		assert.NoError(t, err)
+
+		recorded, err := itemRepo.AddTransaction(replayed, true)
+		assert.NoError(t, err)
+		assert.Equal(t, transaction.ItemId, recorded.ItemId)
+
+		owned, err := itemRepo.CheckOwnedItem(transaction.UserId, replayed.ItemId)
+		assert.NoError(t, err)
+		assert.False(t, owned)
+
+		assert.Equal(t, int64(1), countTransactions(db))
+		assert.ErrorIs(t, db.First(&models.SelectedItem{}).Error, gorm.ErrRecordNotFound)
+	})
+
+	t.Run("transaction not recorded when item already owned", func(t *testing.T) {
+		db := setUpDB(onSale)
+		itemRepo := NewItemRepository(db)
+
+		_, err := itemRepo.AddTransaction(transaction, false)
+		assert.NoError(t, err)
+
+		again := transaction
+		again.TransactionId = uuid.New()
+
+		_, err = itemRepo.AddTransaction(again, false)
+		assert.Equal(t, apperrors.Conflict, apperrors.KindOf(err))
+
+		assert.Equal(t, int64(1), countTransactions(db))
+	})
+}
+
+func TestGetTransaction(t *testing.T) {
+	t.Run("recorded transaction returned", func(t *testing.T) {
+		db := test.CreateTestDB()
+		itemId := uuid.New()
+		if result := db.Create(&models.ChannelItem{ChannelId: "channel id", ItemId: itemId}); result.Error != nil {
+			panic(result.Error)
+		}
+		itemRepo := NewItemRepository(db)
+
+		transaction := models.Transaction{
+			TransactionId: uuid.New(),
+			UserId:        "user id",
+			ChannelId:     "channel id",
+			ItemId:        itemId,
+			Sku:           "common",
+			BitsCost:      100,
+			PurchasedAt:   time.Now().UTC().Truncate(time.Second),
+			Claims:        `{"data":{}}`,
+		}
+		_, err := itemRepo.AddTransaction(transaction, false)
+		assert.NoError(t, err)
+
+		got, err := itemRepo.GetTransaction(transaction.TransactionId)
+		assert.NoError(t, err)
+		assert.Equal(t, transaction.ItemId, got.ItemId)
+	})
+
+	t.Run("not found when transaction never recorded", func(t *testing.T) {
+		itemRepo := NewItemRepository(test.CreateTestDB())
+
+		_, err := itemRepo.GetTransaction(uuid.New())
+		assert.Equal(t, apperrors.NotFound, apperrors.KindOf(err))
+	})
+}
+
+func TestGetCatalog(t *testing.T) {
+	channelId := twitch.Id("channel id")
+	first := models.Item{ItemId: uuid.New(), Name: "first"}
+	second := models.Item{ItemId: uuid.New(), Name: "second"}
+	retired := models.Item{ItemId: uuid.New(), Name: "retired"}
+
+	db := test.CreateTestDB()
+	for _, record := range []interface{}{
+		&first, &second, &retired,
+		&models.ChannelItem{ChannelId: channelId, ItemId: second.ItemId, Position: 1},
+		&models.ChannelItem{ChannelId: channelId, ItemId: retired.ItemId, Position: 2, Retired: true},
+		&models.ChannelItem{ChannelId: channelId, ItemId: first.ItemId, Position: 0},
+	} {
+		if result := db.Create(record); result.Error != nil {
+			panic(result.Error)
+		}
+	}
+
+	itemRepo := NewItemRepository(db)
+
+	t.Run("catalog includes retired items in store order", func(t *testing.T) {
+		catalog, err := itemRepo.GetCatalog(channelId)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []models.CatalogItem{
+			{Item: first, Position: 0},
+			{Item: second, Position: 1},
+			{Item: retired, Position: 2, Retired: true},
+		}, catalog)
+	})
+
+	t.Run("store excludes retired items", func(t *testing.T) {
+		items, err := itemRepo.GetChannelsItems(channelId)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []models.Item{first, second}, items)
+	})
+
+	t.Run("catalog item not found in another channel", func(t *testing.T) {
+		_, err := itemRepo.GetCatalogItem("other channel", first.ItemId)
+
+		assert.Equal(t, apperrors.NotFound, apperrors.KindOf(err))
+	})
+}
+
+func TestCreateItem(t *testing.T) {
+	channelId := twitch.Id("channel id")
+
+	db := test.CreateTestDB()
+	itemRepo := NewItemRepository(db)
+
+	first := models.Item{ItemId: uuid.New(), Name: "first"}
+	second := models.Item{ItemId: uuid.New(), Name: "second"}
+
+	assert.NoError(t, itemRepo.CreateItem(channelId, first))
+	assert.NoError(t, itemRepo.CreateItem(channelId, second))
+
+	item, err := itemRepo.GetCatalogItem(channelId, second.ItemId)
+	assert.NoError(t, err)
+	assert.Equal(t, models.CatalogItem{Item: second, Position: 1}, item)
+}
+
+func TestUpdateItem(t *testing.T) {
+	db := test.CreateTestDB()
+	itemRepo := NewItemRepository(db)
+
+	item := models.Item{ItemId: uuid.New(), Name: "old", Rarity: models.Common, Image: "old", PrevImg: "old"}
+	if result := db.Create(&item); result.Error != nil {
+		panic(result.Error)
+	}
+
+	t.Run("item updated", func(t *testing.T) {
+		updated := models.Item{ItemId: item.ItemId, Name: "new", Rarity: models.Uncommon, Image: "new", PrevImg: "new prev"}
+		assert.NoError(t, itemRepo.UpdateItem(updated))
+
+		got, err := itemRepo.GetItemById(item.ItemId)
+		assert.NoError(t, err)
+		assert.Equal(t, updated, got)
+	})
+
+	t.Run("not found error when item does not exist", func(t *testing.T) {
+		err := itemRepo.UpdateItem(models.Item{ItemId: uuid.New(), Name: "new"})
+
+		assert.Equal(t, apperrors.NotFound, apperrors.KindOf(err))
+	})
+}
+
+func TestRemoveItem(t *testing.T) {
+	channelId := twitch.Id("channel id")
+	userId := twitch.Id("user id")
+
+	setUp := func() (*itemRepository, models.Item) {
+		db := test.CreateTestDB()
+		itemRepo := NewItemRepository(db)
+
+		item := models.Item{ItemId: uuid.New(), Name: "item"}
+		if err := itemRepo.CreateItem(channelId, item); err != nil {
+			panic(err)
+		}
+		return itemRepo, item
+	}
+
+	t.Run("unused item deleted", func(t *testing.T) {
+		itemRepo, item := setUp()
+
+		retired, err := itemRepo.RemoveItem(channelId, item.ItemId)
+		assert.NoError(t, err)
+		assert.False(t, retired)
+
+		_, err = itemRepo.GetItemById(item.ItemId)
+		assert.Equal(t, apperrors.NotFound, apperrors.KindOf(err))
+	})
+
+	t.Run("owned item retired", func(t *testing.T) {
+		itemRepo, item := setUp()
+		if err := itemRepo.db.Create(&models.OwnedItem{UserId: userId, ChannelId: channelId, ItemId: item.ItemId, TransactionId: uuid.New()}).Error; err != nil {
+			panic(err)
+		}
+
+		retired, err := itemRepo.RemoveItem(channelId, item.ItemId)
+		assert.NoError(t, err)
+		assert.True(t, retired)
+
+		got, err := itemRepo.GetCatalogItem(channelId, item.ItemId)
+		assert.NoError(t, err)
+		assert.True(t, got.Retired)
+	})
+
+	t.Run("selected item retired", func(t *testing.T) {
+		itemRepo, item := setUp()
+		if err := itemRepo.SetSelectedItem(userId, channelId, item.ItemId); err != nil {
+			panic(err)
+		}
+
+		retired, err := itemRepo.RemoveItem(channelId, item.ItemId)
+		assert.NoError(t, err)
+		assert.True(t, retired)
+	})
+
+	t.Run("not found error when item is in another channel", func(t *testing.T) {
+		itemRepo, item := setUp()
+
+		_, err := itemRepo.RemoveItem("other channel", item.ItemId)
+		assert.Equal(t, apperrors.NotFound, apperrors.KindOf(err))
+
+		_, err = itemRepo.GetItemById(item.ItemId)
+		assert.NoError(t, err)
+	})
+}
+
+func TestSetItemPositions(t *testing.T) {
+	channelId := twitch.Id("channel id")
+
+	db := test.CreateTestDB()
+	itemRepo := NewItemRepository(db)
+
+	first := models.Item{ItemId: uuid.New(), Name: "first"}
+	second := models.Item{ItemId: uuid.New(), Name: "second"}
+	assert.NoError(t, itemRepo.CreateItem(channelId, first))
+	assert.NoError(t, itemRepo.CreateItem(channelId, second))
+
+	assert.NoError(t, itemRepo.SetItemPositions(channelId, []uuid.UUID{second.ItemId, first.ItemId}))
+
+	items, err := itemRepo.GetChannelsItems(channelId)
+	assert.NoError(t, err)
+	assert.Equal(t, []models.Item{second, first}, items)
+}
+
+func TestSetDefaultItem(t *testing.T) {
+	channelId := twitch.Id("channel id")
+
+	db := test.CreateTestDB()
+	itemRepo := NewItemRepository(db)
+
+	first := models.Item{ItemId: uuid.New(), Name: "first"}
+	second := models.Item{ItemId: uuid.New(), Name: "second"}
+	assert.NoError(t, itemRepo.CreateItem(channelId, first))
+	assert.NoError(t, itemRepo.CreateItem(channelId, second))
+
+	assert.NoError(t, itemRepo.SetDefaultItem(channelId, first.ItemId))
+	assert.NoError(t, itemRepo.SetDefaultItem(channelId, second.ItemId))
+
+	got, err := itemRepo.GetDefaultItem(channelId)
+	assert.NoError(t, err)
+	assert.Equal(t, second, got)
+}
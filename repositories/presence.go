@@ -0,0 +1,52 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/streampets/backend/models"
+	"github.com/streampets/backend/twitch"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type PresenceRepo struct {
+	db *gorm.DB
+}
+
+func NewPresenceRepo(db *gorm.DB) *PresenceRepo {
+	return &PresenceRepo{db: db}
+}
+
+// Stores the given pets, replacing the stored pets of their channels. Other
+// channels are left alone, as another instance may have stored them. Their
+// pets are left out when restoring once they are too old.
+func (r *PresenceRepo) SavePresence(pets []models.PetPresence) error {
+	userIds := map[twitch.Id][]twitch.Id{}
+	for _, pet := range pets {
+		userIds[pet.ChannelId] = append(userIds[pet.ChannelId], pet.UserId)
+	}
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		for channelId, userIds := range userIds {
+			result := tx.Where("channel_id = ? AND user_id NOT IN ?", channelId, userIds).Delete(&models.PetPresence{})
+			if result.Error != nil {
+				return result.Error
+			}
+		}
+		if len(pets) == 0 {
+			return nil
+		}
+		return tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "channel_id"}, {Name: "user_id"}},
+			UpdateAll: true,
+		}).CreateInBatches(pets, 100).Error
+	})
+	return dbError(err, "presence")
+}
+
+// Returns the stored pets that were last seen after the given time.
+func (r *PresenceRepo) LoadPresence(seenAfter time.Time) ([]models.PetPresence, error) {
+	var pets []models.PetPresence
+	result := r.db.Where("last_seen > ?", seenAfter).Find(&pets)
+	return pets, dbError(result.Error, "presence")
+}
@@ -0,0 +1,128 @@
+package repositories
+
+import (
+	"testing"
+	"time"
+
+	"github.com/streampets/backend/models"
+	"github.com/streampets/backend/test"
+	"github.com/streampets/backend/twitch"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSavePresence(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+
+	old := models.PetPresence{ChannelId: "channel id", UserId: "old user id", JoinedAt: now, LastSeen: now}
+	pet := models.PetPresence{ChannelId: "channel id", UserId: twitch.Id("user id"), JoinedAt: now, LastSeen: now}
+
+	db := test.CreateTestDB()
+	if result := db.Create(&old); result.Error != nil {
+		panic(result.Error)
+	}
+
+	repo := NewPresenceRepo(db)
+
+	err := repo.SavePresence([]models.PetPresence{pet})
+	assert.NoError(t, err)
+
+	var got []models.PetPresence
+	if result := db.Find(&got); result.Error != nil {
+		panic(result.Error)
+	}
+
+	assert.Len(t, got, 1)
+	assert.Equal(t, pet.UserId, got[0].UserId)
+}
+
+func TestSavePresenceKeepsOtherChannels(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+
+	other := models.PetPresence{ChannelId: "other channel id", UserId: "other user id", LastSeen: now}
+	pet := models.PetPresence{ChannelId: "channel id", UserId: "user id", LastSeen: now}
+
+	db := test.CreateTestDB()
+	if result := db.Create(&other); result.Error != nil {
+		panic(result.Error)
+	}
+
+	repo := NewPresenceRepo(db)
+
+	assert.NoError(t, repo.SavePresence([]models.PetPresence{pet}))
+	assert.NoError(t, repo.SavePresence([]models.PetPresence{}))
+
+	got, err := repo.LoadPresence(now.Add(-time.Hour))
+	assert.NoError(t, err)
+	if !assert.Len(t, got, 2) {
+		return
+	}
+	assert.ElementsMatch(t, []twitch.Id{other.UserId, pet.UserId}, []twitch.Id{got[0].UserId, got[1].UserId})
+}
+
+func TestSavePresenceUpdatesStoredPets(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+
+	stored := models.PetPresence{ChannelId: "channel id", UserId: "user id", Image: "old image", LastSeen: now.Add(-time.Minute)}
+	pet := models.PetPresence{ChannelId: "channel id", UserId: "user id", Image: "new image", LastSeen: now}
+
+	db := test.CreateTestDB()
+	if result := db.Create(&stored); result.Error != nil {
+		panic(result.Error)
+	}
+
+	repo := NewPresenceRepo(db)
+
+	assert.NoError(t, repo.SavePresence([]models.PetPresence{pet}))
+
+	got, err := repo.LoadPresence(now.Add(-time.Hour))
+	assert.NoError(t, err)
+	assert.Len(t, got, 1)
+	assert.Equal(t, "new image", got[0].Image)
+	assert.True(t, now.Equal(got[0].LastSeen))
+}
+
+func TestLoadPresence(t *testing.T) {
+	now := time.Now()
+
+	recent := models.PetPresence{ChannelId: "channel id", UserId: "recent user id", LastSeen: now}
+	stale := models.PetPresence{ChannelId: "channel id", UserId: "stale user id", LastSeen: now.Add(-time.Hour)}
+
+	db := test.CreateTestDB()
+	if result := db.Create(&[]models.PetPresence{recent, stale}); result.Error != nil {
+		panic(result.Error)
+	}
+
+	repo := NewPresenceRepo(db)
+
+	got, err := repo.LoadPresence(now.Add(-time.Minute))
+
+	assert.NoError(t, err)
+	assert.Len(t, got, 1)
+	assert.Equal(t, recent.UserId, got[0].UserId)
+}
+
+func TestPresencePositionRoundTrip(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	x, y := 12.5, 40.0
+
+	placed := models.PetPresence{ChannelId: "channel id", UserId: "placed user id", LastSeen: now, PositionX: &x, PositionY: &y}
+	unplaced := models.PetPresence{ChannelId: "channel id", UserId: "unplaced user id", LastSeen: now}
+
+	db := test.CreateTestDB()
+	repo := NewPresenceRepo(db)
+
+	err := repo.SavePresence([]models.PetPresence{placed, unplaced})
+	assert.NoError(t, err)
+
+	got, err := repo.LoadPresence(now.Add(-time.Minute))
+	assert.NoError(t, err)
+
+	byUser := map[twitch.Id]models.PetPresence{}
+	for _, pet := range got {
+		byUser[pet.UserId] = pet
+	}
+	assert.Equal(t, &x, byUser[placed.UserId].PositionX)
+	assert.Equal(t, &y, byUser[placed.UserId].PositionY)
+	assert.Nil(t, byUser[unplaced.UserId].PositionX)
+	assert.Nil(t, byUser[unplaced.UserId].PositionY)
+}
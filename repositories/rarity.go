@@ -0,0 +1,22 @@
+package repositories
+
+import (
+	"github.com/streampets/backend/models"
+	"github.com/streampets/backend/twitch"
+	"gorm.io/gorm"
+)
+
+type RarityRepo struct {
+	db *gorm.DB
+}
+
+func NewRarityRepo(db *gorm.DB) *RarityRepo {
+	return &RarityRepo{db: db}
+}
+
+// Returns the global rarity tiers followed by the channel's own.
+func (r *RarityRepo) GetRarityTiers(channelId twitch.Id) ([]models.RarityTier, error) {
+	tiers := []models.RarityTier{}
+	result := r.db.Where("channel_id IN ?", []twitch.Id{"", channelId}).Order("channel_id, sort_order, name").Find(&tiers)
+	return tiers, dbError(result.Error, "rarity_tier")
+}
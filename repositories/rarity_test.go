@@ -0,0 +1,37 @@
+package repositories
+
+import (
+	"testing"
+
+	"github.com/streampets/backend/models"
+	"github.com/streampets/backend/test"
+	"github.com/streampets/backend/twitch"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetRarityTiers(t *testing.T) {
+	channelId := twitch.Id("channel id")
+
+	own := models.RarityTier{ChannelId: channelId, Name: "rare", Sku: "rare"}
+	other := models.RarityTier{ChannelId: "other channel", Name: "legendary", Sku: "legendary"}
+
+	db := test.CreateTestDB()
+	// The common and uncommon tiers are added by the migrations.
+	global := []models.RarityTier{}
+	if result := db.Order("sort_order").Find(&global); result.Error != nil {
+		panic(result.Error)
+	}
+
+	for _, tier := range []models.RarityTier{own, other} {
+		if result := db.Create(&tier); result.Error != nil {
+			panic(result.Error)
+		}
+	}
+
+	tiers, err := NewRarityRepo(db).GetRarityTiers(channelId)
+
+	assert.NoError(t, err)
+	if assert.Len(t, global, 2) {
+		assert.Equal(t, []models.RarityTier{global[0], global[1], own}, tiers)
+	}
+}
@@ -0,0 +1,35 @@
+package repositories
+
+import (
+	"github.com/streampets/backend/models"
+	"github.com/streampets/backend/twitch"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type SessionVersionRepo struct {
+	db *gorm.DB
+}
+
+func NewSessionVersionRepo(db *gorm.DB) *SessionVersionRepo {
+	return &SessionVersionRepo{db: db}
+}
+
+// Returns the version of the user's sessions, 0 if they never logged out.
+func (r *SessionVersionRepo) GetSessionVersion(userId twitch.Id) (int, error) {
+	var versions []models.SessionVersion
+	result := r.db.Where("user_id = ?", userId).Limit(1).Find(&versions)
+	if result.Error != nil || len(versions) == 0 {
+		return 0, dbError(result.Error, "session_version")
+	}
+	return versions[0].Version, nil
+}
+
+// Moves the user's sessions on to the next version.
+func (r *SessionVersionRepo) BumpSessionVersion(userId twitch.Id) error {
+	result := r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{"version": gorm.Expr("session_versions.version + 1")}),
+	}).Create(&models.SessionVersion{UserId: userId, Version: 1})
+	return dbError(result.Error, "session_version")
+}
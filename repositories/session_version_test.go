@@ -0,0 +1,37 @@
+package repositories
+
+import (
+	"testing"
+
+	"github.com/streampets/backend/test"
+	"github.com/streampets/backend/twitch"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSessionVersion(t *testing.T) {
+	userId := twitch.Id("user id")
+
+	t.Run("version 0 when never bumped", func(t *testing.T) {
+		repo := NewSessionVersionRepo(test.CreateTestDB())
+
+		version, err := repo.GetSessionVersion(userId)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 0, version)
+	})
+
+	t.Run("version goes up each time it is bumped", func(t *testing.T) {
+		repo := NewSessionVersionRepo(test.CreateTestDB())
+
+		assert.NoError(t, repo.BumpSessionVersion(userId))
+		assert.NoError(t, repo.BumpSessionVersion(userId))
+
+		version, err := repo.GetSessionVersion(userId)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, version)
+
+		other, err := repo.GetSessionVersion("other user id")
+		assert.NoError(t, err)
+		assert.Equal(t, 0, other)
+	})
+}
@@ -0,0 +1,28 @@
+package repositories
+
+import (
+	"github.com/streampets/backend/models"
+	"github.com/streampets/backend/twitch"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type TwitchTokenRepo struct {
+	db *gorm.DB
+}
+
+func NewTwitchTokenRepo(db *gorm.DB) *TwitchTokenRepo {
+	return &TwitchTokenRepo{db: db}
+}
+
+// Stores the user's tokens, replacing the ones from their previous login.
+func (r *TwitchTokenRepo) SaveTwitchToken(token models.TwitchToken) error {
+	result := r.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&token)
+	return dbError(result.Error, "twitch_token")
+}
+
+func (r *TwitchTokenRepo) GetTwitchToken(userId twitch.Id) (models.TwitchToken, error) {
+	var token models.TwitchToken
+	result := r.db.Where("user_id = ?", userId).First(&token)
+	return token, dbError(result.Error, "twitch_token")
+}
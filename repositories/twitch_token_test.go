@@ -0,0 +1,31 @@
+package repositories
+
+import (
+	"testing"
+	"time"
+
+	"github.com/streampets/backend/models"
+	"github.com/streampets/backend/test"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestTwitchTokenRepo(t *testing.T) {
+	expiresAt := time.Now().UTC().Truncate(time.Second)
+	first := models.TwitchToken{UserId: "user id", AccessToken: "first access", RefreshToken: "first refresh", ExpiresAt: expiresAt}
+	second := models.TwitchToken{UserId: "user id", AccessToken: "second access", RefreshToken: "second refresh", Scopes: "a b", ExpiresAt: expiresAt.Add(time.Hour)}
+
+	repo := NewTwitchTokenRepo(test.CreateTestDB())
+
+	assert.NoError(t, repo.SaveTwitchToken(first))
+	assert.NoError(t, repo.SaveTwitchToken(second))
+
+	got, err := repo.GetTwitchToken("user id")
+	assert.NoError(t, err)
+	assert.Equal(t, second.RefreshToken, got.RefreshToken)
+	assert.Equal(t, second.Scopes, got.Scopes)
+	assert.True(t, second.ExpiresAt.Equal(got.ExpiresAt))
+
+	_, err = repo.GetTwitchToken("unknown")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
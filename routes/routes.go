@@ -13,7 +13,10 @@ func RegisterRoutes(
 	overlay *controllers.OverlayController,
 	extension *controllers.ExtensionController,
 	dashboard *controllers.DashboardController,
+	assets *controllers.AssetController,
 	twitchBot *controllers.TwitchBotController,
+	botAuth gin.HandlerFunc,
+	eventSub *controllers.EventSubController,
 ) {
 	overlayUrl := os.Getenv("OVERLAY_URL")
 	extensionUrl := os.Getenv("EXTENSION_URL")
@@ -27,16 +30,42 @@ func RegisterRoutes(
 	}))
 
 	r.GET("/overlay/listen", overlay.HandleListen)
+	r.GET("/overlay/ws", overlay.HandleWebSocket)
 
 	r.GET("/extension/user", extension.GetUserData)
 	r.GET("/extension/items", extension.GetStoreData)
 	r.POST("/extension/items", extension.BuyStoreItem)
 	r.PUT("/extension/items", extension.SetSelectedItem)
+	r.GET("/extension/rarities", extension.GetRarityTiers)
 
+	r.GET("/dashboard/oauth/login", dashboard.StartLogin)
+	r.GET("/dashboard/oauth/callback", dashboard.FinishLogin)
 	r.GET("/dashboard/login", dashboard.HandleLogin)
+	r.GET("/dashboard/presence", dashboard.GetPresence)
+	r.GET("/dashboard/items", dashboard.GetItems)
 
-	r.POST("/channels/:channelId/users", twitchBot.AddPetToChannel)
-	r.DELETE("/channels/:channelId/users/:userId", twitchBot.RemoveUserFromChannel)
-	r.POST("/channels/:channelId/users/:userId/:action", twitchBot.Action)
-	r.PUT("/channels/:channelId/users/:userId", twitchBot.UpdateUser)
+	// Only the dashboard may change anything with the streamer's session.
+	dashboardWrites := r.Group("/dashboard", controllers.RequireOrigin(dashboardUrl))
+	dashboardWrites.POST("/logout", dashboard.Logout)
+	dashboardWrites.POST("/channel", dashboard.OnboardChannel)
+	dashboardWrites.POST("/overlay/rotate", dashboard.RotateOverlayId)
+	dashboardWrites.POST("/items", dashboard.CreateItem)
+	dashboardWrites.PUT("/items/order", dashboard.ReorderItems)
+	dashboardWrites.PUT("/items/default", dashboard.SetDefaultItem)
+	dashboardWrites.PATCH("/items/:itemId", dashboard.UpdateItem)
+	dashboardWrites.DELETE("/items/:itemId", dashboard.DeleteItem)
+	dashboardWrites.POST("/images", dashboard.UploadImage)
+
+	r.GET("/assets/:key", assets.GetAsset)
+
+	bot := r.Group("/channels/:channelId/users", botAuth)
+	bot.GET("", twitchBot.GetUsersInChannel)
+	bot.POST("", twitchBot.AddPetToChannel)
+	bot.DELETE("/:userId", twitchBot.RemoveUserFromChannel)
+	bot.POST("/:userId/:action", twitchBot.Action)
+	bot.PUT("/:userId", twitchBot.UpdateUser)
+
+	if eventSub != nil {
+		r.POST("/eventsub", eventSub.HandleWebhook)
+	}
 }
@@ -1,17 +1,23 @@
 package services
 
 import (
-	"errors"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
-	"github.com/streampets/backend/models"
+	"github.com/streampets/backend/apperrors"
 	"github.com/streampets/backend/twitch"
 )
 
-var ErrIdMismatch = errors.New("channel id and overlay id do not match")
-var ErrUnexpectedSigningMethod = errors.New("unexpected signing method")
-var ErrInvalidToken = errors.New("token is not valid")
+var ErrIdMismatch = apperrors.New(apperrors.Forbidden, "overlay_id_mismatch", "channel id and overlay id do not match")
+var ErrUnexpectedSigningMethod = apperrors.New(apperrors.Unauthorized, "unexpected_signing_method", "unexpected signing method")
+var ErrInvalidToken = apperrors.New(apperrors.Unauthorized, "invalid_token", "token is not valid")
+var ErrRarityMismatch = apperrors.New(apperrors.Invalid, "rarity_mismatch", "receipt and item rarity do not match")
+var ErrCostMismatch = apperrors.New(apperrors.Invalid, "cost_mismatch", "receipt cost does not match the rarity's price")
+var ErrReceiptMismatch = apperrors.New(apperrors.Forbidden, "receipt_mismatch", "receipt belongs to another user or channel")
 
 type ExtToken struct {
 	ChannelId twitch.Id `json:"channel_id"`
@@ -19,27 +25,40 @@ type ExtToken struct {
 	jwt.RegisteredClaims
 }
 
+type Cost struct {
+	Amount int    `json:"amount"`
+	Type   string `json:"type"`
+}
+
 type Product struct {
-	Rarity models.Rarity `json:"sku"`
+	Sku  string `json:"sku"`
+	Cost Cost   `json:"cost"`
 }
 
 type Data struct {
 	TransactionId uuid.UUID `json:"transactionId"`
+	Time          time.Time `json:"time"`
+	UserId        twitch.Id `json:"userId"`
+	ChannelId     twitch.Id `json:"channelId"`
 	Product       Product   `json:"product"`
 }
 
 type Receipt struct {
 	Data Data `json:"data"`
 	jwt.RegisteredClaims
+	// The claims exactly as Twitch signed them, kept in the transaction ledger.
+	Claims json.RawMessage `json:"-"`
 }
 
 type AuthService struct {
 	channelRepo  OverlayIdGetter
 	clientSecret string
+	now          func() time.Time
 }
 
 type OverlayIdGetter interface {
 	GetOverlayId(channelId twitch.Id) (uuid.UUID, error)
+	GetPreviousOverlayId(channelId twitch.Id) (uuid.UUID, time.Time, error)
 }
 
 func NewAuthService(
@@ -49,6 +68,7 @@ func NewAuthService(
 	return &AuthService{
 		channelRepo:  channelRepo,
 		clientSecret: clientSecret,
+		now:          time.Now,
 	}
 }
 
@@ -58,7 +78,17 @@ func (s *AuthService) VerifyOverlayId(channelId twitch.Id, overlayId uuid.UUID)
 		return err
 	}
 
-	if overlayId != expectedId {
+	if overlayId == expectedId {
+		return nil
+	}
+
+	// The overlay id was rotated and the streamer may not have updated their overlay yet.
+	previousId, expiresAt, err := s.channelRepo.GetPreviousOverlayId(channelId)
+	if err != nil {
+		return err
+	}
+
+	if overlayId != previousId || !s.now().Before(expiresAt) {
 		return ErrIdMismatch
 	}
 
@@ -68,7 +98,7 @@ func (s *AuthService) VerifyOverlayId(channelId twitch.Id, overlayId uuid.UUID)
 func (s *AuthService) VerifyExtToken(tokenString string) (*ExtToken, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &ExtToken{}, s.keyFunc)
 	if err != nil {
-		return nil, err
+		return nil, apperrors.Wrap(err, apperrors.Unauthorized, "invalid_token", "token is not valid")
 	}
 
 	claims, ok := token.Claims.(*ExtToken)
@@ -82,7 +112,7 @@ func (s *AuthService) VerifyExtToken(tokenString string) (*ExtToken, error) {
 func (s *AuthService) VerifyReceipt(tokenString string) (*Receipt, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &Receipt{}, s.keyFunc)
 	if err != nil {
-		return nil, err
+		return nil, apperrors.Wrap(err, apperrors.Unauthorized, "invalid_receipt", "receipt is not valid")
 	}
 
 	claims, ok := token.Claims.(*Receipt)
@@ -90,6 +120,10 @@ func (s *AuthService) VerifyReceipt(tokenString string) (*Receipt, error) {
 		return nil, ErrInvalidToken
 	}
 
+	// The parser has already decoded the claims, so the payload is well formed.
+	payload, _ := base64.RawURLEncoding.DecodeString(strings.Split(token.Raw, ".")[1])
+	claims.Claims = payload
+
 	return claims, nil
 }
 
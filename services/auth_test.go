@@ -1,7 +1,10 @@
 package services
 
 import (
+	"encoding/json"
+	"fmt"
 	"testing"
+	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
@@ -48,6 +51,37 @@ func TestVerifyOverlayId(t *testing.T) {
 	})
 }
 
+func TestVerifyPreviousOverlayId(t *testing.T) {
+	channelId := twitch.Id("channel id")
+	previousId := uuid.New()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := map[string]struct {
+		expiresAt time.Time
+		expected  error
+	}{
+		"previous overlay id accepted during grace period": {now.Add(time.Minute), nil},
+		"previous overlay id refused after grace period":   {now, ErrIdMismatch},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			mock.SetUp(t)
+
+			repoMock := mock.Mock[OverlayIdGetter]()
+			mock.When(repoMock.GetOverlayId(channelId)).ThenReturn(uuid.New(), nil)
+			mock.When(repoMock.GetPreviousOverlayId(channelId)).ThenReturn(previousId, test.expiresAt, nil)
+
+			authService := NewAuthService(repoMock, "")
+			authService.now = func() time.Time { return now }
+
+			err := authService.VerifyOverlayId(channelId, previousId)
+
+			assert.Equal(t, test.expected, err)
+		})
+	}
+}
+
 func TestVerifyExtToken(t *testing.T) {
 	t.Run("valid token is verified correctly", func(t *testing.T) {
 		mock.SetUp(t)
@@ -126,6 +160,32 @@ func TestVerifyReceipt(t *testing.T) {
 		assert.Equal(t, transactionId, got.Data.TransactionId)
 	})
 
+	t.Run("purchase details and raw claims kept", func(t *testing.T) {
+		mock.SetUp(t)
+
+		clientSecret := "secret"
+		purchasedAt := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+		claims := fmt.Sprintf(`{"data":{"channelId":"channel id","product":{"cost":{"amount":100,"type":"bits"},"sku":"common"},"time":"%s","transactionId":"%s","userId":"user id"},"topic":"bits_transaction_receipt"}`,
+			purchasedAt.Format(time.RFC3339), uuid.New())
+
+		var mapClaims jwt.MapClaims
+		assert.NoError(t, json.Unmarshal([]byte(claims), &mapClaims))
+
+		tokenString, err := jwt.NewWithClaims(jwt.SigningMethodHS256, mapClaims).SignedString([]byte(clientSecret))
+		assert.NoError(t, err)
+
+		authService := NewAuthService(mock.Mock[OverlayIdGetter](), clientSecret)
+
+		got, err := authService.VerifyReceipt(tokenString)
+
+		assert.NoError(t, err)
+		assert.Equal(t, twitch.Id("user id"), got.Data.UserId)
+		assert.Equal(t, twitch.Id("channel id"), got.Data.ChannelId)
+		assert.Equal(t, 100, got.Data.Product.Cost.Amount)
+		assert.True(t, purchasedAt.Equal(got.Data.Time))
+		assert.JSONEq(t, claims, string(got.Claims))
+	})
+
 	t.Run("invalid token is not verified", func(t *testing.T) {
 		mock.SetUp(t)
 
@@ -0,0 +1,129 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/streampets/backend/apperrors"
+	"github.com/streampets/backend/models"
+	"github.com/streampets/backend/twitch"
+	"gorm.io/gorm"
+)
+
+var ErrInvalidBotKey = apperrors.New(apperrors.Unauthorized, "invalid_bot_key", "bot api key is not valid")
+var ErrBotNotAllowed = apperrors.New(apperrors.Forbidden, "bot_not_allowed", "bot is not allowed to act for this channel")
+
+type BotRepository interface {
+	GetBotByKeyHash(keyHash string) (models.Bot, error)
+	CanActForChannel(botId uuid.UUID, channelId twitch.Id) (bool, error)
+	CreateBot(bot models.Bot, channelIds []twitch.Id) error
+	GetBots() ([]models.Bot, error)
+	GetBotChannels() ([]models.BotChannel, error)
+	SetBotChannels(botId uuid.UUID, channelIds []twitch.Id) error
+	DeleteBot(botId uuid.UUID) error
+}
+
+// A bot and the channels it may act for.
+type BotSummary struct {
+	Bot        models.Bot
+	ChannelIds []twitch.Id
+}
+
+type BotService struct {
+	botRepo BotRepository
+}
+
+func NewBotService(
+	botRepo BotRepository,
+) *BotService {
+	return &BotService{
+		botRepo: botRepo,
+	}
+}
+
+// Returns the bot the key belongs to if it may act for the channel.
+func (s *BotService) AuthenticateBot(key string, channelId twitch.Id) (models.Bot, error) {
+	bot, err := s.botRepo.GetBotByKeyHash(hashBotKey(key))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return models.Bot{}, ErrInvalidBotKey
+	} else if err != nil {
+		return models.Bot{}, err
+	}
+
+	allowed, err := s.botRepo.CanActForChannel(bot.BotId, channelId)
+	if err != nil {
+		return models.Bot{}, err
+	}
+
+	if !allowed {
+		return models.Bot{}, ErrBotNotAllowed
+	}
+
+	return bot, nil
+}
+
+// Creates a bot scoped to the given channels and returns its API key.
+// The key cannot be recovered later, only its hash is stored.
+func (s *BotService) CreateBot(name string, channelIds []twitch.Id) (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	key := base64.RawURLEncoding.EncodeToString(secret)
+
+	bot := models.Bot{
+		BotId:   uuid.New(),
+		Name:    name,
+		KeyHash: hashBotKey(key),
+	}
+
+	if err := s.botRepo.CreateBot(bot, channelIds); err != nil {
+		return "", err
+	}
+
+	return key, nil
+}
+
+// Returns every bot along with the channels it may act for.
+func (s *BotService) ListBots() ([]BotSummary, error) {
+	bots, err := s.botRepo.GetBots()
+	if err != nil {
+		return nil, err
+	}
+
+	channels, err := s.botRepo.GetBotChannels()
+	if err != nil {
+		return nil, err
+	}
+
+	channelIds := map[uuid.UUID][]twitch.Id{}
+	for _, channel := range channels {
+		channelIds[channel.BotId] = append(channelIds[channel.BotId], channel.ChannelId)
+	}
+
+	summaries := make([]BotSummary, 0, len(bots))
+	for _, bot := range bots {
+		summaries = append(summaries, BotSummary{Bot: bot, ChannelIds: channelIds[bot.BotId]})
+	}
+	return summaries, nil
+}
+
+// Replaces the channels the bot may act for. Its API key stays the same.
+func (s *BotService) SetBotChannels(botId uuid.UUID, channelIds []twitch.Id) error {
+	return s.botRepo.SetBotChannels(botId, channelIds)
+}
+
+// Removes the bot, after which its API key is no longer accepted.
+func (s *BotService) RevokeBot(botId uuid.UUID) error {
+	return s.botRepo.DeleteBot(botId)
+}
+
+// Keys are random, so a fast hash is enough and lets bots be looked up by it.
+func hashBotKey(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(hash[:])
+}
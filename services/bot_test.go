@@ -0,0 +1,151 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/ovechkin-dm/mockio/mock"
+	"github.com/streampets/backend/apperrors"
+	"github.com/streampets/backend/models"
+	"github.com/streampets/backend/twitch"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestAuthenticateBot(t *testing.T) {
+	key := "key"
+	channelId := twitch.Id("channel id")
+	bot := models.Bot{BotId: uuid.New(), Name: "bot", KeyHash: hashBotKey(key)}
+
+	t.Run("bot returned when allowed to act for channel", func(t *testing.T) {
+		mock.SetUp(t)
+
+		repoMock := mock.Mock[BotRepository]()
+		mock.When(repoMock.GetBotByKeyHash(hashBotKey(key))).ThenReturn(bot, nil)
+		mock.When(repoMock.CanActForChannel(bot.BotId, channelId)).ThenReturn(true, nil)
+
+		got, err := NewBotService(repoMock).AuthenticateBot(key, channelId)
+
+		assert.NoError(t, err)
+		assert.Equal(t, bot, got)
+	})
+
+	t.Run("unknown key is invalid", func(t *testing.T) {
+		mock.SetUp(t)
+
+		repoMock := mock.Mock[BotRepository]()
+		mock.When(repoMock.GetBotByKeyHash(mock.AnyString())).ThenReturn(models.Bot{}, gorm.ErrRecordNotFound)
+
+		_, err := NewBotService(repoMock).AuthenticateBot("wrong key", channelId)
+
+		assert.Equal(t, ErrInvalidBotKey, err)
+	})
+
+	t.Run("bot not allowed to act for other channels", func(t *testing.T) {
+		mock.SetUp(t)
+
+		repoMock := mock.Mock[BotRepository]()
+		mock.When(repoMock.GetBotByKeyHash(hashBotKey(key))).ThenReturn(bot, nil)
+		mock.When(repoMock.CanActForChannel(bot.BotId, channelId)).ThenReturn(false, nil)
+
+		_, err := NewBotService(repoMock).AuthenticateBot(key, channelId)
+
+		assert.Equal(t, ErrBotNotAllowed, err)
+	})
+}
+
+func TestCreateBot(t *testing.T) {
+	mock.SetUp(t)
+
+	channelIds := []twitch.Id{"channel id"}
+
+	repoMock := mock.Mock[BotRepository]()
+	captor := mock.Captor[models.Bot]()
+	mock.When(repoMock.CreateBot(captor.Capture(), mock.Equal(channelIds))).ThenReturn(nil)
+
+	key, err := NewBotService(repoMock).CreateBot("bot", channelIds)
+
+	assert.NoError(t, err)
+	assert.NotEmpty(t, key)
+	assert.Equal(t, "bot", captor.Last().Name)
+	assert.Equal(t, hashBotKey(key), captor.Last().KeyHash)
+}
+
+func TestListBots(t *testing.T) {
+	botOne := models.Bot{BotId: uuid.New(), Name: "bot one"}
+	botTwo := models.Bot{BotId: uuid.New(), Name: "bot two"}
+
+	t.Run("bots returned with their channels", func(t *testing.T) {
+		mock.SetUp(t)
+
+		repoMock := mock.Mock[BotRepository]()
+		mock.When(repoMock.GetBots()).ThenReturn([]models.Bot{botOne, botTwo}, nil)
+		mock.When(repoMock.GetBotChannels()).ThenReturn([]models.BotChannel{
+			{BotId: botOne.BotId, ChannelId: "channel one"},
+			{BotId: botOne.BotId, ChannelId: "channel two"},
+		}, nil)
+
+		got, err := NewBotService(repoMock).ListBots()
+
+		assert.NoError(t, err)
+		assert.Equal(t, []BotSummary{
+			{Bot: botOne, ChannelIds: []twitch.Id{"channel one", "channel two"}},
+			{Bot: botTwo},
+		}, got)
+	})
+
+	t.Run("error returned when channels cannot be read", func(t *testing.T) {
+		mock.SetUp(t)
+
+		repoMock := mock.Mock[BotRepository]()
+		mock.When(repoMock.GetBots()).ThenReturn([]models.Bot{botOne}, nil)
+		mock.When(repoMock.GetBotChannels()).ThenReturn(nil, assert.AnError)
+
+		_, err := NewBotService(repoMock).ListBots()
+
+		assert.Equal(t, assert.AnError, err)
+	})
+}
+
+func TestSetBotChannels(t *testing.T) {
+	mock.SetUp(t)
+
+	botId := uuid.New()
+	channelIds := []twitch.Id{"channel id"}
+
+	repoMock := mock.Mock[BotRepository]()
+	mock.When(repoMock.SetBotChannels(botId, channelIds)).ThenReturn(nil)
+
+	err := NewBotService(repoMock).SetBotChannels(botId, channelIds)
+
+	assert.NoError(t, err)
+	mock.Verify(repoMock, mock.Once()).SetBotChannels(botId, channelIds)
+}
+
+func TestRevokeBot(t *testing.T) {
+	botId := uuid.New()
+
+	t.Run("bot deleted", func(t *testing.T) {
+		mock.SetUp(t)
+
+		repoMock := mock.Mock[BotRepository]()
+		mock.When(repoMock.DeleteBot(botId)).ThenReturn(nil)
+
+		err := NewBotService(repoMock).RevokeBot(botId)
+
+		assert.NoError(t, err)
+		mock.Verify(repoMock, mock.Once()).DeleteBot(botId)
+	})
+
+	t.Run("unknown bot not found", func(t *testing.T) {
+		mock.SetUp(t)
+
+		notFound := apperrors.New(apperrors.NotFound, "bot_not_found", "bot not found")
+		repoMock := mock.Mock[BotRepository]()
+		mock.When(repoMock.DeleteBot(botId)).ThenReturn(notFound)
+
+		err := NewBotService(repoMock).RevokeBot(botId)
+
+		assert.Equal(t, apperrors.NotFound, apperrors.KindOf(err))
+	})
+}
@@ -0,0 +1,114 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/streampets/backend/apperrors"
+	"github.com/streampets/backend/models"
+	"github.com/streampets/backend/twitch"
+	"gorm.io/gorm"
+)
+
+var ErrTwitchUserNotFound = apperrors.New(apperrors.NotFound, "twitch_user_not_found", "twitch user not found")
+
+// The items every new channel starts with, the first of which is the default.
+// Each channel gets its own copies so streamers can change them.
+var starterItems = []models.Item{
+	{Name: "yellow", Rarity: models.Common, Image: "yellow", PrevImg: "yellow"},
+	{Name: "blue", Rarity: models.Common, Image: "blue", PrevImg: "blue"},
+	{Name: "green", Rarity: models.Common, Image: "green", PrevImg: "green"},
+	{Name: "pink", Rarity: models.Uncommon, Image: "pink", PrevImg: "pink"},
+}
+
+type ChannelRepository interface {
+	GetChannel(channelId twitch.Id) (models.Channel, error)
+	CreateChannel(channel models.Channel, items []models.Item, defaultItemId uuid.UUID) error
+	RotateOverlayId(channelId twitch.Id, overlayId uuid.UUID, previousExpiresAt time.Time) (before models.Channel, err error)
+}
+
+type UserGetter interface {
+	GetUsers(ctx context.Context, userIds []twitch.Id, logins []string) ([]twitch.User, error)
+}
+
+type ChannelService struct {
+	channelRepo ChannelRepository
+	users       UserGetter
+	now         func() time.Time
+}
+
+func NewChannelService(
+	channelRepo ChannelRepository,
+	users UserGetter,
+) *ChannelService {
+	return &ChannelService{
+		channelRepo: channelRepo,
+		users:       users,
+		now:         time.Now,
+	}
+}
+
+// Sets up the streamer's channel with a new overlay id and the starter items.
+// Returns the channel and true if it was created, or the existing channel and
+// false if the streamer had already been onboarded.
+func (s *ChannelService) OnboardChannel(ctx context.Context, channelId twitch.Id) (models.Channel, bool, error) {
+	channel, err := s.channelRepo.GetChannel(channelId)
+	if err == nil {
+		return channel, false, nil
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return models.Channel{}, false, err
+	}
+
+	users, err := s.users.GetUsers(ctx, []twitch.Id{channelId}, nil)
+	if err != nil {
+		return models.Channel{}, false, err
+	}
+	if len(users) == 0 {
+		return models.Channel{}, false, ErrTwitchUserNotFound
+	}
+
+	channel = models.Channel{
+		ChannelId:   channelId,
+		ChannelName: users[0].Login,
+		OverlayId:   uuid.New(),
+	}
+
+	items := make([]models.Item, len(starterItems))
+	for i, item := range starterItems {
+		item.ItemId = uuid.New()
+		items[i] = item
+	}
+
+	if err := s.channelRepo.CreateChannel(channel, items, items[0].ItemId); err != nil {
+		return models.Channel{}, false, err
+	}
+
+	return channel, true, nil
+}
+
+// Gives the channel a new overlay id, for when the old one leaked. The old one
+// keeps working for gracePeriod so the streamer can update their overlay.
+// Returns the new overlay id and the ones that stopped working straight away:
+// the old one when there is no grace period, and the one before it if its own
+// grace period was not over yet.
+func (s *ChannelService) RotateOverlayId(channelId twitch.Id, gracePeriod time.Duration) (uuid.UUID, []uuid.UUID, error) {
+	overlayId := uuid.New()
+	now := s.now()
+
+	before, err := s.channelRepo.RotateOverlayId(channelId, overlayId, now.Add(gracePeriod))
+	if err != nil {
+		return uuid.UUID{}, nil, err
+	}
+
+	dropped := []uuid.UUID{}
+	if before.PreviousOverlayId != (uuid.UUID{}) && before.PreviousOverlayIdExpiresAt.After(now) {
+		dropped = append(dropped, before.PreviousOverlayId)
+	}
+	if gracePeriod == 0 {
+		dropped = append(dropped, before.OverlayId)
+	}
+
+	return overlayId, dropped, nil
+}
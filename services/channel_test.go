@@ -0,0 +1,136 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ovechkin-dm/mockio/mock"
+	"github.com/streampets/backend/models"
+	"github.com/streampets/backend/twitch"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestOnboardChannel(t *testing.T) {
+	ctx := context.Background()
+	channelId := twitch.Id("channel id")
+
+	t.Run("channel created with starter items", func(t *testing.T) {
+		mock.SetUp(t)
+
+		repoMock := mock.Mock[ChannelRepository]()
+		usersMock := mock.Mock[UserGetter]()
+
+		mock.When(repoMock.GetChannel(channelId)).ThenReturn(models.Channel{}, gorm.ErrRecordNotFound)
+		mock.When(usersMock.GetUsers(ctx, []twitch.Id{channelId}, nil)).ThenReturn([]twitch.User{{Id: channelId, Login: "channel"}}, nil)
+		mock.When(repoMock.CreateChannel(mock.Any[models.Channel](), mock.Any[[]models.Item](), mock.Any[uuid.UUID]())).ThenReturn(nil)
+
+		channel, created, err := NewChannelService(repoMock, usersMock).OnboardChannel(ctx, channelId)
+
+		assert.NoError(t, err)
+		assert.True(t, created)
+		assert.Equal(t, channelId, channel.ChannelId)
+		assert.Equal(t, "channel", channel.ChannelName)
+		assert.NotEqual(t, uuid.UUID{}, channel.OverlayId)
+
+		items := mock.Captor[[]models.Item]()
+		defaultItemId := mock.Captor[uuid.UUID]()
+		mock.Verify(repoMock, mock.Once()).CreateChannel(mock.Equal(channel), items.Capture(), defaultItemId.Capture())
+
+		assert.Len(t, items.Last(), len(starterItems))
+		assert.Equal(t, items.Last()[0].ItemId, defaultItemId.Last())
+		for i, item := range items.Last() {
+			assert.NotEqual(t, uuid.UUID{}, item.ItemId)
+			assert.Equal(t, starterItems[i].Name, item.Name)
+		}
+	})
+
+	t.Run("existing channel returned when already onboarded", func(t *testing.T) {
+		mock.SetUp(t)
+
+		existing := models.Channel{ChannelId: channelId, OverlayId: uuid.New()}
+
+		repoMock := mock.Mock[ChannelRepository]()
+		usersMock := mock.Mock[UserGetter]()
+
+		mock.When(repoMock.GetChannel(channelId)).ThenReturn(existing, nil)
+
+		channel, created, err := NewChannelService(repoMock, usersMock).OnboardChannel(ctx, channelId)
+
+		assert.NoError(t, err)
+		assert.False(t, created)
+		assert.Equal(t, existing, channel)
+		mock.Verify(repoMock, mock.Never()).CreateChannel(mock.Any[models.Channel](), mock.Any[[]models.Item](), mock.Any[uuid.UUID]())
+	})
+
+	t.Run("channel not created when twitch user not found", func(t *testing.T) {
+		mock.SetUp(t)
+
+		repoMock := mock.Mock[ChannelRepository]()
+		usersMock := mock.Mock[UserGetter]()
+
+		mock.When(repoMock.GetChannel(channelId)).ThenReturn(models.Channel{}, gorm.ErrRecordNotFound)
+		mock.When(usersMock.GetUsers(ctx, []twitch.Id{channelId}, nil)).ThenReturn([]twitch.User{}, nil)
+
+		_, _, err := NewChannelService(repoMock, usersMock).OnboardChannel(ctx, channelId)
+
+		assert.Equal(t, ErrTwitchUserNotFound, err)
+		mock.Verify(repoMock, mock.Never()).CreateChannel(mock.Any[models.Channel](), mock.Any[[]models.Item](), mock.Any[uuid.UUID]())
+	})
+}
+
+func TestRotateOverlayId(t *testing.T) {
+	channelId := twitch.Id("channel id")
+	currentId := uuid.New()
+	previousId := uuid.New()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := map[string]struct {
+		before      models.Channel
+		gracePeriod time.Duration
+		dropped     []uuid.UUID
+	}{
+		"nothing dropped during grace period": {
+			models.Channel{ChannelId: channelId, OverlayId: currentId},
+			time.Hour,
+			[]uuid.UUID{},
+		},
+		"current overlay id dropped without grace period": {
+			models.Channel{ChannelId: channelId, OverlayId: currentId},
+			0,
+			[]uuid.UUID{currentId},
+		},
+		"previous overlay id still in its grace period dropped": {
+			models.Channel{ChannelId: channelId, OverlayId: currentId, PreviousOverlayId: previousId, PreviousOverlayIdExpiresAt: now.Add(time.Minute)},
+			time.Hour,
+			[]uuid.UUID{previousId},
+		},
+		"expired previous overlay id not dropped again": {
+			models.Channel{ChannelId: channelId, OverlayId: currentId, PreviousOverlayId: previousId, PreviousOverlayIdExpiresAt: now.Add(-time.Minute)},
+			0,
+			[]uuid.UUID{currentId},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			mock.SetUp(t)
+
+			repoMock := mock.Mock[ChannelRepository]()
+			mock.When(repoMock.RotateOverlayId(mock.Equal(channelId), mock.Any[uuid.UUID](), mock.Equal(now.Add(test.gracePeriod)))).ThenReturn(test.before, nil)
+
+			service := NewChannelService(repoMock, mock.Mock[UserGetter]())
+			service.now = func() time.Time { return now }
+
+			overlayId, dropped, err := service.RotateOverlayId(channelId, test.gracePeriod)
+
+			assert.NoError(t, err)
+			assert.Equal(t, test.dropped, dropped)
+			assert.NotEqual(t, uuid.UUID{}, overlayId)
+			assert.NotEqual(t, currentId, overlayId)
+			mock.Verify(repoMock, mock.Once()).RotateOverlayId(channelId, overlayId, now.Add(test.gracePeriod))
+		})
+	}
+}
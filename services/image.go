@@ -0,0 +1,161 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"image"
+	"image/color"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"net/http"
+	"strings"
+
+	"github.com/streampets/backend/apperrors"
+)
+
+// The largest image that can be uploaded, in bytes.
+const MaxImageSize int = 1 << 20
+
+const minImageDimension int = 16
+const maxImageDimension int = 1024
+
+// Previews are scaled to fit in a square this many pixels wide.
+const previewSize int = 64
+
+var ErrImageTooLarge = apperrors.New(apperrors.Invalid, "image_too_large", "images must be at most 1MiB")
+var ErrUnsupportedImage = apperrors.New(apperrors.Invalid, "unsupported_image", "images must be PNG, GIF or JPEG")
+var ErrInvalidImageDimensions = apperrors.New(apperrors.Invalid, "invalid_image_dimensions", "images must be between 16 and 1024 pixels wide and high")
+
+var imageExtensions = map[string]string{
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/jpeg": ".jpg",
+}
+
+type AssetStore interface {
+	Put(ctx context.Context, key string, data []byte) error
+}
+
+// The urls of an uploaded image and its preview, named like models.Item's
+// Image and PrevImg.
+type UploadedImage struct {
+	Image   string `json:"img"`
+	PrevImg string `json:"prev"`
+}
+
+type ImageService struct {
+	store    AssetStore
+	assetUrl string
+}
+
+// assetUrl is where the stored assets are served, the key of each asset is
+// added to it to get the asset's url.
+func NewImageService(store AssetStore, assetUrl string) *ImageService {
+	return &ImageService{
+		store:    store,
+		assetUrl: strings.TrimSuffix(assetUrl, "/"),
+	}
+}
+
+// Checks the image, makes a preview of it and stores both. Assets are named
+// after the hash of their content, so uploading the same image twice stores
+// it once.
+func (s *ImageService) UploadImage(ctx context.Context, data []byte) (UploadedImage, error) {
+	if len(data) > MaxImageSize {
+		return UploadedImage{}, ErrImageTooLarge
+	}
+
+	extension, ok := imageExtensions[http.DetectContentType(data)]
+	if !ok {
+		return UploadedImage{}, ErrUnsupportedImage
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return UploadedImage{}, apperrors.Wrap(err, apperrors.Invalid, "unsupported_image", "images must be PNG, GIF or JPEG")
+	}
+	if !validDimension(config.Width) || !validDimension(config.Height) {
+		return UploadedImage{}, ErrInvalidImageDimensions
+	}
+
+	// Animated GIFs are decoded to their first frame, which is all the preview shows.
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return UploadedImage{}, apperrors.Wrap(err, apperrors.Invalid, "unsupported_image", "images must be PNG, GIF or JPEG")
+	}
+
+	var preview bytes.Buffer
+	if err := png.Encode(&preview, scaleToFit(img, previewSize)); err != nil {
+		return UploadedImage{}, err
+	}
+
+	imageKey := assetKey(data, extension)
+	if err := s.store.Put(ctx, imageKey, data); err != nil {
+		return UploadedImage{}, err
+	}
+
+	previewKey := assetKey(preview.Bytes(), ".png")
+	if err := s.store.Put(ctx, previewKey, preview.Bytes()); err != nil {
+		return UploadedImage{}, err
+	}
+
+	return UploadedImage{
+		Image:   s.assetUrl + "/" + imageKey,
+		PrevImg: s.assetUrl + "/" + previewKey,
+	}, nil
+}
+
+func validDimension(pixels int) bool {
+	return pixels >= minImageDimension && pixels <= maxImageDimension
+}
+
+func assetKey(data []byte, extension string) string {
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:]) + extension
+}
+
+// Shrinks the image to fit in a size by size square, keeping its aspect
+// ratio. Each pixel is the average of the pixels it covers. Images that
+// already fit are only copied.
+func scaleToFit(src image.Image, size int) image.Image {
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	scaledWidth, scaledHeight := width, height
+	if width > size || height > size {
+		if width >= height {
+			scaledWidth, scaledHeight = size, max(1, height*size/width)
+		} else {
+			scaledWidth, scaledHeight = max(1, width*size/height), size
+		}
+	}
+
+	dst := image.NewRGBA64(image.Rect(0, 0, scaledWidth, scaledHeight))
+	for y := 0; y < scaledHeight; y++ {
+		y0, y1 := bounds.Min.Y+y*height/scaledHeight, bounds.Min.Y+(y+1)*height/scaledHeight
+		for x := 0; x < scaledWidth; x++ {
+			x0, x1 := bounds.Min.X+x*width/scaledWidth, bounds.Min.X+(x+1)*width/scaledWidth
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := src.At(sx, sy).RGBA()
+					r, g, b, a = r+uint64(pr), g+uint64(pg), b+uint64(pb), a+uint64(pa)
+					n++
+				}
+			}
+
+			dst.SetRGBA64(x, y, color.RGBA64{
+				R: uint16(r / n),
+				G: uint16(g / n),
+				B: uint16(b / n),
+				A: uint16(a / n),
+			})
+		}
+	}
+
+	return dst
+}
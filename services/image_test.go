@@ -0,0 +1,112 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func encodePng(width, height int) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 255, A: 255})
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		panic(err)
+	}
+	return buf.Bytes()
+}
+
+// Records what is stored. A mock is not used because mocks cannot tell which
+// test they belong to after decoding an image has grown the goroutine's stack.
+type fakeAssetStore struct {
+	keys   []string
+	assets map[string][]byte
+}
+
+func (f *fakeAssetStore) Put(ctx context.Context, key string, data []byte) error {
+	if f.assets == nil {
+		f.assets = map[string][]byte{}
+	}
+	f.keys = append(f.keys, key)
+	f.assets[key] = data
+	return nil
+}
+
+func TestUploadImage(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("image and preview stored under their hashes", func(t *testing.T) {
+		data := encodePng(128, 64)
+		store := &fakeAssetStore{}
+
+		uploaded, err := NewImageService(store, "https://api/assets/").UploadImage(ctx, data)
+		assert.NoError(t, err)
+
+		if !assert.Len(t, store.keys, 2) {
+			return
+		}
+		imageKey, previewKey := store.keys[0], store.keys[1]
+
+		assert.Equal(t, assetKey(data, ".png"), imageKey)
+		assert.Equal(t, data, store.assets[imageKey])
+		assert.Equal(t, "https://api/assets/"+imageKey, uploaded.Image)
+
+		preview := store.assets[previewKey]
+		assert.Equal(t, assetKey(preview, ".png"), previewKey)
+		assert.Equal(t, "https://api/assets/"+previewKey, uploaded.PrevImg)
+
+		config, err := png.DecodeConfig(bytes.NewReader(preview))
+		assert.NoError(t, err)
+		assert.Equal(t, 64, config.Width)
+		assert.Equal(t, 32, config.Height)
+	})
+
+	t.Run("gif accepted", func(t *testing.T) {
+		palette := color.Palette{color.Black, color.White}
+		var data bytes.Buffer
+		if err := gif.EncodeAll(&data, &gif.GIF{
+			Image: []*image.Paletted{image.NewPaletted(image.Rect(0, 0, 32, 32), palette), image.NewPaletted(image.Rect(0, 0, 32, 32), palette)},
+			Delay: []int{10, 10},
+		}); err != nil {
+			panic(err)
+		}
+
+		uploaded, err := NewImageService(&fakeAssetStore{}, "/assets").UploadImage(ctx, data.Bytes())
+
+		assert.NoError(t, err)
+		assert.True(t, strings.HasSuffix(uploaded.Image, ".gif"))
+	})
+
+	tests := map[string]struct {
+		data     []byte
+		expected error
+	}{
+		"too large error when image over the limit":     {append(encodePng(16, 16), make([]byte, MaxImageSize)...), ErrImageTooLarge},
+		"unsupported error when not an image":           {[]byte("not an image"), ErrUnsupportedImage},
+		"invalid dimensions error when image too small": {encodePng(8, 32), ErrInvalidImageDimensions},
+		"invalid dimensions error when image too large": {encodePng(1025, 32), ErrInvalidImageDimensions},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			store := &fakeAssetStore{}
+
+			_, err := NewImageService(store, "/assets").UploadImage(ctx, test.data)
+
+			assert.Equal(t, test.expected, err)
+			assert.Empty(t, store.keys)
+		})
+	}
+}
@@ -2,14 +2,27 @@ package services
 
 import (
 	"errors"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/google/uuid"
+	"github.com/streampets/backend/apperrors"
 	"github.com/streampets/backend/models"
 	"github.com/streampets/backend/twitch"
 	"gorm.io/gorm"
 )
 
-var ErrSelectUnownedItem = errors.New("user tried to select an item they do not own")
+var ErrSelectUnownedItem = apperrors.New(apperrors.Forbidden, "select_unowned_item", "user tried to select an item they do not own")
+var ErrInvalidItemName = apperrors.New(apperrors.Invalid, "invalid_item_name", "item names must be between 1 and 32 characters")
+var ErrInvalidRarity = apperrors.New(apperrors.Invalid, "invalid_rarity", "unknown rarity")
+var ErrNoItemImage = apperrors.New(apperrors.Invalid, "no_item_image", "items need an image")
+var ErrInvalidItemOrder = apperrors.New(apperrors.Invalid, "invalid_item_order", "item order must list each of the channel's items once")
+var ErrItemNameTaken = apperrors.New(apperrors.Conflict, "item_name_taken", "the channel already has an item with this name")
+var ErrDefaultItemRemoved = apperrors.New(apperrors.Conflict, "default_item_removed", "the default item cannot be retired or deleted")
+var ErrRetiredDefaultItem = apperrors.New(apperrors.Conflict, "retired_default_item", "a retired item cannot be the default item")
+
+// Item names are typed in chat to select them, so they are kept short.
+const maxItemNameLength int = 32
 
 type ItemRepository interface {
 	GetItemByName(channelId twitch.Id, itemName string) (models.Item, error)
@@ -21,22 +34,48 @@ type ItemRepository interface {
 
 	GetChannelsItems(channelId twitch.Id) ([]models.Item, error)
 
+	GetCatalog(channelId twitch.Id) ([]models.CatalogItem, error)
+	GetCatalogItem(channelId twitch.Id, itemId uuid.UUID) (models.CatalogItem, error)
+	CreateItem(channelId twitch.Id, item models.Item) error
+	UpdateItem(item models.Item) error
+	SetItemRetired(channelId twitch.Id, itemId uuid.UUID, retired bool) error
+	RemoveItem(channelId twitch.Id, itemId uuid.UUID) (retired bool, err error)
+	SetItemPositions(channelId twitch.Id, itemIds []uuid.UUID) error
+
 	GetOwnedItems(channelId, userId twitch.Id) ([]models.Item, error)
-	AddOwnedItem(userId twitch.Id, itemId, transactionId uuid.UUID) error
+	AddTransaction(transaction models.Transaction, selectItem bool) (models.Transaction, error)
+	GetTransaction(transactionId uuid.UUID) (models.Transaction, error)
 	CheckOwnedItem(userId twitch.Id, itemId uuid.UUID) (bool, error)
 
 	GetDefaultItem(channelId twitch.Id) (models.Item, error)
+	SetDefaultItem(channelId twitch.Id, itemId uuid.UUID) error
+}
+
+// Changes to an item in a channel's catalog. Nil fields are left unchanged.
+type ItemUpdate struct {
+	Name    *string
+	Rarity  *models.Rarity
+	Image   *string
+	PrevImg *string
+	Retired *bool
+}
+
+type RarityGetter interface {
+	GetRarityTier(channelId twitch.Id, name models.Rarity) (models.RarityTier, error)
 }
 
 type ItemService struct {
 	itemRepo ItemRepository
+	rarities RarityGetter
 }
 
 func NewItemService(
 	itemRepo ItemRepository,
+	rarities RarityGetter,
 ) *ItemService {
 	return &ItemService{
 		itemRepo: itemRepo,
+		rarities: rarities,
 	}
 }
 
@@ -50,7 +89,7 @@ func (s *ItemService) GetItemById(itemId uuid.UUID) (models.Item, error) {
 
 func (s *ItemService) GetSelectedItem(userId, channelId twitch.Id) (models.Item, error) {
 	item, err := s.itemRepo.GetSelectedItem(userId, channelId)
-	if err == gorm.ErrRecordNotFound {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
 		return s.itemRepo.GetDefaultItem(channelId)
 	}
 	if err != nil {
@@ -64,7 +103,7 @@ func (s *ItemService) SetSelectedItem(userId, channelId twitch.Id, itemId uuid.U
 	if owned, err := s.itemRepo.CheckOwnedItem(userId, itemId); err != nil {
 		return err
 	} else if owned {
-		return s.itemRepo.SetSelectedItem(channelId, userId, itemId)
+		return s.itemRepo.SetSelectedItem(userId, channelId, itemId)
 	}
 
 	if defaultItem, err := s.itemRepo.GetDefaultItem(channelId); err != nil {
@@ -105,6 +144,202 @@ func (s *ItemService) GetOwnedItems(channelId, userId twitch.Id) ([]models.Item,
 	return result, nil
 }
 
-func (s *ItemService) AddOwnedItem(userId twitch.Id, itemId, transactionId uuid.UUID) error {
-	return s.itemRepo.AddOwnedItem(userId, itemId, transactionId)
+// Records the purchase and returns the item it bought, selecting it for the
+// user if selectItem is set. The item must be on sale in the transaction's
+// channel. A replayed receipt returns the item bought when the transaction
+// was first recorded.
+func (s *ItemService) BuyItem(transaction models.Transaction, selectItem bool) (models.Item, error) {
+	recorded, err := s.itemRepo.AddTransaction(transaction, selectItem)
+	if err != nil {
+		return models.Item{}, err
+	}
+
+	return s.itemRepo.GetItemById(recorded.ItemId)
+}
+
+// Returns the item bought in the transaction, and false if the transaction was
+// never recorded. Replayed receipts are answered with it without checking the
+// purchase again, as the item's rarity or its price may have changed since.
+func (s *ItemService) GetBoughtItem(transactionId uuid.UUID) (models.Item, bool, error) {
+	transaction, err := s.itemRepo.GetTransaction(transactionId)
+	if apperrors.KindOf(err) == apperrors.NotFound {
+		return models.Item{}, false, nil
+	}
+	if err != nil {
+		return models.Item{}, false, err
+	}
+
+	item, err := s.itemRepo.GetItemById(transaction.ItemId)
+	if err != nil {
+		return models.Item{}, false, err
+	}
+	return item, true, nil
+}
+
+// Returns all of the channel's items, retired ones included, in store order.
+func (s *ItemService) GetCatalog(channelId twitch.Id) ([]models.CatalogItem, error) {
+	return s.itemRepo.GetCatalog(channelId)
+}
+
+// Adds a new item to the end of the channel's store. Items without a preview
+// image use their image as the preview.
+func (s *ItemService) CreateItem(channelId twitch.Id, item models.Item) (models.CatalogItem, error) {
+	item.ItemId = uuid.New()
+	item.Name = strings.TrimSpace(item.Name)
+	if item.PrevImg == "" {
+		item.PrevImg = item.Image
+	}
+
+	if err := s.validateItem(channelId, item); err != nil {
+		return models.CatalogItem{}, err
+	}
+	if err := s.checkNameFree(channelId, item); err != nil {
+		return models.CatalogItem{}, err
+	}
+
+	if err := s.itemRepo.CreateItem(channelId, item); err != nil {
+		return models.CatalogItem{}, err
+	}
+
+	return s.itemRepo.GetCatalogItem(channelId, item.ItemId)
+}
+
+// Renames, re-images, retires or brings back one of the channel's items.
+func (s *ItemService) UpdateItem(channelId twitch.Id, itemId uuid.UUID, update ItemUpdate) (models.CatalogItem, error) {
+	current, err := s.itemRepo.GetCatalogItem(channelId, itemId)
+	if err != nil {
+		return models.CatalogItem{}, err
+	}
+
+	item := current.Item
+	if update.Name != nil {
+		item.Name = strings.TrimSpace(*update.Name)
+	}
+	if update.Rarity != nil {
+		item.Rarity = *update.Rarity
+	}
+	if update.Image != nil {
+		item.Image = *update.Image
+	}
+	if update.PrevImg != nil {
+		item.PrevImg = *update.PrevImg
+	}
+
+	if err := s.validateItem(channelId, item); err != nil {
+		return models.CatalogItem{}, err
+	}
+	if item.Name != current.Name {
+		if err := s.checkNameFree(channelId, item); err != nil {
+			return models.CatalogItem{}, err
+		}
+	}
+
+	retiring := update.Retired != nil && *update.Retired && !current.Retired
+	if retiring {
+		if err := s.checkNotDefault(channelId, itemId); err != nil {
+			return models.CatalogItem{}, err
+		}
+	}
+
+	if item != current.Item {
+		if err := s.itemRepo.UpdateItem(item); err != nil {
+			return models.CatalogItem{}, err
+		}
+	}
+
+	if update.Retired != nil && *update.Retired != current.Retired {
+		if err := s.itemRepo.SetItemRetired(channelId, itemId, *update.Retired); err != nil {
+			return models.CatalogItem{}, err
+		}
+	}
+
+	return s.itemRepo.GetCatalogItem(channelId, itemId)
+}
+
+// Deletes one of the channel's items. Items that viewers own or have selected
+// are retired instead, so they keep them. Returns whether it was retired.
+func (s *ItemService) RemoveItem(channelId twitch.Id, itemId uuid.UUID) (bool, error) {
+	if err := s.checkNotDefault(channelId, itemId); err != nil {
+		return false, err
+	}
+
+	return s.itemRepo.RemoveItem(channelId, itemId)
+}
+
+// Puts the channel's items in the store in the order given, which has to
+// include every one of them.
+func (s *ItemService) ReorderItems(channelId twitch.Id, itemIds []uuid.UUID) error {
+	catalog, err := s.itemRepo.GetCatalog(channelId)
+	if err != nil {
+		return err
+	}
+
+	if len(itemIds) != len(catalog) {
+		return ErrInvalidItemOrder
+	}
+
+	unordered := map[uuid.UUID]bool{}
+	for _, item := range catalog {
+		unordered[item.ItemId] = true
+	}
+	for _, itemId := range itemIds {
+		if !unordered[itemId] {
+			return ErrInvalidItemOrder
+		}
+		delete(unordered, itemId)
+	}
+
+	return s.itemRepo.SetItemPositions(channelId, itemIds)
+}
+
+// Makes one of the channel's items the one every viewer's pet gets for free.
+func (s *ItemService) SetDefaultItem(channelId twitch.Id, itemId uuid.UUID) error {
+	item, err := s.itemRepo.GetCatalogItem(channelId, itemId)
+	if err != nil {
+		return err
+	}
+	if item.Retired {
+		return ErrRetiredDefaultItem
+	}
+
+	return s.itemRepo.SetDefaultItem(channelId, itemId)
+}
+
+func (s *ItemService) checkNameFree(channelId twitch.Id, item models.Item) error {
+	existing, err := s.itemRepo.GetItemByName(channelId, item.Name)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if existing.ItemId != item.ItemId {
+		return ErrItemNameTaken
+	}
+	return nil
+}
+
+func (s *ItemService) checkNotDefault(channelId twitch.Id, itemId uuid.UUID) error {
+	defaultItem, err := s.itemRepo.GetDefaultItem(channelId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if defaultItem.ItemId == itemId {
+		return ErrDefaultItemRemoved
+	}
+	return nil
+}
+
+func (s *ItemService) validateItem(channelId twitch.Id, item models.Item) error {
+	if item.Name == "" || utf8.RuneCountInString(item.Name) > maxItemNameLength {
+		return ErrInvalidItemName
+	}
+	if item.Image == "" || item.PrevImg == "" {
+		return ErrNoItemImage
+	}
+	_, err := s.rarities.GetRarityTier(channelId, item.Rarity)
+	return err
 }
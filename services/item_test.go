@@ -5,9 +5,11 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/ovechkin-dm/mockio/mock"
+	"github.com/streampets/backend/apperrors"
 	"github.com/streampets/backend/models"
 	"github.com/streampets/backend/twitch"
 	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
 )
 
 func TestGetItemByName(t *testing.T) {
@@ -21,7 +23,7 @@ func TestGetItemByName(t *testing.T) {
 	itemMock := mock.Mock[ItemRepository]()
 	mock.When(itemMock.GetItemByName(channelId, itemName)).ThenReturn(item, nil)
 
-	database := NewItemService(itemMock)
+	database := NewItemService(itemMock, mock.Mock[RarityGetter]())
 
 	got, err := database.GetItemByName(channelId, itemName)
 
@@ -40,7 +42,7 @@ func TestGetItemById(t *testing.T) {
 	itemMock := mock.Mock[ItemRepository]()
 	mock.When(itemMock.GetItemById(itemId)).ThenReturn(item, nil)
 
-	database := NewItemService(itemMock)
+	database := NewItemService(itemMock, mock.Mock[RarityGetter]())
 
 	got, err := database.GetItemById(itemId)
 
@@ -60,7 +62,7 @@ func TestGetSelectedItem(t *testing.T) {
 	itemMock := mock.Mock[ItemRepository]()
 	mock.When(itemMock.GetSelectedItem(userId, channelId)).ThenReturn(want, nil)
 
-	itemService := NewItemService(itemMock)
+	itemService := NewItemService(itemMock, mock.Mock[RarityGetter]())
 
 	got, err := itemService.GetSelectedItem(userId, channelId)
 
@@ -79,11 +81,11 @@ func TestSetSelectedItem(t *testing.T) {
 		itemMock := mock.Mock[ItemRepository]()
 		mock.When(itemMock.CheckOwnedItem(userId, itemId)).ThenReturn(true, nil)
 
-		itemService := NewItemService(itemMock)
+		itemService := NewItemService(itemMock, mock.Mock[RarityGetter]())
 
 		err := itemService.SetSelectedItem(userId, channelId, itemId)
 
-		mock.Verify(itemMock, mock.Once()).SetSelectedItem(channelId, userId, itemId)
+		mock.Verify(itemMock, mock.Once()).SetSelectedItem(userId, channelId, itemId)
 
 		assert.NoError(t, err)
 	})
@@ -98,9 +100,9 @@ func TestSetSelectedItem(t *testing.T) {
 		itemMock := mock.Mock[ItemRepository]()
 		mock.When(itemMock.CheckOwnedItem(userId, itemId)).ThenReturn(false, nil)
 
-		itemService := NewItemService(itemMock)
+		itemService := NewItemService(itemMock, mock.Mock[RarityGetter]())
 
-		mock.Verify(itemMock, mock.Never()).SetSelectedItem(channelId, userId, itemId)
+		mock.Verify(itemMock, mock.Never()).SetSelectedItem(userId, channelId, itemId)
 
 		err := itemService.SetSelectedItem(userId, channelId, itemId)
 		if assert.Error(t, err) {
@@ -118,7 +120,7 @@ func TestGetChannelsItems(t *testing.T) {
 	itemMock := mock.Mock[ItemRepository]()
 	mock.When(itemMock.GetChannelsItems(channelId)).ThenReturn(expected, nil)
 
-	itemService := NewItemService(itemMock)
+	itemService := NewItemService(itemMock, mock.Mock[RarityGetter]())
 
 	items, err := itemService.GetChannelsItems(channelId)
 
@@ -139,7 +141,7 @@ func TestGetOwnedItems(t *testing.T) {
 
 	mock.When(itemMock.GetOwnedItems(channelId, userId)).ThenReturn(expected, nil)
 
-	itemService := NewItemService(itemMock)
+	itemService := NewItemService(itemMock, mock.Mock[RarityGetter]())
 
 	items, err := itemService.GetOwnedItems(channelId, userId)
 
@@ -147,19 +149,291 @@ func TestGetOwnedItems(t *testing.T) {
 	assert.Equal(t, expected, items)
 }
 
-func TestAddOwnedItem(t *testing.T) {
+func TestBuyItem(t *testing.T) {
 	mock.SetUp(t)
 
-	userId := twitch.Id("user id")
-	itemId := uuid.New()
-	transactionId := uuid.New()
+	transaction := models.Transaction{TransactionId: uuid.New(), UserId: "user id", ItemId: uuid.New()}
+	original := models.Transaction{TransactionId: transaction.TransactionId, UserId: "user id", ItemId: uuid.New()}
+	item := models.Item{ItemId: original.ItemId, Name: "original"}
 
 	itemMock := mock.Mock[ItemRepository]()
-	mock.When(itemMock.AddOwnedItem(userId, itemId, transactionId)).ThenReturn(nil)
+	mock.When(itemMock.AddTransaction(transaction, true)).ThenReturn(original, nil)
+	mock.When(itemMock.GetItemById(original.ItemId)).ThenReturn(item, nil)
 
-	itemService := NewItemService(itemMock)
+	itemService := NewItemService(itemMock, mock.Mock[RarityGetter]())
 
-	err := itemService.AddOwnedItem(userId, itemId, transactionId)
+	got, err := itemService.BuyItem(transaction, true)
 
 	assert.NoError(t, err)
+	assert.Equal(t, item, got)
+}
+
+func TestGetBoughtItem(t *testing.T) {
+	transactionId := uuid.New()
+
+	t.Run("item of recorded transaction returned", func(t *testing.T) {
+		mock.SetUp(t)
+
+		transaction := models.Transaction{TransactionId: transactionId, ItemId: uuid.New()}
+		item := models.Item{ItemId: transaction.ItemId}
+
+		itemMock := mock.Mock[ItemRepository]()
+		mock.When(itemMock.GetTransaction(transactionId)).ThenReturn(transaction, nil)
+		mock.When(itemMock.GetItemById(transaction.ItemId)).ThenReturn(item, nil)
+
+		got, found, err := NewItemService(itemMock, mock.Mock[RarityGetter]()).GetBoughtItem(transactionId)
+
+		assert.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, item, got)
+	})
+
+	t.Run("not found when transaction never recorded", func(t *testing.T) {
+		mock.SetUp(t)
+
+		itemMock := mock.Mock[ItemRepository]()
+		notFound := apperrors.New(apperrors.NotFound, "transaction_not_found", "transaction not found")
+		mock.When(itemMock.GetTransaction(transactionId)).ThenReturn(models.Transaction{}, notFound)
+
+		_, found, err := NewItemService(itemMock, mock.Mock[RarityGetter]()).GetBoughtItem(transactionId)
+
+		assert.NoError(t, err)
+		assert.False(t, found)
+	})
+
+	t.Run("error returned when lookup fails", func(t *testing.T) {
+		mock.SetUp(t)
+
+		itemMock := mock.Mock[ItemRepository]()
+		mock.When(itemMock.GetTransaction(transactionId)).ThenReturn(models.Transaction{}, assert.AnError)
+
+		_, _, err := NewItemService(itemMock, mock.Mock[RarityGetter]()).GetBoughtItem(transactionId)
+
+		assert.Equal(t, assert.AnError, err)
+	})
+}
+
+func TestCreateItem(t *testing.T) {
+	channelId := twitch.Id("channel id")
+
+	t.Run("item created with preview defaulting to image", func(t *testing.T) {
+		mock.SetUp(t)
+
+		itemMock := mock.Mock[ItemRepository]()
+		mock.When(itemMock.GetItemByName(channelId, "red")).ThenReturn(models.Item{}, gorm.ErrRecordNotFound)
+		mock.When(itemMock.CreateItem(mock.Equal(channelId), mock.Any[models.Item]())).ThenReturn(nil)
+		mock.When(itemMock.GetCatalogItem(mock.Equal(channelId), mock.Any[uuid.UUID]())).ThenReturn(models.CatalogItem{}, nil)
+
+		itemService := NewItemService(itemMock, mock.Mock[RarityGetter]())
+
+		_, err := itemService.CreateItem(channelId, models.Item{Name: " red ", Rarity: models.Common, Image: "red.png"})
+		assert.NoError(t, err)
+
+		captor := mock.Captor[models.Item]()
+		mock.Verify(itemMock, mock.Once()).CreateItem(mock.Equal(channelId), captor.Capture())
+
+		created := captor.Last()
+		assert.NotEqual(t, uuid.Nil, created.ItemId)
+		assert.Equal(t, "red", created.Name)
+		assert.Equal(t, "red.png", created.PrevImg)
+	})
+
+	tests := map[string]struct {
+		item     models.Item
+		expected error
+	}{
+		"invalid name error when name empty":       {models.Item{Name: " ", Rarity: models.Common, Image: "img"}, ErrInvalidItemName},
+		"invalid name error when name too long":    {models.Item{Name: "abcdefghijklmnopqrstuvwxyz1234567", Rarity: models.Common, Image: "img"}, ErrInvalidItemName},
+		"invalid rarity error when rarity unknown": {models.Item{Name: "red", Rarity: "mythic", Image: "img"}, ErrInvalidRarity},
+		"no image error when image missing":        {models.Item{Name: "red", Rarity: models.Common}, ErrNoItemImage},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			mock.SetUp(t)
+
+			itemMock := mock.Mock[ItemRepository]()
+			rarities := mock.Mock[RarityGetter]()
+			mock.When(rarities.GetRarityTier(channelId, "mythic")).ThenReturn(models.RarityTier{}, ErrInvalidRarity)
+
+			itemService := NewItemService(itemMock, rarities)
+
+			_, err := itemService.CreateItem(channelId, test.item)
+
+			assert.Equal(t, test.expected, err)
+			mock.Verify(itemMock, mock.Never()).CreateItem(mock.Any[twitch.Id](), mock.Any[models.Item]())
+		})
+	}
+
+	t.Run("name taken error when channel has an item with the name", func(t *testing.T) {
+		mock.SetUp(t)
+
+		itemMock := mock.Mock[ItemRepository]()
+		mock.When(itemMock.GetItemByName(channelId, "red")).ThenReturn(models.Item{ItemId: uuid.New()}, nil)
+
+		itemService := NewItemService(itemMock, mock.Mock[RarityGetter]())
+
+		_, err := itemService.CreateItem(channelId, models.Item{Name: "red", Rarity: models.Common, Image: "img"})
+
+		assert.Equal(t, ErrItemNameTaken, err)
+		mock.Verify(itemMock, mock.Never()).CreateItem(mock.Any[twitch.Id](), mock.Any[models.Item]())
+	})
+}
+
+func TestUpdateItem(t *testing.T) {
+	channelId := twitch.Id("channel id")
+	itemId := uuid.New()
+	current := models.CatalogItem{Item: models.Item{ItemId: itemId, Name: "red", Rarity: models.Common, Image: "red", PrevImg: "red"}}
+
+	t.Run("item renamed", func(t *testing.T) {
+		mock.SetUp(t)
+
+		name := "crimson"
+		renamed := current.Item
+		renamed.Name = name
+
+		itemMock := mock.Mock[ItemRepository]()
+		mock.When(itemMock.GetCatalogItem(channelId, itemId)).ThenReturn(current, nil)
+		mock.When(itemMock.GetItemByName(channelId, name)).ThenReturn(models.Item{}, gorm.ErrRecordNotFound)
+		mock.When(itemMock.UpdateItem(renamed)).ThenReturn(nil)
+
+		itemService := NewItemService(itemMock, mock.Mock[RarityGetter]())
+
+		_, err := itemService.UpdateItem(channelId, itemId, ItemUpdate{Name: &name})
+
+		assert.NoError(t, err)
+		mock.Verify(itemMock, mock.Once()).UpdateItem(renamed)
+		mock.Verify(itemMock, mock.Never()).SetItemRetired(mock.Any[twitch.Id](), mock.Any[uuid.UUID](), mock.Any[bool]())
+	})
+
+	t.Run("item retired", func(t *testing.T) {
+		mock.SetUp(t)
+
+		retired := true
+
+		itemMock := mock.Mock[ItemRepository]()
+		mock.When(itemMock.GetCatalogItem(channelId, itemId)).ThenReturn(current, nil)
+		mock.When(itemMock.GetDefaultItem(channelId)).ThenReturn(models.Item{ItemId: uuid.New()}, nil)
+		mock.When(itemMock.SetItemRetired(channelId, itemId, true)).ThenReturn(nil)
+
+		itemService := NewItemService(itemMock, mock.Mock[RarityGetter]())
+
+		_, err := itemService.UpdateItem(channelId, itemId, ItemUpdate{Retired: &retired})
+
+		assert.NoError(t, err)
+		mock.Verify(itemMock, mock.Once()).SetItemRetired(channelId, itemId, true)
+		mock.Verify(itemMock, mock.Never()).UpdateItem(mock.Any[models.Item]())
+	})
+
+	t.Run("default item not retired", func(t *testing.T) {
+		mock.SetUp(t)
+
+		retired := true
+
+		itemMock := mock.Mock[ItemRepository]()
+		mock.When(itemMock.GetCatalogItem(channelId, itemId)).ThenReturn(current, nil)
+		mock.When(itemMock.GetDefaultItem(channelId)).ThenReturn(current.Item, nil)
+
+		itemService := NewItemService(itemMock, mock.Mock[RarityGetter]())
+
+		_, err := itemService.UpdateItem(channelId, itemId, ItemUpdate{Retired: &retired})
+
+		assert.Equal(t, ErrDefaultItemRemoved, err)
+		mock.Verify(itemMock, mock.Never()).SetItemRetired(mock.Any[twitch.Id](), mock.Any[uuid.UUID](), mock.Any[bool]())
+	})
+}
+
+func TestRemoveItem(t *testing.T) {
+	channelId := twitch.Id("channel id")
+	itemId := uuid.New()
+
+	t.Run("item removed", func(t *testing.T) {
+		mock.SetUp(t)
+
+		itemMock := mock.Mock[ItemRepository]()
+		mock.When(itemMock.GetDefaultItem(channelId)).ThenReturn(models.Item{ItemId: uuid.New()}, nil)
+		mock.When(itemMock.RemoveItem(channelId, itemId)).ThenReturn(true, nil)
+
+		itemService := NewItemService(itemMock, mock.Mock[RarityGetter]())
+
+		retired, err := itemService.RemoveItem(channelId, itemId)
+
+		assert.NoError(t, err)
+		assert.True(t, retired)
+	})
+
+	t.Run("default item not removed", func(t *testing.T) {
+		mock.SetUp(t)
+
+		itemMock := mock.Mock[ItemRepository]()
+		mock.When(itemMock.GetDefaultItem(channelId)).ThenReturn(models.Item{ItemId: itemId}, nil)
+
+		itemService := NewItemService(itemMock, mock.Mock[RarityGetter]())
+
+		_, err := itemService.RemoveItem(channelId, itemId)
+
+		assert.Equal(t, ErrDefaultItemRemoved, err)
+		mock.Verify(itemMock, mock.Never()).RemoveItem(mock.Any[twitch.Id](), mock.Any[uuid.UUID]())
+	})
+}
+
+func TestReorderItems(t *testing.T) {
+	channelId := twitch.Id("channel id")
+	first, second := uuid.New(), uuid.New()
+	catalog := []models.CatalogItem{{Item: models.Item{ItemId: first}}, {Item: models.Item{ItemId: second}}}
+
+	tests := map[string]struct {
+		itemIds  []uuid.UUID
+		expected error
+	}{
+		"items reordered":                           {[]uuid.UUID{second, first}, nil},
+		"invalid order error when item missing":     {[]uuid.UUID{second}, ErrInvalidItemOrder},
+		"invalid order error when item repeated":    {[]uuid.UUID{second, second}, ErrInvalidItemOrder},
+		"invalid order error when item not in shop": {[]uuid.UUID{second, uuid.New()}, ErrInvalidItemOrder},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			mock.SetUp(t)
+
+			itemMock := mock.Mock[ItemRepository]()
+			mock.When(itemMock.GetCatalog(channelId)).ThenReturn(catalog, nil)
+			mock.When(itemMock.SetItemPositions(channelId, test.itemIds)).ThenReturn(nil)
+
+			itemService := NewItemService(itemMock, mock.Mock[RarityGetter]())
+
+			err := itemService.ReorderItems(channelId, test.itemIds)
+
+			assert.Equal(t, test.expected, err)
+		})
+	}
+}
+
+func TestSetDefaultItem(t *testing.T) {
+	channelId := twitch.Id("channel id")
+	itemId := uuid.New()
+
+	tests := map[string]struct {
+		retired  bool
+		expected error
+	}{
+		"default item changed":                   {false, nil},
+		"retired item not made the default item": {true, ErrRetiredDefaultItem},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			mock.SetUp(t)
+
+			itemMock := mock.Mock[ItemRepository]()
+			mock.When(itemMock.GetCatalogItem(channelId, itemId)).ThenReturn(models.CatalogItem{Item: models.Item{ItemId: itemId}, Retired: test.retired}, nil)
+			mock.When(itemMock.SetDefaultItem(channelId, itemId)).ThenReturn(nil)
+
+			itemService := NewItemService(itemMock, mock.Mock[RarityGetter]())
+
+			err := itemService.SetDefaultItem(channelId, itemId)
+
+			assert.Equal(t, test.expected, err)
+		})
+	}
 }
@@ -0,0 +1,234 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/streampets/backend/apperrors"
+	"github.com/streampets/backend/models"
+	"github.com/streampets/backend/twitch"
+)
+
+var ErrInvalidSession = apperrors.New(apperrors.Unauthorized, "invalid_session", "session is not valid")
+
+// The dashboard does not need any scopes yet, logging in only proves who the streamer is.
+var loginScopes = []string{}
+
+// Stored Twitch access tokens are refreshed this long before they expire, so
+// they do not expire while in use.
+const twitchTokenExpiryMargin = time.Minute
+
+type OAuthClient interface {
+	AuthorizeUrl(redirectUri, state, codeChallenge string, scopes []string) string
+	ExchangeCode(ctx context.Context, code, redirectUri, codeVerifier string) (twitch.UserToken, error)
+	RefreshToken(ctx context.Context, refreshToken string) (twitch.UserToken, error)
+	ValidateToken(ctx context.Context, accessToken string) (twitch.Id, error)
+}
+
+type TwitchTokenStore interface {
+	SaveTwitchToken(token models.TwitchToken) error
+	GetTwitchToken(userId twitch.Id) (models.TwitchToken, error)
+}
+
+type SessionVersionStore interface {
+	GetSessionVersion(userId twitch.Id) (int, error)
+	BumpSessionVersion(userId twitch.Id) error
+}
+
+// A login that was started and waits for Twitch to redirect back. State and
+// Verifier have to be kept by the browser until then.
+type LoginAttempt struct {
+	Url      string
+	State    string
+	Verifier string
+}
+
+type Session struct {
+	jwt.RegisteredClaims
+	// Sessions are only valid while this matches the streamer's session version.
+	Version int `json:"ver,omitempty"`
+}
+
+type LoginService struct {
+	oauth       OAuthClient
+	tokens      TwitchTokenStore
+	versions    SessionVersionStore
+	redirectUri string
+	secret      []byte
+	sessionTtl  time.Duration
+	now         func() time.Time
+}
+
+func NewLoginService(
+	oauth OAuthClient,
+	tokens TwitchTokenStore,
+	versions SessionVersionStore,
+	redirectUri string,
+	secret []byte,
+	sessionTtl time.Duration,
+) *LoginService {
+	return &LoginService{
+		oauth:       oauth,
+		tokens:      tokens,
+		versions:    versions,
+		redirectUri: redirectUri,
+		secret:      secret,
+		sessionTtl:  sessionTtl,
+		now:         time.Now,
+	}
+}
+
+// Starts a login with a new state and PKCE verifier.
+func (s *LoginService) StartLogin() (LoginAttempt, error) {
+	state, err := randomString()
+	if err != nil {
+		return LoginAttempt{}, err
+	}
+
+	verifier, err := randomString()
+	if err != nil {
+		return LoginAttempt{}, err
+	}
+
+	challenge := sha256.Sum256([]byte(verifier))
+	url := s.oauth.AuthorizeUrl(s.redirectUri, state, base64.RawURLEncoding.EncodeToString(challenge[:]), loginScopes)
+
+	return LoginAttempt{Url: url, State: state, Verifier: verifier}, nil
+}
+
+// Exchanges the code Twitch sent the streamer back with, stores their Twitch
+// tokens and returns a signed session for them.
+func (s *LoginService) FinishLogin(ctx context.Context, code, verifier string) (string, error) {
+	token, err := s.oauth.ExchangeCode(ctx, code, s.redirectUri, verifier)
+	if err != nil {
+		return "", err
+	}
+
+	userId, err := s.oauth.ValidateToken(ctx, token.AccessToken)
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.tokens.SaveTwitchToken(twitchTokenModel(userId, token)); err != nil {
+		return "", err
+	}
+
+	return s.createSession(userId)
+}
+
+// Returns an access token for calling Twitch on the streamer's behalf. The
+// stored one is refreshed with the stored refresh token once it is about to
+// expire. Returns twitch.ErrInvalidRefreshToken if the streamer has to log
+// in again.
+func (s *LoginService) TwitchAccessToken(ctx context.Context, userId twitch.Id) (string, error) {
+	token, err := s.tokens.GetTwitchToken(userId)
+	if err != nil {
+		return "", err
+	}
+
+	if s.now().Add(twitchTokenExpiryMargin).Before(token.ExpiresAt) {
+		return token.AccessToken, nil
+	}
+
+	refreshed, err := s.oauth.RefreshToken(ctx, token.RefreshToken)
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.tokens.SaveTwitchToken(twitchTokenModel(userId, refreshed)); err != nil {
+		return "", err
+	}
+
+	return refreshed.AccessToken, nil
+}
+
+func twitchTokenModel(userId twitch.Id, token twitch.UserToken) models.TwitchToken {
+	return models.TwitchToken{
+		UserId:       userId,
+		AccessToken:  token.AccessToken,
+		RefreshToken: token.RefreshToken,
+		Scopes:       strings.Join(token.Scopes, " "),
+		ExpiresAt:    token.ExpiresAt,
+	}
+}
+
+func (s *LoginService) createSession(userId twitch.Id) (string, error) {
+	version, err := s.versions.GetSessionVersion(userId)
+	if err != nil {
+		return "", err
+	}
+
+	now := s.now()
+	session := jwt.NewWithClaims(jwt.SigningMethodHS256, Session{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   string(userId),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(s.sessionTtl)),
+		},
+		Version: version,
+	})
+	return session.SignedString(s.secret)
+}
+
+// Returns the id of the streamer the session belongs to.
+// Returns ErrInvalidSession if it was not signed by us, has expired or the
+// streamer has logged out since it was issued.
+func (s *LoginService) VerifySession(session string) (twitch.Id, error) {
+	token, err := jwt.ParseWithClaims(session, &Session{}, s.keyFunc, jwt.WithTimeFunc(s.now))
+	if err != nil {
+		return "", ErrInvalidSession
+	}
+
+	claims, ok := token.Claims.(*Session)
+	if !ok || !token.Valid || claims.Subject == "" {
+		return "", ErrInvalidSession
+	}
+
+	userId := twitch.Id(claims.Subject)
+	version, err := s.versions.GetSessionVersion(userId)
+	if err != nil {
+		return "", err
+	}
+	if claims.Version != version {
+		return "", ErrInvalidSession
+	}
+
+	return userId, nil
+}
+
+// Ends every session of the streamer the session belongs to, so a copied
+// session cookie stops working too. Sessions that are already invalid have
+// nothing to end.
+func (s *LoginService) Logout(session string) error {
+	userId, err := s.VerifySession(session)
+	if err == ErrInvalidSession {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return s.versions.BumpSessionVersion(userId)
+}
+
+func (s *LoginService) keyFunc(token *jwt.Token) (interface{}, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		return nil, ErrUnexpectedSigningMethod
+	}
+	return s.secret, nil
+}
+
+// Returns 32 random bytes, base64url encoded. Long enough for both an OAuth
+// state and a PKCE verifier.
+func randomString() (string, error) {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(bytes), nil
+}
@@ -0,0 +1,207 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/ovechkin-dm/mockio/mock"
+	"github.com/streampets/backend/models"
+	"github.com/streampets/backend/twitch"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoginService(t *testing.T) {
+	redirectUri := "https://backend/dashboard/oauth/callback"
+	secret := []byte("secret")
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	setUpService := func() (*LoginService, OAuthClient, TwitchTokenStore, SessionVersionStore) {
+		oauth := mock.Mock[OAuthClient]()
+		tokens := mock.Mock[TwitchTokenStore]()
+		versions := mock.Mock[SessionVersionStore]()
+
+		service := NewLoginService(oauth, tokens, versions, redirectUri, secret, time.Hour)
+		service.now = func() time.Time { return now }
+		return service, oauth, tokens, versions
+	}
+
+	t.Run("login started with state and challenge of verifier", func(t *testing.T) {
+		mock.SetUp(t)
+
+		service, oauth, _, _ := setUpService()
+		mock.When(oauth.AuthorizeUrl(mock.AnyString(), mock.AnyString(), mock.AnyString(), mock.Any[[]string]())).ThenReturn("url")
+
+		attempt, err := service.StartLogin()
+
+		assert.NoError(t, err)
+		assert.Equal(t, "url", attempt.Url)
+		assert.NotEmpty(t, attempt.State)
+		assert.NotEqual(t, attempt.State, attempt.Verifier)
+
+		challenge := sha256.Sum256([]byte(attempt.Verifier))
+		mock.Verify(oauth, mock.Once()).AuthorizeUrl(redirectUri, attempt.State, base64.RawURLEncoding.EncodeToString(challenge[:]), loginScopes)
+	})
+
+	t.Run("twitch token saved and session returned when login finished", func(t *testing.T) {
+		mock.SetUp(t)
+
+		ctx := context.Background()
+		userId := twitch.Id("user id")
+		token := twitch.UserToken{AccessToken: "access", RefreshToken: "refresh", ExpiresAt: now.Add(time.Hour), Scopes: []string{"a", "b"}}
+
+		service, oauth, tokens, _ := setUpService()
+		mock.When(oauth.ExchangeCode(ctx, "code", redirectUri, "verifier")).ThenReturn(token, nil)
+		mock.When(oauth.ValidateToken(ctx, "access")).ThenReturn(userId, nil)
+		mock.When(tokens.SaveTwitchToken(mock.Any[models.TwitchToken]())).ThenReturn(nil)
+
+		session, err := service.FinishLogin(ctx, "code", "verifier")
+		assert.NoError(t, err)
+
+		mock.Verify(tokens, mock.Once()).SaveTwitchToken(models.TwitchToken{
+			UserId:       userId,
+			AccessToken:  "access",
+			RefreshToken: "refresh",
+			Scopes:       "a b",
+			ExpiresAt:    now.Add(time.Hour),
+		})
+
+		got, err := service.VerifySession(session)
+		assert.NoError(t, err)
+		assert.Equal(t, userId, got)
+	})
+
+	t.Run("login not finished when code invalid", func(t *testing.T) {
+		mock.SetUp(t)
+
+		ctx := context.Background()
+
+		service, oauth, tokens, _ := setUpService()
+		mock.When(oauth.ExchangeCode(ctx, "code", redirectUri, "verifier")).ThenReturn(twitch.UserToken{}, twitch.ErrInvalidAuthorizationCode)
+
+		_, err := service.FinishLogin(ctx, "code", "verifier")
+
+		assert.Equal(t, twitch.ErrInvalidAuthorizationCode, err)
+		mock.Verify(tokens, mock.Never()).SaveTwitchToken(mock.Any[models.TwitchToken]())
+	})
+
+	t.Run("stored twitch access token returned while valid", func(t *testing.T) {
+		mock.SetUp(t)
+
+		ctx := context.Background()
+		userId := twitch.Id("user id")
+
+		service, oauth, tokens, _ := setUpService()
+		mock.When(tokens.GetTwitchToken(userId)).ThenReturn(models.TwitchToken{UserId: userId, AccessToken: "access", RefreshToken: "refresh", ExpiresAt: now.Add(time.Hour)}, nil)
+
+		accessToken, err := service.TwitchAccessToken(ctx, userId)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "access", accessToken)
+		mock.Verify(oauth, mock.Never()).RefreshToken(mock.Any[context.Context](), mock.AnyString())
+	})
+
+	t.Run("twitch access token refreshed and saved once expired", func(t *testing.T) {
+		mock.SetUp(t)
+
+		ctx := context.Background()
+		userId := twitch.Id("user id")
+		refreshed := twitch.UserToken{AccessToken: "new access", RefreshToken: "new refresh", ExpiresAt: now.Add(time.Hour), Scopes: []string{"a"}}
+
+		service, oauth, tokens, _ := setUpService()
+		mock.When(tokens.GetTwitchToken(userId)).ThenReturn(models.TwitchToken{UserId: userId, AccessToken: "access", RefreshToken: "refresh", ExpiresAt: now}, nil)
+		mock.When(oauth.RefreshToken(ctx, "refresh")).ThenReturn(refreshed, nil)
+		mock.When(tokens.SaveTwitchToken(mock.Any[models.TwitchToken]())).ThenReturn(nil)
+
+		accessToken, err := service.TwitchAccessToken(ctx, userId)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "new access", accessToken)
+		mock.Verify(tokens, mock.Once()).SaveTwitchToken(models.TwitchToken{
+			UserId:       userId,
+			AccessToken:  "new access",
+			RefreshToken: "new refresh",
+			Scopes:       "a",
+			ExpiresAt:    now.Add(time.Hour),
+		})
+	})
+
+	t.Run("twitch access token not returned when refresh token rejected", func(t *testing.T) {
+		mock.SetUp(t)
+
+		ctx := context.Background()
+		userId := twitch.Id("user id")
+
+		service, oauth, tokens, _ := setUpService()
+		mock.When(tokens.GetTwitchToken(userId)).ThenReturn(models.TwitchToken{UserId: userId, RefreshToken: "refresh", ExpiresAt: now}, nil)
+		mock.When(oauth.RefreshToken(ctx, "refresh")).ThenReturn(twitch.UserToken{}, twitch.ErrInvalidRefreshToken)
+
+		_, err := service.TwitchAccessToken(ctx, userId)
+
+		assert.Equal(t, twitch.ErrInvalidRefreshToken, err)
+		mock.Verify(tokens, mock.Never()).SaveTwitchToken(mock.Any[models.TwitchToken]())
+	})
+
+	t.Run("session invalid once expired", func(t *testing.T) {
+		mock.SetUp(t)
+
+		service, _, _, _ := setUpService()
+		session, err := service.createSession("user id")
+		assert.NoError(t, err)
+
+		service.now = func() time.Time { return now.Add(time.Hour + time.Second) }
+		_, err = service.VerifySession(session)
+
+		assert.Equal(t, ErrInvalidSession, err)
+	})
+
+	t.Run("session invalid when signed with other secret", func(t *testing.T) {
+		mock.SetUp(t)
+
+		other := NewLoginService(nil, nil, mock.Mock[SessionVersionStore](), redirectUri, []byte("other secret"), time.Hour)
+		other.now = func() time.Time { return now }
+		session, err := other.createSession("user id")
+		assert.NoError(t, err)
+
+		service, _, _, _ := setUpService()
+		_, err = service.VerifySession(session)
+
+		assert.Equal(t, ErrInvalidSession, err)
+	})
+
+	t.Run("session invalid once streamer logged out", func(t *testing.T) {
+		mock.SetUp(t)
+
+		userId := twitch.Id("user id")
+		service, _, _, versions := setUpService()
+		// Issued and logged out at version 0, then at version 1 after logging out.
+		mock.When(versions.GetSessionVersion(userId)).ThenReturn(0, nil).ThenReturn(0, nil).ThenReturn(1, nil)
+		mock.When(versions.BumpSessionVersion(userId)).ThenReturn(nil)
+
+		session, err := service.createSession(userId)
+		assert.NoError(t, err)
+
+		assert.NoError(t, service.Logout(session))
+		mock.Verify(versions, mock.Once()).BumpSessionVersion(userId)
+
+		_, err = service.VerifySession(session)
+		assert.Equal(t, ErrInvalidSession, err)
+
+		newSession, err := service.createSession(userId)
+		assert.NoError(t, err)
+		got, err := service.VerifySession(newSession)
+		assert.NoError(t, err)
+		assert.Equal(t, userId, got)
+	})
+
+	t.Run("nothing revoked when logging out with invalid session", func(t *testing.T) {
+		mock.SetUp(t)
+
+		service, _, _, versions := setUpService()
+
+		assert.NoError(t, service.Logout("not a session"))
+		mock.Verify(versions, mock.Never()).BumpSessionVersion(mock.Any[twitch.Id]())
+	})
+}
@@ -9,6 +9,13 @@ type Pet struct {
 	UserId   twitch.Id `json:"userId"`
 	Username string    `json:"username"`
 	Image    string    `json:"color"`
+	Position *Position `json:"position,omitempty"`
+}
+
+// Where an overlay last reported a pet, so that other overlays can place it in the same spot.
+type Position struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
 }
 
 type SelectedItemGetter interface {
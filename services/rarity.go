@@ -0,0 +1,84 @@
+package services
+
+import (
+	"sort"
+
+	"github.com/streampets/backend/apperrors"
+	"github.com/streampets/backend/models"
+	"github.com/streampets/backend/twitch"
+)
+
+var ErrUnknownSku = apperrors.New(apperrors.Invalid, "unknown_sku", "no rarity is sold under this sku")
+
+type RarityRepository interface {
+	GetRarityTiers(channelId twitch.Id) ([]models.RarityTier, error)
+}
+
+type RarityService struct {
+	rarityRepo RarityRepository
+}
+
+func NewRarityService(rarityRepo RarityRepository) *RarityService {
+	return &RarityService{
+		rarityRepo: rarityRepo,
+	}
+}
+
+// Returns the tiers the channel's items can have in sort order. The
+// channel's own tiers replace global tiers of the same name.
+func (s *RarityService) GetRarityTiers(channelId twitch.Id) ([]models.RarityTier, error) {
+	stored, err := s.rarityRepo.GetRarityTiers(channelId)
+	if err != nil {
+		return nil, err
+	}
+
+	byName := map[models.Rarity]models.RarityTier{}
+	for _, tier := range stored {
+		if existing, ok := byName[tier.Name]; ok && existing.ChannelId != "" {
+			continue
+		}
+		byName[tier.Name] = tier
+	}
+
+	tiers := make([]models.RarityTier, 0, len(byName))
+	for _, tier := range byName {
+		tiers = append(tiers, tier)
+	}
+	sort.Slice(tiers, func(i, j int) bool {
+		if tiers[i].SortOrder != tiers[j].SortOrder {
+			return tiers[i].SortOrder < tiers[j].SortOrder
+		}
+		return tiers[i].Name < tiers[j].Name
+	})
+
+	return tiers, nil
+}
+
+func (s *RarityService) GetRarityTier(channelId twitch.Id, name models.Rarity) (models.RarityTier, error) {
+	tiers, err := s.GetRarityTiers(channelId)
+	if err != nil {
+		return models.RarityTier{}, err
+	}
+
+	for _, tier := range tiers {
+		if tier.Name == name {
+			return tier, nil
+		}
+	}
+	return models.RarityTier{}, ErrInvalidRarity
+}
+
+// Returns the tier sold as the Bits product with the sku in the channel.
+func (s *RarityService) ResolveSku(channelId twitch.Id, sku string) (models.RarityTier, error) {
+	tiers, err := s.GetRarityTiers(channelId)
+	if err != nil {
+		return models.RarityTier{}, err
+	}
+
+	for _, tier := range tiers {
+		if tier.Sku == sku {
+			return tier, nil
+		}
+	}
+	return models.RarityTier{}, ErrUnknownSku
+}
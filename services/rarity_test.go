@@ -0,0 +1,73 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/ovechkin-dm/mockio/mock"
+	"github.com/streampets/backend/models"
+	"github.com/streampets/backend/twitch"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetRarityTiers(t *testing.T) {
+	mock.SetUp(t)
+
+	channelId := twitch.Id("channel id")
+	common := models.RarityTier{Name: models.Common, Sku: "common", SortOrder: 0}
+	uncommon := models.RarityTier{Name: models.Uncommon, Sku: "uncommon", SortOrder: 1}
+	channelsUncommon := models.RarityTier{ChannelId: channelId, Name: models.Uncommon, Sku: "uncommon", Colour: "#ff0000", SortOrder: 1}
+	rare := models.RarityTier{ChannelId: channelId, Name: "rare", Sku: "rare", SortOrder: 2}
+
+	repoMock := mock.Mock[RarityRepository]()
+	mock.When(repoMock.GetRarityTiers(channelId)).ThenReturn([]models.RarityTier{common, uncommon, rare, channelsUncommon}, nil)
+
+	tiers, err := NewRarityService(repoMock).GetRarityTiers(channelId)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []models.RarityTier{common, channelsUncommon, rare}, tiers)
+}
+
+func TestResolveSku(t *testing.T) {
+	channelId := twitch.Id("channel id")
+	legendary := models.RarityTier{Name: "legendary", Sku: "legendary_pet"}
+
+	tests := map[string]struct {
+		sku      string
+		expected models.RarityTier
+		err      error
+	}{
+		"tier sold under the sku returned":          {"legendary_pet", legendary, nil},
+		"unknown sku error when none sold under it": {"mythic_pet", models.RarityTier{}, ErrUnknownSku},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			mock.SetUp(t)
+
+			repoMock := mock.Mock[RarityRepository]()
+			mock.When(repoMock.GetRarityTiers(channelId)).ThenReturn([]models.RarityTier{legendary}, nil)
+
+			tier, err := NewRarityService(repoMock).ResolveSku(channelId, test.sku)
+
+			assert.Equal(t, test.err, err)
+			assert.Equal(t, test.expected, tier)
+		})
+	}
+}
+
+func TestGetRarityTier(t *testing.T) {
+	mock.SetUp(t)
+
+	channelId := twitch.Id("channel id")
+
+	repoMock := mock.Mock[RarityRepository]()
+	mock.When(repoMock.GetRarityTiers(channelId)).ThenReturn([]models.RarityTier{{Name: models.Common}}, nil)
+
+	rarityService := NewRarityService(repoMock)
+
+	_, err := rarityService.GetRarityTier(channelId, models.Common)
+	assert.NoError(t, err)
+
+	_, err = rarityService.GetRarityTier(channelId, "rare")
+	assert.Equal(t, ErrInvalidRarity, err)
+}
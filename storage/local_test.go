@@ -0,0 +1,53 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocalStore(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("stored asset can be read back", func(t *testing.T) {
+		store := NewLocalStore(filepath.Join(t.TempDir(), "assets"))
+
+		assert.NoError(t, store.Put(ctx, "abc.png", []byte("image")))
+
+		object, err := store.Get(ctx, "abc.png")
+		if assert.NoError(t, err) {
+			defer object.Body.Close()
+			data, _ := io.ReadAll(object.Body)
+			assert.Equal(t, "image", string(data))
+			assert.Equal(t, "image/png", object.ContentType)
+			assert.Equal(t, int64(5), object.Size)
+		}
+	})
+
+	t.Run("not found error when asset missing", func(t *testing.T) {
+		store := NewLocalStore(t.TempDir())
+
+		_, err := store.Get(ctx, "abc.png")
+
+		assert.Equal(t, ErrAssetNotFound, err)
+	})
+
+	t.Run("keys outside the store refused", func(t *testing.T) {
+		dir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(dir, "secret.txt"), []byte("secret"), 0o644); err != nil {
+			panic(err)
+		}
+
+		store := NewLocalStore(filepath.Join(dir, "assets"))
+
+		for _, key := range []string{"../secret.txt", "..", ".env", "a/b.png", ""} {
+			_, err := store.Get(ctx, key)
+			assert.Equal(t, ErrAssetNotFound, err, key)
+			assert.Error(t, store.Put(ctx, key, []byte("data")), key)
+		}
+	})
+}
@@ -0,0 +1,114 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/streampets/backend/apperrors"
+)
+
+// Stores assets in a bucket of an S3 compatible object store. Objects are
+// addressed path style, as in "<endpoint>/<bucket>/<key>", which every S3
+// compatible store supports.
+type S3Store struct {
+	client   *http.Client
+	endpoint string
+	bucket   string
+	creds    credentials
+	now      func() time.Time
+}
+
+func NewS3Store(client *http.Client, endpoint, bucket, region, accessKey, secretKey string) *S3Store {
+	return &S3Store{
+		client:   client,
+		endpoint: strings.TrimSuffix(endpoint, "/"),
+		bucket:   bucket,
+		creds: credentials{
+			accessKey: accessKey,
+			secretKey: secretKey,
+			region:    region,
+			service:   "s3",
+		},
+		now: time.Now,
+	}
+}
+
+func (s *S3Store) Put(ctx context.Context, key string, data []byte) error {
+	if !validKey(key) {
+		return fmt.Errorf("invalid asset key %q", key)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s.objectUrl(key), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentTypeOf(key))
+	// Assets never change, so they can be cached by anything in front of the bucket too.
+	req.Header.Set("Cache-Control", "public, max-age=31536000, immutable")
+
+	res, err := s.do(req, hashHex(data))
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+
+	return nil
+}
+
+func (s *S3Store) Get(ctx context.Context, key string) (Object, error) {
+	if !validKey(key) {
+		return Object{}, ErrAssetNotFound
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.objectUrl(key), nil)
+	if err != nil {
+		return Object{}, err
+	}
+
+	res, err := s.do(req, emptyPayloadHash)
+	if err != nil {
+		return Object{}, err
+	}
+
+	contentType := res.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = contentTypeOf(key)
+	}
+
+	return Object{Body: res.Body, ContentType: contentType, Size: res.ContentLength}, nil
+}
+
+func (s *S3Store) objectUrl(key string) string {
+	return fmt.Sprintf("%s/%s/%s", s.endpoint, url.PathEscape(s.bucket), url.PathEscape(key))
+}
+
+// Signs and sends the request. Responses other than 2xx are closed and
+// turned into errors.
+func (s *S3Store) do(req *http.Request, payloadHash string) (*http.Response, error) {
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	s.creds.sign(req, payloadHash, s.now())
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return nil, apperrors.Wrap(err, apperrors.Unavailable, "storage_unavailable", "asset storage unavailable")
+	}
+
+	switch {
+	case res.StatusCode >= 200 && res.StatusCode < 300:
+		return res, nil
+	case res.StatusCode == http.StatusNotFound:
+		res.Body.Close()
+		return nil, ErrAssetNotFound
+	case res.StatusCode >= 500:
+		res.Body.Close()
+		return nil, ErrStorageUnavailable
+	default:
+		res.Body.Close()
+		return nil, fmt.Errorf("object store responded with status %d to %s %s", res.StatusCode, req.Method, req.URL.Path)
+	}
+}
@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/streampets/backend/apperrors"
+	"github.com/stretchr/testify/assert"
+)
+
+// A stand-in for an S3 compatible store that keeps objects in memory.
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	types   map[string]string
+	auth    []string
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.auth = append(f.auth, r.Header.Get("Authorization"))
+	if r.Header.Get("X-Amz-Content-Sha256") == "" {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		data, _ := io.ReadAll(r.Body)
+		if r.Header.Get("X-Amz-Content-Sha256") != hashHex(data) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		f.objects[r.URL.Path] = data
+		f.types[r.URL.Path] = r.Header.Get("Content-Type")
+	case http.MethodGet:
+		data, ok := f.objects[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", f.types[r.URL.Path])
+		w.Write(data)
+	}
+}
+
+func TestS3Store(t *testing.T) {
+	ctx := context.Background()
+
+	setUp := func() (*S3Store, *fakeS3) {
+		fake := &fakeS3{objects: map[string][]byte{}, types: map[string]string{}}
+		server := httptest.NewServer(fake)
+		t.Cleanup(server.Close)
+
+		store := NewS3Store(server.Client(), server.URL, "bucket", "us-east-1", "access key", "secret key")
+		store.now = func() time.Time { return time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC) }
+		return store, fake
+	}
+
+	t.Run("stored asset can be read back", func(t *testing.T) {
+		store, fake := setUp()
+
+		assert.NoError(t, store.Put(ctx, "abc.png", []byte("image")))
+		assert.Equal(t, []byte("image"), fake.objects["/bucket/abc.png"])
+
+		object, err := store.Get(ctx, "abc.png")
+		if assert.NoError(t, err) {
+			defer object.Body.Close()
+			data, _ := io.ReadAll(object.Body)
+			assert.Equal(t, "image", string(data))
+			assert.Equal(t, "image/png", object.ContentType)
+		}
+
+		for _, auth := range fake.auth {
+			assert.True(t, strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=access key/20240101/us-east-1/s3/aws4_request, SignedHeaders="), auth)
+		}
+	})
+
+	t.Run("not found error when asset missing", func(t *testing.T) {
+		store, _ := setUp()
+
+		_, err := store.Get(ctx, "abc.png")
+
+		assert.Equal(t, ErrAssetNotFound, err)
+	})
+
+	t.Run("unavailable error when store cannot be reached", func(t *testing.T) {
+		server := httptest.NewServer(http.NotFoundHandler())
+		server.Close()
+
+		store := NewS3Store(server.Client(), server.URL, "bucket", "us-east-1", "access key", "secret key")
+
+		err := store.Put(ctx, "abc.png", []byte("image"))
+
+		assert.Equal(t, apperrors.Unavailable, apperrors.KindOf(err))
+	})
+}
@@ -0,0 +1,40 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"mime"
+	"path"
+	"strings"
+
+	"github.com/streampets/backend/apperrors"
+)
+
+var ErrAssetNotFound = apperrors.New(apperrors.NotFound, "asset_not_found", "asset not found")
+var ErrStorageUnavailable = apperrors.New(apperrors.Unavailable, "storage_unavailable", "asset storage unavailable")
+
+// Where uploaded assets are kept, implemented by LocalStore and S3Store.
+type Store interface {
+	Put(ctx context.Context, key string, data []byte) error
+	Get(ctx context.Context, key string) (Object, error)
+}
+
+// A stored asset. Body has to be closed.
+type Object struct {
+	Body        io.ReadCloser
+	ContentType string
+	Size        int64
+}
+
+// Keys are file names like "<hash>.png", anything that could be a path is
+// refused so keys from requests cannot reach outside the store.
+func validKey(key string) bool {
+	return key != "" && !strings.HasPrefix(key, ".") && !strings.ContainsAny(key, `/\`) && path.Ext(key) != ""
+}
+
+func contentTypeOf(key string) string {
+	if contentType := mime.TypeByExtension(path.Ext(key)); contentType != "" {
+		return contentType
+	}
+	return "application/octet-stream"
+}
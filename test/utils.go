@@ -1,26 +1,18 @@
 package test
 
 import (
-	"github.com/streampets/backend/models"
+	"github.com/streampets/backend/migrations"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
 func CreateTestDB() *gorm.DB {
-	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{TranslateError: true})
 	if err != nil {
 		panic(err)
 	}
 
-	if err := db.AutoMigrate(
-		&models.ChannelItem{},
-		&models.Channel{},
-		&models.DefaultChannelItem{},
-		&models.Item{},
-		&models.OwnedItem{},
-		&models.SelectedItem{},
-		&models.User{},
-	); err != nil {
+	if _, err := migrations.Up(db); err != nil {
 		panic(err)
 	}
 
@@ -4,22 +4,49 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"sync"
+	"time"
+
+	"github.com/streampets/backend/apperrors"
 )
 
 // A struct used to communicate with the Twitch Api.
 type TwitchApi struct {
-	client  *http.Client
-	baseUrl string
+	client       *http.Client
+	baseUrl      string
+	helixUrl     string
+	clientId     string
+	clientSecret string
+
+	// Held while a new app access token is requested so that only one is.
+	tokenMu sync.Mutex
+	token   appToken
+
+	limitMu   sync.Mutex
+	rateLimit rateLimit
+
+	now   func() time.Time
+	sleep func(ctx context.Context, d time.Duration) error
 }
 
-// Creates a new TwitchApi client.
+// Creates a new TwitchApi client. baseUrl is the Twitch authentication
+// server and helixUrl the Helix API, which is called with an app access token
+// for the given client.
 func New(
 	client *http.Client,
 	baseUrl string,
+	helixUrl string,
+	clientId string,
+	clientSecret string,
 ) *TwitchApi {
 	return &TwitchApi{
-		client:  client,
-		baseUrl: baseUrl,
+		client:       client,
+		baseUrl:      baseUrl,
+		helixUrl:     helixUrl,
+		clientId:     clientId,
+		clientSecret: clientSecret,
+		now:          time.Now,
+		sleep:        sleep,
 	}
 }
 
@@ -41,17 +68,26 @@ func (t *TwitchApi) ValidateToken(ctx context.Context, accessToken string) (Id,
 
 	response, err := t.client.Do(req)
 	if err != nil {
-		return "", err
+		return "", apperrors.Wrap(err, apperrors.Unavailable, "twitch_unavailable", "twitch is unavailable")
 	}
+	defer response.Body.Close()
 
-	if response.StatusCode == 401 {
+	if response.StatusCode == http.StatusUnauthorized {
 		return "", ErrInvalidUserToken
 	}
+	if err := checkStatus(response); err != nil {
+		return "", err
+	}
 
 	var data validateResponse
 	if err = parseResponse(&data, response); err != nil {
 		return "", err
 	}
 
+	// App access tokens are valid too, but do not belong to a user.
+	if data.UserId == "" {
+		return "", ErrInvalidUserToken
+	}
+
 	return data.UserId, nil
 }
@@ -7,6 +7,7 @@ import (
 	"net/http/httptest"
 	"testing"
 
+	"github.com/streampets/backend/apperrors"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -25,7 +26,7 @@ func TestValidateToken(t *testing.T) {
 		defer server.Close()
 
 		client := &http.Client{}
-		api := New(client, server.URL)
+		api := New(client, server.URL, server.URL, "client id", "client secret")
 
 		ctx := context.Background()
 		userId, err := api.ValidateToken(ctx, "valid token")
@@ -41,7 +42,7 @@ func TestValidateToken(t *testing.T) {
 		defer server.Close()
 
 		client := &http.Client{}
-		api := New(client, server.URL)
+		api := New(client, server.URL, server.URL, "client id", "client secret")
 
 		ctx := context.Background()
 		_, err := api.ValidateToken(ctx, "valid token")
@@ -50,4 +51,43 @@ func TestValidateToken(t *testing.T) {
 			assert.Equal(t, ErrInvalidUserToken, err)
 		}
 	})
+
+	tests := map[string]struct {
+		status   int
+		response string
+		kind     apperrors.Kind
+	}{
+		"invalid user token error when token has no user": {http.StatusOK, `{"client_id":"client id"}`, apperrors.Unauthorized},
+		"unavailable error when twitch fails":             {http.StatusServiceUnavailable, "", apperrors.Unavailable},
+		"error when twitch refuses the request":           {http.StatusBadRequest, `{"message":"bad request"}`, apperrors.Internal},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(test.status)
+				fmt.Fprintln(w, test.response)
+			}))
+			defer server.Close()
+
+			api := New(&http.Client{}, server.URL, server.URL, "client id", "client secret")
+
+			userId, err := api.ValidateToken(context.Background(), "token")
+
+			assert.Error(t, err)
+			assert.Equal(t, test.kind, apperrors.KindOf(err))
+			assert.Equal(t, Id(""), userId)
+		})
+	}
+
+	t.Run("unavailable error when twitch cannot be reached", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		server.Close()
+
+		api := New(&http.Client{}, server.URL, server.URL, "client id", "client secret")
+
+		_, err := api.ValidateToken(context.Background(), "token")
+
+		assert.Equal(t, apperrors.Unavailable, apperrors.KindOf(err))
+	})
 }
@@ -0,0 +1,110 @@
+package twitch
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/streampets/backend/apperrors"
+)
+
+// Headers sent by Twitch with every EventSub webhook request.
+const (
+	EventSubMessageId        string = "Twitch-Eventsub-Message-Id"
+	EventSubMessageType      string = "Twitch-Eventsub-Message-Type"
+	EventSubMessageSignature string = "Twitch-Eventsub-Message-Signature"
+	EventSubMessageTimestamp string = "Twitch-Eventsub-Message-Timestamp"
+)
+
+// Values of the EventSubMessageType header.
+const (
+	NotificationMessage string = "notification"
+	VerificationMessage string = "webhook_callback_verification"
+	RevocationMessage   string = "revocation"
+)
+
+// Subscription types handled by the backend.
+const (
+	ChatMessageSubscription string = "channel.chat.message"
+	FollowSubscription      string = "channel.follow"
+	SubscribeSubscription   string = "channel.subscribe"
+	RaidSubscription        string = "channel.raid"
+)
+
+// Twitch recommends rejecting messages older than this to prevent replays.
+const maxEventSubMessageAge = 10 * time.Minute
+
+var ErrInvalidSignature = apperrors.New(apperrors.Forbidden, "invalid_eventsub_signature", "eventsub message signature is not valid")
+var ErrStaleMessage = apperrors.New(apperrors.Forbidden, "stale_eventsub_message", "eventsub message is too old")
+
+// The body of an EventSub webhook request.
+type EventSubMessage struct {
+	Subscription EventSubSubscription `json:"subscription"`
+	// Set on notifications, decode it according to Subscription.Type.
+	Event json.RawMessage `json:"event"`
+	// Set on webhook_callback_verification messages.
+	Challenge string `json:"challenge"`
+}
+
+type EventSubSubscription struct {
+	Id      string `json:"id"`
+	Type    string `json:"type"`
+	Version string `json:"version"`
+	Status  string `json:"status"`
+}
+
+type ChatMessageEvent struct {
+	BroadcasterUserId Id     `json:"broadcaster_user_id"`
+	ChatterUserId     Id     `json:"chatter_user_id"`
+	ChatterUserName   string `json:"chatter_user_name"`
+	Message           struct {
+		Text string `json:"text"`
+	} `json:"message"`
+}
+
+type FollowEvent struct {
+	BroadcasterUserId Id     `json:"broadcaster_user_id"`
+	UserId            Id     `json:"user_id"`
+	UserName          string `json:"user_name"`
+}
+
+type SubscribeEvent struct {
+	BroadcasterUserId Id     `json:"broadcaster_user_id"`
+	UserId            Id     `json:"user_id"`
+	UserName          string `json:"user_name"`
+	Tier              string `json:"tier"`
+	IsGift            bool   `json:"is_gift"`
+}
+
+type RaidEvent struct {
+	FromBroadcasterUserId   Id     `json:"from_broadcaster_user_id"`
+	FromBroadcasterUserName string `json:"from_broadcaster_user_name"`
+	ToBroadcasterUserId     Id     `json:"to_broadcaster_user_id"`
+	Viewers                 int    `json:"viewers"`
+}
+
+// Checks that an EventSub message was signed with the subscription's secret
+// and was sent recently.
+func VerifyEventSubMessage(secret string, header http.Header, body []byte, now time.Time) error {
+	id := header.Get(EventSubMessageId)
+	timestamp := header.Get(EventSubMessageTimestamp)
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(id + timestamp))
+	mac.Write(body)
+	expected := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	if !hmac.Equal([]byte(expected), []byte(header.Get(EventSubMessageSignature))) {
+		return ErrInvalidSignature
+	}
+
+	sent, err := time.Parse(time.RFC3339Nano, timestamp)
+	if err != nil || now.Sub(sent) > maxEventSubMessageAge {
+		return ErrStaleMessage
+	}
+
+	return nil
+}
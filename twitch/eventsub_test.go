@@ -0,0 +1,25 @@
+package twitch
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyEventSubMessage(t *testing.T) {
+	// Signature computed independently with: echo -n "message id2024-01-01T12:00:00Z{}" | openssl sha256 -hmac secret
+	header := http.Header{}
+	header.Set(EventSubMessageId, "message id")
+	header.Set(EventSubMessageTimestamp, "2024-01-01T12:00:00Z")
+	header.Set(EventSubMessageSignature, "sha256=4c41840c55c3d0c63845d4497ae088ce75952231aa641651642aab73af9863e9")
+
+	sent := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	body := []byte("{}")
+
+	assert.NoError(t, VerifyEventSubMessage("secret", header, body, sent.Add(time.Minute)))
+	assert.Equal(t, ErrStaleMessage, VerifyEventSubMessage("secret", header, body, sent.Add(time.Hour)))
+	assert.Equal(t, ErrInvalidSignature, VerifyEventSubMessage("other secret", header, body, sent))
+	assert.Equal(t, ErrInvalidSignature, VerifyEventSubMessage("secret", header, []byte("{ }"), sent))
+}
@@ -0,0 +1,306 @@
+package twitch
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/streampets/backend/apperrors"
+)
+
+// The most chatters Get Chatters returns per page.
+const chattersPageSize int = 1000
+
+// How long before it expires an app access token is replaced.
+const tokenExpiryMargin time.Duration = time.Minute
+
+// How many times a rate limited request is retried.
+const maxRateLimitRetries int = 3
+
+// Indicates that Twitch could not be reached or failed to respond.
+var ErrTwitchUnavailable error = apperrors.New(apperrors.Unavailable, "twitch_unavailable", "twitch is unavailable")
+
+// Indicates that a request was still rate limited after being retried.
+var ErrRateLimited error = apperrors.New(apperrors.Unavailable, "twitch_rate_limited", "twitch rate limit exceeded")
+
+// A Twitch user as returned by Get Users.
+type User struct {
+	Id              Id     `json:"id"`
+	Login           string `json:"login"`
+	DisplayName     string `json:"display_name"`
+	ProfileImageUrl string `json:"profile_image_url"`
+}
+
+// A channel as returned by Get Channel Information.
+type ChannelInformation struct {
+	BroadcasterId    Id     `json:"broadcaster_id"`
+	BroadcasterLogin string `json:"broadcaster_login"`
+	BroadcasterName  string `json:"broadcaster_name"`
+	GameName         string `json:"game_name"`
+	Title            string `json:"title"`
+}
+
+// A user connected to a channel's chat as returned by Get Chatters.
+type Chatter struct {
+	UserId    Id     `json:"user_id"`
+	UserLogin string `json:"user_login"`
+	UserName  string `json:"user_name"`
+}
+
+type appToken struct {
+	accessToken string
+	expiresAt   time.Time
+}
+
+type rateLimit struct {
+	remaining int
+	reset     time.Time
+}
+
+// Gets the users with the given ids and logins, at most 100 altogether.
+// Users that do not exist are left out.
+func (t *TwitchApi) GetUsers(ctx context.Context, userIds []Id, logins []string) ([]User, error) {
+	query := url.Values{}
+	for _, userId := range userIds {
+		query.Add("id", string(userId))
+	}
+	for _, login := range logins {
+		query.Add("login", login)
+	}
+
+	var data helixResponse[User]
+	if err := t.helixGet(ctx, "/users", query, "", &data); err != nil {
+		return nil, err
+	}
+	return data.Data, nil
+}
+
+// Gets the channels of the given broadcasters, at most 100.
+func (t *TwitchApi) GetChannelInformation(ctx context.Context, broadcasterIds []Id) ([]ChannelInformation, error) {
+	query := url.Values{}
+	for _, broadcasterId := range broadcasterIds {
+		query.Add("broadcaster_id", string(broadcasterId))
+	}
+
+	var data helixResponse[ChannelInformation]
+	if err := t.helixGet(ctx, "/channels", query, "", &data); err != nil {
+		return nil, err
+	}
+	return data.Data, nil
+}
+
+// Gets everyone connected to the broadcaster's chat, following every page.
+// Twitch only lists chatters to the broadcaster and their moderators, so this
+// needs a user access token with the moderator:read:chatters scope belonging
+// to moderatorId. Returns ErrInvalidUserToken if the token is not valid.
+func (t *TwitchApi) GetChatters(ctx context.Context, broadcasterId, moderatorId Id, accessToken string) ([]Chatter, error) {
+	chatters := []Chatter{}
+	cursor := ""
+
+	for {
+		query := url.Values{}
+		query.Set("broadcaster_id", string(broadcasterId))
+		query.Set("moderator_id", string(moderatorId))
+		query.Set("first", strconv.Itoa(chattersPageSize))
+		if cursor != "" {
+			query.Set("after", cursor)
+		}
+
+		var data helixResponse[Chatter]
+		if err := t.helixGet(ctx, "/chat/chatters", query, accessToken, &data); err != nil {
+			return nil, err
+		}
+
+		chatters = append(chatters, data.Data...)
+		if cursor = data.Pagination.Cursor; cursor == "" {
+			return chatters, nil
+		}
+	}
+}
+
+type helixResponse[T any] struct {
+	Data       []T `json:"data"`
+	Pagination struct {
+		Cursor string `json:"cursor"`
+	} `json:"pagination"`
+}
+
+// Sends a GET request to Helix and unmarshals the response into data.
+// Uses the app access token unless a user access token is given. Waits out
+// the rate limit, and replaces the app access token if Twitch rejects it.
+func (t *TwitchApi) helixGet(ctx context.Context, path string, query url.Values, userToken string, data any) error {
+	refreshed := false
+	retries := 0
+
+	for {
+		accessToken := userToken
+		if accessToken == "" {
+			var err error
+			if accessToken, err = t.appAccessToken(ctx); err != nil {
+				return err
+			}
+		}
+
+		if err := t.waitForRateLimit(ctx); err != nil {
+			return err
+		}
+
+		req, err := http.NewRequestWithContext(ctx, "GET", t.helixUrl+path+"?"+query.Encode(), nil)
+		if err != nil {
+			return err
+		}
+
+		req.Header.Add("Authorization", "Bearer "+accessToken)
+		req.Header.Add("Client-Id", t.clientId)
+
+		response, err := t.client.Do(req)
+		if err != nil {
+			return apperrors.Wrap(err, apperrors.Unavailable, "twitch_unavailable", "twitch is unavailable")
+		}
+
+		t.updateRateLimit(response.Header)
+
+		switch {
+		case response.StatusCode == http.StatusUnauthorized && userToken != "":
+			response.Body.Close()
+			return ErrInvalidUserToken
+		case response.StatusCode == http.StatusUnauthorized && !refreshed:
+			response.Body.Close()
+			t.discardAppToken(accessToken)
+			refreshed = true
+			continue
+		case response.StatusCode == http.StatusTooManyRequests:
+			response.Body.Close()
+			if retries++; retries > maxRateLimitRetries {
+				return ErrRateLimited
+			}
+			continue
+		}
+
+		defer response.Body.Close()
+		if err := checkStatus(response); err != nil {
+			return err
+		}
+		return parseResponse(data, response)
+	}
+}
+
+// Returns the current app access token, getting a new one through the client
+// credentials flow when there is none or it is about to expire.
+func (t *TwitchApi) appAccessToken(ctx context.Context) (string, error) {
+	type tokenResponse struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+
+	t.tokenMu.Lock()
+	defer t.tokenMu.Unlock()
+
+	if t.token.accessToken != "" && t.now().Before(t.token.expiresAt) {
+		return t.token.accessToken, nil
+	}
+
+	form := url.Values{}
+	form.Set("client_id", t.clientId)
+	form.Set("client_secret", t.clientSecret)
+	form.Set("grant_type", "client_credentials")
+
+	req, err := http.NewRequestWithContext(ctx, "POST", t.baseUrl+"/oauth2/token", strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+
+	response, err := t.client.Do(req)
+	if err != nil {
+		return "", apperrors.Wrap(err, apperrors.Unavailable, "twitch_unavailable", "twitch is unavailable")
+	}
+	defer response.Body.Close()
+
+	if err := checkStatus(response); err != nil {
+		return "", err
+	}
+
+	var data tokenResponse
+	if err = parseResponse(&data, response); err != nil {
+		return "", err
+	}
+
+	t.token = appToken{
+		accessToken: data.AccessToken,
+		expiresAt:   t.now().Add(time.Duration(data.ExpiresIn)*time.Second - tokenExpiryMargin),
+	}
+	return t.token.accessToken, nil
+}
+
+// Forgets the app access token unless another request already replaced it.
+func (t *TwitchApi) discardAppToken(accessToken string) {
+	t.tokenMu.Lock()
+	defer t.tokenMu.Unlock()
+
+	if t.token.accessToken == accessToken {
+		t.token = appToken{}
+	}
+}
+
+// Blocks until the rate limit resets if the last response used it up.
+func (t *TwitchApi) waitForRateLimit(ctx context.Context) error {
+	t.limitMu.Lock()
+	limit := t.rateLimit
+	t.limitMu.Unlock()
+
+	if limit.reset.IsZero() || limit.remaining > 0 {
+		return nil
+	}
+
+	wait := limit.reset.Sub(t.now())
+	if wait <= 0 {
+		return nil
+	}
+	return t.sleep(ctx, wait)
+}
+
+// Remembers the rate limit Twitch reported in the Ratelimit-Remaining and
+// Ratelimit-Reset headers, if it reported one.
+func (t *TwitchApi) updateRateLimit(header http.Header) {
+	remaining, err := strconv.Atoi(header.Get("Ratelimit-Remaining"))
+	if err != nil {
+		return
+	}
+
+	reset, err := strconv.ParseInt(header.Get("Ratelimit-Reset"), 10, 64)
+	if err != nil {
+		return
+	}
+
+	t.limitMu.Lock()
+	defer t.limitMu.Unlock()
+	t.rateLimit = rateLimit{remaining: remaining, reset: time.Unix(reset, 0)}
+}
+
+func checkStatus(response *http.Response) error {
+	switch {
+	case response.StatusCode >= 500:
+		return ErrTwitchUnavailable
+	case response.StatusCode >= 300:
+		return fmt.Errorf("twitch responded with status %d", response.StatusCode)
+	}
+	return nil
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
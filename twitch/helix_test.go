@@ -0,0 +1,263 @@
+package twitch
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHelix(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	// Stands in for both the Twitch authentication server and Helix. Token
+	// requests are counted and each one is answered with a new token.
+	setUpServer := func(helix http.HandlerFunc) (*httptest.Server, *atomic.Int32) {
+		var tokens atomic.Int32
+
+		mux := http.NewServeMux()
+		mux.HandleFunc("POST /oauth2/token", func(w http.ResponseWriter, r *http.Request) {
+			if r.FormValue("client_id") != "client id" ||
+				r.FormValue("client_secret") != "client secret" ||
+				r.FormValue("grant_type") != "client_credentials" {
+				http.Error(w, "invalid client", http.StatusBadRequest)
+				return
+			}
+			n := tokens.Add(1)
+			fmt.Fprintf(w, `{"access_token":"token %d","expires_in":3600,"token_type":"bearer"}`, n)
+		})
+		mux.HandleFunc("/helix/", func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Client-Id") != "client id" {
+				http.Error(w, "no client id", http.StatusUnauthorized)
+				return
+			}
+			helix(w, r)
+		})
+
+		server := httptest.NewServer(mux)
+		t.Cleanup(server.Close)
+		return server, &tokens
+	}
+
+	setUpApi := func(server *httptest.Server) *TwitchApi {
+		api := New(&http.Client{}, server.URL, server.URL+"/helix", "client id", "client secret")
+		api.now = func() time.Time { return now }
+		return api
+	}
+
+	t.Run("users retrieved with app access token", func(t *testing.T) {
+		server, tokens := setUpServer(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "Bearer token 1" {
+				http.Error(w, "invalid token", http.StatusUnauthorized)
+				return
+			}
+			assert.Equal(t, []string{"1"}, r.URL.Query()["id"])
+			assert.Equal(t, []string{"login"}, r.URL.Query()["login"])
+			fmt.Fprintln(w, `{"data":[{"id":"1","login":"one","display_name":"One","profile_image_url":"image"},{"id":"2","login":"login","display_name":"Login"}]}`)
+		})
+
+		api := setUpApi(server)
+		ctx := context.Background()
+
+		users, err := api.GetUsers(ctx, []Id{"1"}, []string{"login"})
+		assert.NoError(t, err)
+		assert.Equal(t, []User{
+			{Id: "1", Login: "one", DisplayName: "One", ProfileImageUrl: "image"},
+			{Id: "2", Login: "login", DisplayName: "Login"},
+		}, users)
+
+		_, err = api.GetUsers(ctx, []Id{"1"}, []string{"login"})
+		assert.NoError(t, err)
+		assert.Equal(t, int32(1), tokens.Load())
+	})
+
+	t.Run("app access token replaced when about to expire", func(t *testing.T) {
+		server, tokens := setUpServer(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintln(w, `{"data":[]}`)
+		})
+
+		clock := now
+		api := setUpApi(server)
+		api.now = func() time.Time { return clock }
+		ctx := context.Background()
+
+		_, err := api.GetUsers(ctx, []Id{"1"}, nil)
+		assert.NoError(t, err)
+
+		clock = clock.Add(time.Hour - tokenExpiryMargin)
+		_, err = api.GetUsers(ctx, []Id{"1"}, nil)
+		assert.NoError(t, err)
+
+		assert.Equal(t, int32(2), tokens.Load())
+	})
+
+	t.Run("app access token replaced when rejected", func(t *testing.T) {
+		server, tokens := setUpServer(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "Bearer token 2" {
+				http.Error(w, "invalid token", http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprintln(w, `{"data":[{"id":"1"}]}`)
+		})
+
+		api := setUpApi(server)
+
+		users, err := api.GetUsers(context.Background(), []Id{"1"}, nil)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []User{{Id: "1"}}, users)
+		assert.Equal(t, int32(2), tokens.Load())
+	})
+
+	t.Run("channel information retrieved", func(t *testing.T) {
+		server, _ := setUpServer(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/helix/channels", r.URL.Path)
+			assert.Equal(t, []string{"1", "2"}, r.URL.Query()["broadcaster_id"])
+			fmt.Fprintln(w, `{"data":[{"broadcaster_id":"1","broadcaster_login":"one","broadcaster_name":"One","game_name":"game","title":"title"}]}`)
+		})
+
+		api := setUpApi(server)
+
+		channels, err := api.GetChannelInformation(context.Background(), []Id{"1", "2"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, []ChannelInformation{{
+			BroadcasterId:    "1",
+			BroadcasterLogin: "one",
+			BroadcasterName:  "One",
+			GameName:         "game",
+			Title:            "title",
+		}}, channels)
+	})
+
+	t.Run("every page of chatters retrieved with user access token", func(t *testing.T) {
+		server, tokens := setUpServer(func(w http.ResponseWriter, r *http.Request) {
+			query := r.URL.Query()
+			if r.Header.Get("Authorization") != "Bearer user token" {
+				http.Error(w, "invalid token", http.StatusUnauthorized)
+				return
+			}
+			assert.Equal(t, "broadcaster id", query.Get("broadcaster_id"))
+			assert.Equal(t, "moderator id", query.Get("moderator_id"))
+
+			switch query.Get("after") {
+			case "":
+				fmt.Fprintln(w, `{"data":[{"user_id":"1","user_login":"one","user_name":"One"}],"pagination":{"cursor":"next"}}`)
+			case "next":
+				fmt.Fprintln(w, `{"data":[{"user_id":"2","user_login":"two","user_name":"Two"}],"pagination":{}}`)
+			}
+		})
+
+		api := setUpApi(server)
+
+		chatters, err := api.GetChatters(context.Background(), "broadcaster id", "moderator id", "user token")
+
+		assert.NoError(t, err)
+		assert.Equal(t, []Chatter{
+			{UserId: "1", UserLogin: "one", UserName: "One"},
+			{UserId: "2", UserLogin: "two", UserName: "Two"},
+		}, chatters)
+		assert.Equal(t, int32(0), tokens.Load())
+	})
+
+	t.Run("invalid user token error when chatters unauthorized", func(t *testing.T) {
+		server, _ := setUpServer(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "invalid token", http.StatusUnauthorized)
+		})
+
+		api := setUpApi(server)
+
+		_, err := api.GetChatters(context.Background(), "broadcaster id", "moderator id", "user token")
+
+		assert.Equal(t, ErrInvalidUserToken, err)
+	})
+
+	t.Run("waits for rate limit to reset when used up", func(t *testing.T) {
+		reset := now.Add(5 * time.Second)
+		var requests atomic.Int32
+
+		server, _ := setUpServer(func(w http.ResponseWriter, r *http.Request) {
+			remaining := 0
+			if requests.Add(1) > 1 {
+				remaining = 10
+			}
+			w.Header().Set("Ratelimit-Remaining", strconv.Itoa(remaining))
+			w.Header().Set("Ratelimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+			fmt.Fprintln(w, `{"data":[]}`)
+		})
+
+		api := setUpApi(server)
+		var waits []time.Duration
+		api.sleep = func(ctx context.Context, d time.Duration) error {
+			waits = append(waits, d)
+			return nil
+		}
+
+		ctx := context.Background()
+		for range 3 {
+			_, err := api.GetUsers(ctx, []Id{"1"}, nil)
+			assert.NoError(t, err)
+		}
+
+		assert.Equal(t, []time.Duration{5 * time.Second}, waits)
+	})
+
+	t.Run("rate limited request retried after reset", func(t *testing.T) {
+		reset := now.Add(time.Second)
+		var requests atomic.Int32
+
+		server, _ := setUpServer(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Ratelimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+			if requests.Add(1) == 1 {
+				w.Header().Set("Ratelimit-Remaining", "0")
+				http.Error(w, "too many requests", http.StatusTooManyRequests)
+				return
+			}
+			w.Header().Set("Ratelimit-Remaining", "10")
+			fmt.Fprintln(w, `{"data":[{"id":"1"}]}`)
+		})
+
+		api := setUpApi(server)
+		var waits []time.Duration
+		api.sleep = func(ctx context.Context, d time.Duration) error {
+			waits = append(waits, d)
+			return nil
+		}
+
+		users, err := api.GetUsers(context.Background(), []Id{"1"}, nil)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []User{{Id: "1"}}, users)
+		assert.Equal(t, []time.Duration{time.Second}, waits)
+	})
+
+	t.Run("rate limited error when retries run out", func(t *testing.T) {
+		server, _ := setUpServer(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "too many requests", http.StatusTooManyRequests)
+		})
+
+		api := setUpApi(server)
+
+		_, err := api.GetUsers(context.Background(), []Id{"1"}, nil)
+
+		assert.Equal(t, ErrRateLimited, err)
+	})
+
+	t.Run("unavailable error when twitch fails", func(t *testing.T) {
+		server, _ := setUpServer(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+		})
+
+		api := setUpApi(server)
+
+		_, err := api.GetChannelInformation(context.Background(), []Id{"1"})
+
+		assert.Equal(t, ErrTwitchUnavailable, err)
+	})
+}
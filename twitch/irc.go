@@ -0,0 +1,304 @@
+package twitch
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"strings"
+	"time"
+)
+
+// The address of Twitch's IRC server, which expects TLS.
+const ChatAddress string = "irc.chat.twitch.tv:6697"
+
+// At most this many chatters' ids are remembered. Twitch stops sending PARTs
+// for busy channels, so not every chatter is forgotten when they leave.
+const maxChatUsers = 10000
+
+var ErrChatAuthFailed = errors.New("chat login authentication failed")
+
+// Twitch sends RECONNECT before restarting a server and expects clients to
+// reconnect straight away.
+var errChatReconnect = errors.New("server asked to reconnect")
+
+// Opens a connection to an IRC server.
+type DialFunc func(ctx context.Context) (net.Conn, error)
+
+// A channel the chat client is in. Id is empty until Twitch has sent it.
+type ChatChannel struct {
+	Login string
+	Id    Id
+}
+
+// A user in a channel's chat. Twitch only sends user ids with messages, so Id
+// is empty for users that have not chatted since the client connected.
+type ChatUser struct {
+	Login       string
+	Id          Id
+	DisplayName string
+}
+
+// Receives what happens in the channels the chat client is in.
+// Handlers are called one at a time, in the order the events arrive.
+type ChatHandler interface {
+	HandleChatJoin(channel ChatChannel, user ChatUser)
+	HandleChatPart(channel ChatChannel, user ChatUser)
+	HandleChatMessage(channel ChatChannel, user ChatUser, text string)
+}
+
+// A struct used to read chat from Twitch IRC.
+type ChatClient struct {
+	dial     DialFunc
+	login    string
+	token    string
+	channels []string
+	handler  ChatHandler
+
+	// Learned from ROOMSTATE and message tags, keyed by login name.
+	channelIds map[string]Id
+	users      map[chatter]ChatUser
+}
+
+// A user in a channel, by login name.
+type chatter struct {
+	channel string
+	user    string
+}
+
+// Creates a chat client which logs in as login using an OAuth token with the
+// chat:read scope and joins the given channels.
+func NewChatClient(
+	dial DialFunc,
+	login string,
+	token string,
+	channels []string,
+	handler ChatHandler,
+) *ChatClient {
+	return &ChatClient{
+		dial:       dial,
+		login:      strings.ToLower(login),
+		token:      token,
+		channels:   channels,
+		handler:    handler,
+		channelIds: make(map[string]Id),
+		users:      make(map[chatter]ChatUser),
+	}
+}
+
+// Reads chat until ctx is done, reconnecting with a backoff when the
+// connection drops. Returns early only if authentication fails.
+func (c *ChatClient) Run(ctx context.Context) error {
+	backoff := time.Second
+	for {
+		connected, err := c.serve(ctx)
+		if err == ErrChatAuthFailed {
+			return err
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err == errChatReconnect {
+			continue
+		}
+
+		if connected {
+			backoff = time.Second
+		}
+		slog.Warn("chat connection lost", "err", err, "retry_in", backoff)
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return nil
+		}
+		backoff = min(backoff*2, time.Minute)
+	}
+}
+
+// Handles a single connection. Returns whether it got as far as logging in.
+func (c *ChatClient) serve(ctx context.Context) (bool, error) {
+	conn, err := c.dial(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	// Unblock the read below when ctx is done.
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	lines := []string{
+		fmt.Sprintf("PASS oauth:%s", strings.TrimPrefix(c.token, "oauth:")),
+		fmt.Sprintf("NICK %s", c.login),
+		"CAP REQ :twitch.tv/membership twitch.tv/tags twitch.tv/commands",
+	}
+	for _, channel := range c.channels {
+		lines = append(lines, fmt.Sprintf("JOIN #%s", strings.ToLower(channel)))
+	}
+	if err := writeLines(conn, lines...); err != nil {
+		return false, err
+	}
+
+	loggedIn := false
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		message := parseIRCMessage(scanner.Text())
+
+		switch message.command {
+		case "001":
+			loggedIn = true
+		case "PING":
+			if err := writeLines(conn, "PONG :"+message.param(0)); err != nil {
+				return loggedIn, err
+			}
+		case "RECONNECT":
+			return loggedIn, errChatReconnect
+		case "NOTICE":
+			if !loggedIn && strings.Contains(message.param(1), "authentication failed") {
+				return false, ErrChatAuthFailed
+			}
+		default:
+			c.handle(message)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return loggedIn, err
+	}
+	return loggedIn, errors.New("connection closed")
+}
+
+func (c *ChatClient) handle(message ircMessage) {
+	channelLogin := strings.TrimPrefix(message.param(0), "#")
+
+	switch message.command {
+	case "ROOMSTATE":
+		if roomId := message.tags["room-id"]; roomId != "" {
+			c.channelIds[channelLogin] = Id(roomId)
+		}
+	case "JOIN":
+		if user := c.user(channelLogin, message.login()); user.Login != c.login {
+			c.handler.HandleChatJoin(c.channel(channelLogin), user)
+		}
+	case "PART":
+		user := c.user(channelLogin, message.login())
+		delete(c.users, chatter{channel: channelLogin, user: user.Login})
+		if user.Login != c.login {
+			c.handler.HandleChatPart(c.channel(channelLogin), user)
+		}
+	case "PRIVMSG":
+		if roomId := message.tags["room-id"]; roomId != "" {
+			c.channelIds[channelLogin] = Id(roomId)
+		}
+
+		user := ChatUser{
+			Login:       message.login(),
+			Id:          Id(message.tags["user-id"]),
+			DisplayName: message.tags["display-name"],
+		}
+		if user.DisplayName == "" {
+			user.DisplayName = user.Login
+		}
+		if user.Id != "" {
+			c.remember(channelLogin, user)
+		}
+
+		c.handler.HandleChatMessage(c.channel(channelLogin), user, message.param(1))
+	}
+}
+
+func (c *ChatClient) channel(login string) ChatChannel {
+	return ChatChannel{Login: login, Id: c.channelIds[login]}
+}
+
+func (c *ChatClient) user(channelLogin, login string) ChatUser {
+	if user, ok := c.users[chatter{channel: channelLogin, user: login}]; ok {
+		return user
+	}
+	return ChatUser{Login: login, DisplayName: login}
+}
+
+// Remembers the user's id until they part, making room by forgetting an
+// arbitrary chatter when there are too many.
+func (c *ChatClient) remember(channelLogin string, user ChatUser) {
+	key := chatter{channel: channelLogin, user: user.Login}
+	if _, ok := c.users[key]; !ok && len(c.users) >= maxChatUsers {
+		for forgotten := range c.users {
+			delete(c.users, forgotten)
+			break
+		}
+	}
+	c.users[key] = user
+}
+
+func writeLines(conn net.Conn, lines ...string) error {
+	for _, line := range lines {
+		if _, err := conn.Write([]byte(line + "\r\n")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// A line received from an IRC server, such as
+//
+//	@user-id=123;display-name=Viewer :viewer!viewer@viewer.tmi.twitch.tv PRIVMSG #streamer :!jump
+type ircMessage struct {
+	tags    map[string]string
+	prefix  string
+	command string
+	params  []string
+}
+
+func parseIRCMessage(line string) ircMessage {
+	message := ircMessage{tags: make(map[string]string)}
+	line = strings.TrimRight(line, "\r\n")
+
+	if strings.HasPrefix(line, "@") {
+		var tags string
+		tags, line, _ = strings.Cut(line[1:], " ")
+		for _, tag := range strings.Split(tags, ";") {
+			key, value, _ := strings.Cut(tag, "=")
+			message.tags[key] = unescapeTagValue(value)
+		}
+	}
+
+	if strings.HasPrefix(line, ":") {
+		message.prefix, line, _ = strings.Cut(line[1:], " ")
+	}
+
+	line, trailing, hasTrailing := strings.Cut(line, " :")
+	fields := strings.Fields(line)
+	if len(fields) > 0 {
+		message.command = fields[0]
+		message.params = fields[1:]
+	}
+	if hasTrailing {
+		message.params = append(message.params, trailing)
+	}
+
+	return message
+}
+
+// Returns the parameter at index i, or an empty string if there is none.
+func (m ircMessage) param(i int) string {
+	if i < len(m.params) {
+		return m.params[i]
+	}
+	return ""
+}
+
+// Returns the nick from a prefix such as "viewer!viewer@viewer.tmi.twitch.tv".
+func (m ircMessage) login() string {
+	login, _, _ := strings.Cut(m.prefix, "!")
+	return login
+}
+
+var tagValueReplacer = strings.NewReplacer(`\:`, ";", `\s`, " ", `\\`, `\`, `\r`, "\r", `\n`, "\n")
+
+func unescapeTagValue(value string) string {
+	return tagValueReplacer.Replace(value)
+}
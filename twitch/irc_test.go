@@ -0,0 +1,202 @@
+package twitch
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type chatEvent struct {
+	kind    string
+	channel ChatChannel
+	user    ChatUser
+	text    string
+}
+
+type chatRecorder struct {
+	events chan chatEvent
+}
+
+func (r *chatRecorder) HandleChatJoin(channel ChatChannel, user ChatUser) {
+	r.events <- chatEvent{kind: "join", channel: channel, user: user}
+}
+
+func (r *chatRecorder) HandleChatPart(channel ChatChannel, user ChatUser) {
+	r.events <- chatEvent{kind: "part", channel: channel, user: user}
+}
+
+func (r *chatRecorder) HandleChatMessage(channel ChatChannel, user ChatUser, text string) {
+	r.events <- chatEvent{kind: "message", channel: channel, user: user, text: text}
+}
+
+// A stand-in for Twitch IRC which hands each connection to the test.
+func startFakeIRCServer(t *testing.T) (DialFunc, chan net.Conn) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	conns := make(chan net.Conn, 2)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conns <- conn
+		}
+	}()
+
+	dial := func(ctx context.Context) (net.Conn, error) {
+		var dialer net.Dialer
+		return dialer.DialContext(ctx, "tcp", listener.Addr().String())
+	}
+	return dial, conns
+}
+
+func acceptConn(t *testing.T, conns chan net.Conn) (net.Conn, *bufio.Reader) {
+	select {
+	case conn := <-conns:
+		t.Cleanup(func() { conn.Close() })
+		return conn, bufio.NewReader(conn)
+	case <-time.After(time.Second):
+		t.Fatal("client did not connect")
+		return nil, nil
+	}
+}
+
+func readLine(t *testing.T, reader *bufio.Reader) string {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.TrimRight(line, "\r\n")
+}
+
+func nextEvent(t *testing.T, events chan chatEvent) chatEvent {
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(time.Second):
+		t.Fatal("no chat event received")
+		return chatEvent{}
+	}
+}
+
+func TestChatClient(t *testing.T) {
+	t.Run("logs in, joins channels and reports chat", func(t *testing.T) {
+		dial, conns := startFakeIRCServer(t)
+		recorder := &chatRecorder{events: make(chan chatEvent, 10)}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		client := NewChatClient(dial, "PetBot", "oauth:token", []string{"Streamer"}, recorder)
+		done := make(chan error)
+		go func() { done <- client.Run(ctx) }()
+
+		conn, reader := acceptConn(t, conns)
+		assert.Equal(t, "PASS oauth:token", readLine(t, reader))
+		assert.Equal(t, "NICK petbot", readLine(t, reader))
+		assert.Equal(t, "CAP REQ :twitch.tv/membership twitch.tv/tags twitch.tv/commands", readLine(t, reader))
+		assert.Equal(t, "JOIN #streamer", readLine(t, reader))
+
+		writeLines(conn,
+			":tmi.twitch.tv 001 petbot :Welcome, GLHF!",
+			":petbot!petbot@petbot.tmi.twitch.tv JOIN #streamer",
+			"@emote-only=0;room-id=1337;slow=0 :tmi.twitch.tv ROOMSTATE #streamer",
+			":viewer!viewer@viewer.tmi.twitch.tv JOIN #streamer",
+			`@display-name=Viewer;room-id=1337;user-id=1234;badge-info= :viewer!viewer@viewer.tmi.twitch.tv PRIVMSG #streamer :!color red fire`,
+			"PING :tmi.twitch.tv",
+			":viewer!viewer@viewer.tmi.twitch.tv PART #streamer",
+		)
+
+		channel := ChatChannel{Login: "streamer", Id: "1337"}
+		viewer := ChatUser{Login: "viewer", Id: "1234", DisplayName: "Viewer"}
+
+		assert.Equal(t, chatEvent{kind: "join", channel: channel, user: ChatUser{Login: "viewer", DisplayName: "viewer"}}, nextEvent(t, recorder.events))
+		assert.Equal(t, chatEvent{kind: "message", channel: channel, user: viewer, text: "!color red fire"}, nextEvent(t, recorder.events))
+		assert.Equal(t, "PONG :tmi.twitch.tv", readLine(t, reader))
+		assert.Equal(t, chatEvent{kind: "part", channel: channel, user: viewer}, nextEvent(t, recorder.events))
+		assert.Empty(t, client.users)
+
+		cancel()
+		select {
+		case err := <-done:
+			assert.NoError(t, err)
+		case <-time.After(time.Second):
+			t.Fatal("client did not stop")
+		}
+	})
+
+	t.Run("reconnects when asked to", func(t *testing.T) {
+		dial, conns := startFakeIRCServer(t)
+		recorder := &chatRecorder{events: make(chan chatEvent, 10)}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		client := NewChatClient(dial, "petbot", "token", []string{"streamer"}, recorder)
+		go client.Run(ctx)
+
+		conn, reader := acceptConn(t, conns)
+		assert.Equal(t, "PASS oauth:token", readLine(t, reader))
+		writeLines(conn, ":tmi.twitch.tv 001 petbot :Welcome, GLHF!", ":tmi.twitch.tv RECONNECT")
+
+		_, reader = acceptConn(t, conns)
+		assert.Equal(t, "PASS oauth:token", readLine(t, reader))
+	})
+
+	t.Run("stops when authentication fails", func(t *testing.T) {
+		dial, conns := startFakeIRCServer(t)
+		recorder := &chatRecorder{events: make(chan chatEvent, 10)}
+
+		client := NewChatClient(dial, "petbot", "wrong", []string{"streamer"}, recorder)
+		done := make(chan error)
+		go func() { done <- client.Run(context.Background()) }()
+
+		conn, _ := acceptConn(t, conns)
+		writeLines(conn, ":tmi.twitch.tv NOTICE * :Login authentication failed")
+
+		select {
+		case err := <-done:
+			assert.Equal(t, ErrChatAuthFailed, err)
+		case <-time.After(time.Second):
+			t.Fatal("client did not stop")
+		}
+	})
+}
+
+func TestChatClientRemember(t *testing.T) {
+	client := NewChatClient(nil, "petbot", "token", nil, nil)
+
+	for i := range maxChatUsers + 1 {
+		login := fmt.Sprintf("viewer%d", i)
+		client.remember("streamer", ChatUser{Login: login, Id: Id(login)})
+	}
+	assert.Len(t, client.users, maxChatUsers)
+
+	client.remember("other", ChatUser{Login: "viewer", Id: "1234"})
+	assert.Equal(t, ChatUser{Login: "viewer", DisplayName: "viewer"}, client.user("streamer", "viewer"))
+	assert.Equal(t, ChatUser{Login: "viewer", Id: "1234"}, client.user("other", "viewer"))
+}
+
+func TestParseIRCMessage(t *testing.T) {
+	message := parseIRCMessage(`@display-name=Cool\sUser;user-id=1234 :cool!cool@cool.tmi.twitch.tv PRIVMSG #streamer :hello there` + "\r\n")
+
+	assert.Equal(t, map[string]string{"display-name": "Cool User", "user-id": "1234"}, message.tags)
+	assert.Equal(t, "cool", message.login())
+	assert.Equal(t, "PRIVMSG", message.command)
+	assert.Equal(t, []string{"#streamer", "hello there"}, message.params)
+
+	ping := parseIRCMessage("PING :tmi.twitch.tv")
+	assert.Equal(t, "PING", ping.command)
+	assert.Equal(t, "tmi.twitch.tv", ping.param(0))
+}
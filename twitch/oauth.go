@@ -0,0 +1,110 @@
+package twitch
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/streampets/backend/apperrors"
+)
+
+// Indicates that Twitch did not accept an authorization code.
+var ErrInvalidAuthorizationCode error = apperrors.New(apperrors.Unauthorized, "invalid_authorization_code", "authorization code is not valid")
+
+// Indicates that Twitch did not accept a refresh token, so the user has to log in again.
+var ErrInvalidRefreshToken error = apperrors.New(apperrors.Unauthorized, "invalid_refresh_token", "refresh token is not valid")
+
+// The tokens Twitch issues to a user who logged in.
+type UserToken struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresAt    time.Time
+	Scopes       []string
+}
+
+// Returns the Twitch page that asks the user to authorize the client. Twitch
+// sends the user back to redirectUri with the state and an authorization code.
+func (t *TwitchApi) AuthorizeUrl(redirectUri, state, codeChallenge string, scopes []string) string {
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", t.clientId)
+	query.Set("redirect_uri", redirectUri)
+	query.Set("scope", strings.Join(scopes, " "))
+	query.Set("state", state)
+	query.Set("code_challenge", codeChallenge)
+	query.Set("code_challenge_method", "S256")
+
+	return t.baseUrl + "/oauth2/authorize?" + query.Encode()
+}
+
+// Exchanges an authorization code for the user's tokens.
+// Returns ErrInvalidAuthorizationCode if Twitch rejects the code.
+func (t *TwitchApi) ExchangeCode(ctx context.Context, code, redirectUri, codeVerifier string) (UserToken, error) {
+	form := url.Values{}
+	form.Set("code", code)
+	form.Set("grant_type", "authorization_code")
+	form.Set("redirect_uri", redirectUri)
+	form.Set("code_verifier", codeVerifier)
+
+	return t.requestUserToken(ctx, form, ErrInvalidAuthorizationCode)
+}
+
+// Gets new tokens for the user once their access token has expired. Twitch
+// may rotate the refresh token too, so both have to be stored again.
+// Returns ErrInvalidRefreshToken if Twitch rejects the refresh token.
+func (t *TwitchApi) RefreshToken(ctx context.Context, refreshToken string) (UserToken, error) {
+	form := url.Values{}
+	form.Set("refresh_token", refreshToken)
+	form.Set("grant_type", "refresh_token")
+
+	return t.requestUserToken(ctx, form, ErrInvalidRefreshToken)
+}
+
+// Posts the form to the token endpoint and returns the tokens Twitch issued.
+// Returns rejected if Twitch refuses the grant.
+func (t *TwitchApi) requestUserToken(ctx context.Context, form url.Values, rejected error) (UserToken, error) {
+	type tokenResponse struct {
+		AccessToken  string   `json:"access_token"`
+		RefreshToken string   `json:"refresh_token"`
+		ExpiresIn    int      `json:"expires_in"`
+		Scope        []string `json:"scope"`
+	}
+
+	form.Set("client_id", t.clientId)
+	form.Set("client_secret", t.clientSecret)
+
+	req, err := http.NewRequestWithContext(ctx, "POST", t.baseUrl+"/oauth2/token", strings.NewReader(form.Encode()))
+	if err != nil {
+		return UserToken{}, err
+	}
+
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+
+	response, err := t.client.Do(req)
+	if err != nil {
+		return UserToken{}, apperrors.Wrap(err, apperrors.Unavailable, "twitch_unavailable", "twitch is unavailable")
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode == http.StatusBadRequest || response.StatusCode == http.StatusUnauthorized {
+		return UserToken{}, rejected
+	}
+
+	if err := checkStatus(response); err != nil {
+		return UserToken{}, err
+	}
+
+	var data tokenResponse
+	if err = parseResponse(&data, response); err != nil {
+		return UserToken{}, err
+	}
+
+	return UserToken{
+		AccessToken:  data.AccessToken,
+		RefreshToken: data.RefreshToken,
+		ExpiresAt:    t.now().Add(time.Duration(data.ExpiresIn) * time.Second),
+		Scopes:       data.Scope,
+	}, nil
+}
@@ -0,0 +1,129 @@
+package twitch
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthorizeUrl(t *testing.T) {
+	api := New(&http.Client{}, "https://id.twitch.tv", "https://api.twitch.tv/helix", "client id", "client secret")
+
+	authorizeUrl, err := url.Parse(api.AuthorizeUrl("https://backend/callback", "state", "challenge", []string{"a", "b"}))
+
+	assert.NoError(t, err)
+	assert.Equal(t, "https://id.twitch.tv/oauth2/authorize", authorizeUrl.Scheme+"://"+authorizeUrl.Host+authorizeUrl.Path)
+	assert.Equal(t, url.Values{
+		"response_type":         {"code"},
+		"client_id":             {"client id"},
+		"redirect_uri":          {"https://backend/callback"},
+		"scope":                 {"a b"},
+		"state":                 {"state"},
+		"code_challenge":        {"challenge"},
+		"code_challenge_method": {"S256"},
+	}, authorizeUrl.Query())
+}
+
+func TestExchangeCode(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	setUpApi := func(handler http.HandlerFunc) *TwitchApi {
+		server := httptest.NewServer(handler)
+		t.Cleanup(server.Close)
+
+		api := New(&http.Client{}, server.URL, server.URL+"/helix", "client id", "client secret")
+		api.now = func() time.Time { return now }
+		return api
+	}
+
+	t.Run("user token returned when code valid", func(t *testing.T) {
+		api := setUpApi(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/oauth2/token", r.URL.Path)
+			assert.Equal(t, "client id", r.FormValue("client_id"))
+			assert.Equal(t, "client secret", r.FormValue("client_secret"))
+			assert.Equal(t, "authorization_code", r.FormValue("grant_type"))
+			assert.Equal(t, "https://backend/callback", r.FormValue("redirect_uri"))
+			assert.Equal(t, "verifier", r.FormValue("code_verifier"))
+
+			if r.FormValue("code") != "code" {
+				http.Error(w, `{"status":400,"message":"Invalid authorization code"}`, http.StatusBadRequest)
+				return
+			}
+			fmt.Fprintln(w, `{"access_token":"access","refresh_token":"refresh","expires_in":3600,"scope":["a"],"token_type":"bearer"}`)
+		})
+
+		token, err := api.ExchangeCode(context.Background(), "code", "https://backend/callback", "verifier")
+
+		assert.NoError(t, err)
+		assert.Equal(t, UserToken{
+			AccessToken:  "access",
+			RefreshToken: "refresh",
+			ExpiresAt:    now.Add(time.Hour),
+			Scopes:       []string{"a"},
+		}, token)
+	})
+
+	t.Run("invalid authorization code error when code rejected", func(t *testing.T) {
+		api := setUpApi(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, `{"status":400,"message":"Invalid authorization code"}`, http.StatusBadRequest)
+		})
+
+		_, err := api.ExchangeCode(context.Background(), "code", "https://backend/callback", "verifier")
+
+		assert.Equal(t, ErrInvalidAuthorizationCode, err)
+	})
+}
+
+func TestRefreshToken(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	setUpApi := func(handler http.HandlerFunc) *TwitchApi {
+		server := httptest.NewServer(handler)
+		t.Cleanup(server.Close)
+
+		api := New(&http.Client{}, server.URL, server.URL+"/helix", "client id", "client secret")
+		api.now = func() time.Time { return now }
+		return api
+	}
+
+	t.Run("new user token returned when refresh token valid", func(t *testing.T) {
+		api := setUpApi(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/oauth2/token", r.URL.Path)
+			assert.Equal(t, "client id", r.FormValue("client_id"))
+			assert.Equal(t, "client secret", r.FormValue("client_secret"))
+			assert.Equal(t, "refresh_token", r.FormValue("grant_type"))
+
+			if r.FormValue("refresh_token") != "refresh" {
+				http.Error(w, `{"status":400,"message":"Invalid refresh token"}`, http.StatusBadRequest)
+				return
+			}
+			fmt.Fprintln(w, `{"access_token":"new access","refresh_token":"new refresh","expires_in":3600,"scope":["a"],"token_type":"bearer"}`)
+		})
+
+		token, err := api.RefreshToken(context.Background(), "refresh")
+
+		assert.NoError(t, err)
+		assert.Equal(t, UserToken{
+			AccessToken:  "new access",
+			RefreshToken: "new refresh",
+			ExpiresAt:    now.Add(time.Hour),
+			Scopes:       []string{"a"},
+		}, token)
+	})
+
+	t.Run("invalid refresh token error when refresh token rejected", func(t *testing.T) {
+		api := setUpApi(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, `{"status":400,"message":"Invalid refresh token"}`, http.StatusBadRequest)
+		})
+
+		_, err := api.RefreshToken(context.Background(), "refresh")
+
+		assert.Equal(t, ErrInvalidRefreshToken, err)
+	})
+}
@@ -0,0 +1,130 @@
+package twitch
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+type tokenValidator interface {
+	ValidateToken(ctx context.Context, accessToken string) (Id, error)
+}
+
+type cachedValidation struct {
+	userId    Id
+	err       error
+	expiresAt time.Time
+}
+
+// Remembers which user an access token belongs to so that not every request
+// has to ask Twitch. Twitch wants tokens revalidated every hour, so ttl should
+// stay well below that. Tokens Twitch rejected are remembered for negativeTtl.
+type CachedTokenValidator struct {
+	validator   tokenValidator
+	ttl         time.Duration
+	negativeTtl time.Duration
+
+	group singleflight.Group
+
+	mu        sync.Mutex
+	tokens    map[string]cachedValidation
+	lastPrune time.Time
+
+	now func() time.Time
+}
+
+func NewCachedTokenValidator(
+	validator tokenValidator,
+	ttl time.Duration,
+	negativeTtl time.Duration,
+) *CachedTokenValidator {
+	return &CachedTokenValidator{
+		validator:   validator,
+		ttl:         ttl,
+		negativeTtl: negativeTtl,
+		tokens:      map[string]cachedValidation{},
+		now:         time.Now,
+	}
+}
+
+// Validates the token like TwitchApi.ValidateToken. Concurrent calls for the
+// same token share a single request to Twitch.
+func (v *CachedTokenValidator) ValidateToken(ctx context.Context, accessToken string) (Id, error) {
+	key := hashToken(accessToken)
+
+	if cached, ok := v.lookup(key); ok {
+		return cached.userId, cached.err
+	}
+
+	result, err, _ := v.group.Do(key, func() (interface{}, error) {
+		// Callers share the request, so one of them going away should not fail the others.
+		userId, err := v.validator.ValidateToken(context.WithoutCancel(ctx), accessToken)
+		v.store(key, userId, err)
+		return userId, err
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return result.(Id), nil
+}
+
+func (v *CachedTokenValidator) lookup(key string) (cachedValidation, bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	cached, ok := v.tokens[key]
+	if !ok || !v.now().Before(cached.expiresAt) {
+		return cachedValidation{}, false
+	}
+	return cached, true
+}
+
+// Remembers the result unless the validation failed for some reason other than
+// Twitch rejecting the token, which is worth retrying straight away. Tokens are
+// never remembered as valid without a user id.
+func (v *CachedTokenValidator) store(key string, userId Id, err error) {
+	var ttl time.Duration
+	switch {
+	case err == nil && userId == "":
+		return
+	case err == nil:
+		ttl = v.ttl
+	case errors.Is(err, ErrInvalidUserToken):
+		ttl = v.negativeTtl
+	default:
+		return
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	now := v.now()
+	v.prune(now)
+	v.tokens[key] = cachedValidation{userId: userId, err: err, expiresAt: now.Add(ttl)}
+}
+
+// Forgets expired tokens, at most once per ttl so storing stays cheap.
+func (v *CachedTokenValidator) prune(now time.Time) {
+	if now.Sub(v.lastPrune) < v.ttl {
+		return
+	}
+	v.lastPrune = now
+
+	for key, cached := range v.tokens {
+		if !now.Before(cached.expiresAt) {
+			delete(v.tokens, key)
+		}
+	}
+}
+
+// Tokens are only kept as hashes so a memory dump does not leak them.
+func hashToken(accessToken string) string {
+	hash := sha256.Sum256([]byte(accessToken))
+	return hex.EncodeToString(hash[:])
+}
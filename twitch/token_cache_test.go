@@ -0,0 +1,151 @@
+package twitch
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeValidator struct {
+	userId  Id
+	err     error
+	calls   atomic.Int32
+	started chan struct{}
+	release chan struct{}
+}
+
+func (f *fakeValidator) ValidateToken(ctx context.Context, accessToken string) (Id, error) {
+	f.calls.Add(1)
+	if f.started != nil {
+		f.started <- struct{}{}
+		<-f.release
+	}
+	return f.userId, f.err
+}
+
+func TestCachedTokenValidator(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	ctx := context.Background()
+
+	setUpValidator := func(fake *fakeValidator) (*CachedTokenValidator, *time.Time) {
+		clock := now
+		validator := NewCachedTokenValidator(fake, 10*time.Minute, 30*time.Second)
+		validator.now = func() time.Time { return clock }
+		return validator, &clock
+	}
+
+	t.Run("user id cached until ttl passes", func(t *testing.T) {
+		fake := &fakeValidator{userId: "user id"}
+		validator, clock := setUpValidator(fake)
+
+		for range 2 {
+			userId, err := validator.ValidateToken(ctx, "token")
+			assert.NoError(t, err)
+			assert.Equal(t, Id("user id"), userId)
+		}
+		assert.Equal(t, int32(1), fake.calls.Load())
+
+		*clock = clock.Add(10 * time.Minute)
+		_, err := validator.ValidateToken(ctx, "token")
+		assert.NoError(t, err)
+		assert.Equal(t, int32(2), fake.calls.Load())
+	})
+
+	t.Run("tokens cached separately", func(t *testing.T) {
+		fake := &fakeValidator{userId: "user id"}
+		validator, _ := setUpValidator(fake)
+
+		_, err := validator.ValidateToken(ctx, "token")
+		assert.NoError(t, err)
+		_, err = validator.ValidateToken(ctx, "other token")
+		assert.NoError(t, err)
+
+		assert.Equal(t, int32(2), fake.calls.Load())
+	})
+
+	t.Run("invalid token cached until negative ttl passes", func(t *testing.T) {
+		fake := &fakeValidator{err: ErrInvalidUserToken}
+		validator, clock := setUpValidator(fake)
+
+		for range 2 {
+			_, err := validator.ValidateToken(ctx, "token")
+			assert.Equal(t, ErrInvalidUserToken, err)
+		}
+		assert.Equal(t, int32(1), fake.calls.Load())
+
+		*clock = clock.Add(30 * time.Second)
+		_, err := validator.ValidateToken(ctx, "token")
+		assert.Equal(t, ErrInvalidUserToken, err)
+		assert.Equal(t, int32(2), fake.calls.Load())
+	})
+
+	t.Run("other errors not cached", func(t *testing.T) {
+		fake := &fakeValidator{err: assert.AnError}
+		validator, _ := setUpValidator(fake)
+
+		for range 2 {
+			_, err := validator.ValidateToken(ctx, "token")
+			assert.Equal(t, assert.AnError, err)
+		}
+		assert.Equal(t, int32(2), fake.calls.Load())
+	})
+
+	t.Run("tokens without a user id not cached", func(t *testing.T) {
+		fake := &fakeValidator{}
+		validator, _ := setUpValidator(fake)
+
+		for range 2 {
+			userId, err := validator.ValidateToken(ctx, "token")
+			assert.NoError(t, err)
+			assert.Equal(t, Id(""), userId)
+		}
+		assert.Equal(t, int32(2), fake.calls.Load())
+	})
+
+	t.Run("concurrent validations of a token collapsed", func(t *testing.T) {
+		fake := &fakeValidator{
+			userId:  "user id",
+			started: make(chan struct{}, 1),
+			release: make(chan struct{}),
+		}
+		validator, _ := setUpValidator(fake)
+
+		var wg sync.WaitGroup
+		for range 5 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				userId, err := validator.ValidateToken(ctx, "token")
+				assert.NoError(t, err)
+				assert.Equal(t, Id("user id"), userId)
+			}()
+		}
+
+		<-fake.started
+		// Callers arriving after the release find the result in the cache instead.
+		time.Sleep(10 * time.Millisecond)
+		close(fake.release)
+		wg.Wait()
+
+		assert.Equal(t, int32(1), fake.calls.Load())
+	})
+
+	t.Run("expired tokens forgotten", func(t *testing.T) {
+		fake := &fakeValidator{userId: "user id"}
+		validator, clock := setUpValidator(fake)
+
+		_, err := validator.ValidateToken(ctx, "token")
+		assert.NoError(t, err)
+
+		*clock = clock.Add(time.Hour)
+		_, err = validator.ValidateToken(ctx, "other token")
+		assert.NoError(t, err)
+
+		assert.Len(t, validator.tokens, 1)
+		assert.NotContains(t, validator.tokens, hashToken("token"))
+	})
+}
@@ -2,16 +2,17 @@ package twitch
 
 import (
 	"encoding/json"
-	"errors"
 	"io"
 	"net/http"
+
+	"github.com/streampets/backend/apperrors"
 )
 
 // A Twitch user id
 type Id string
 
 // Indicates an invalid Twitch user access token.
-var ErrInvalidUserToken error = errors.New("invalid access token")
+var ErrInvalidUserToken error = apperrors.New(apperrors.Unauthorized, "invalid_user_token", "invalid access token")
 
 // Unmarshals a response body into a specified struct.
 //